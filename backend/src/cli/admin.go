@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"time"
+
+	"expenses-tracker/src/handler"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/registry"
+	"expenses-tracker/src/repository"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// createUser adds a user with the default categories, like a signup. The
+// address counts as verified since no confirmation email is sent.
+func createUser(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	email := fs.String("email", "", "email address of the new user")
+	name := fs.String("name", "", "display name of the new user")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *name == "" {
+		return nil, errors.New("-name is required")
+	}
+	addr, err := mail.ParseAddress(*email)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email address %q", *email)
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		exists, err := reg.UserRepo.EmailExists(addr.Address, 0)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%s is already registered", addr.Address)
+		}
+		password, err := c.readPassword()
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user := model.M_user{
+			Name:            *name,
+			Email:           addr.Address,
+			EmailVerifiedAt: &now,
+			Password:        string(hashedPassword),
+		}
+		if err := reg.DB.Transaction(func(tx *gorm.DB) error {
+			if err := reg.UserRepo.WithTx(tx).Create(&user); err != nil {
+				return err
+			}
+			return repository.SeedDefaultCategories(tx, user.ID)
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Created user %d (%s)\n", user.ID, user.Email)
+		return nil
+	}, nil
+}
+
+// resetPassword sets a new password, signs out every session as a reset link
+// does, and revokes the user's personal access tokens too, since an admin
+// reset usually means the account was compromised.
+func resetPassword(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	ref := fs.String("user", "", "USER whose password to set")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		user, err := findUser(reg, *ref)
+		if err != nil {
+			return err
+		}
+		password, err := c.readPassword()
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		var sessions, tokens int64
+		if err := reg.DB.Transaction(func(tx *gorm.DB) error {
+			user.Password = string(hashedPassword)
+			if err := reg.UserRepo.WithTx(tx).Update(user); err != nil {
+				return err
+			}
+			if sessions, err = reg.RefreshTokenRepo.WithTx(tx).RevokeAll(user.ID, ""); err != nil {
+				return err
+			}
+			tokens, err = reg.AccessTokenRepo.WithTx(tx).RevokeAll(user.ID)
+			return err
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Password of user %d (%s) reset; %d session(s) signed out, %d access token(s) revoked\n", user.ID, user.Email, sessions, tokens)
+		return nil
+	}, nil
+}
+
+// seedCategories adds the default categories to a workspace the user can
+// access, or to their personal workspace. Workspaces that already have
+// categories are left alone.
+func seedCategories(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	ref := fs.String("user", "", "USER the categories belong to")
+	workspaceID := fs.Uint("workspace", 0, "workspace ID; 0 is the user's personal workspace")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		user, err := findUser(reg, *ref)
+		if err != nil {
+			return err
+		}
+		wsID := uint(*workspaceID)
+		if wsID != 0 {
+			if _, err := reg.WorkspaceRepo.GetAccessible(user.ID, wsID); err != nil {
+				return fmt.Errorf("workspace %d of user %d: %w", wsID, user.ID, err)
+			}
+		}
+		existing, err := reg.CategoryRepo.GetAll(user.ID, wsID, "")
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("workspace %d already has %d categories", wsID, len(existing))
+		}
+		if err := repository.SeedDefaultCategoriesForWorkspace(reg.DB, user.ID, wsID); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Seeded default categories for user %d in workspace %d\n", user.ID, wsID)
+		return nil
+	}, nil
+}
+
+// exportUser writes the archive a user gets from their own data export.
+func exportUser(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	ref := fs.String("user", "", "USER to export")
+	output := fs.String("o", "", "file to write (default personal-data-<id>-<date>.zip)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		user, err := findUser(reg, *ref)
+		if err != nil {
+			return err
+		}
+		path := *output
+		if path == "" {
+			path = fmt.Sprintf("personal-data-%d-%s.zip", user.ID, time.Now().Format("2006-01-02"))
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		err = handler.WritePersonalData(f, user, reg.PersonalDataRepo)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return err
+		}
+		fmt.Fprintf(c.out, "Exported user %d (%s) to %s\n", user.ID, user.Email, path)
+		return nil
+	}, nil
+}
+
+// purgeExpiredTokens runs the token cleanup job once.
+func purgeExpiredTokens(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		if err := reg.TokenCleanupScheduler.RunOnce(time.Now()); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "Expired tokens purged")
+		return nil
+	}, nil
+}
+
+// recomputeBalances derives every balance again, or those of one user, and
+// stores the ones that were missing.
+func recomputeBalances(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error) {
+	ref := fs.String("user", "", "only recompute the balances of USER")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return func(reg *registry.Registry, c *console) error {
+		var userID uint
+		if *ref != "" {
+			user, err := findUser(reg, *ref)
+			if err != nil {
+				return err
+			}
+			userID = user.ID
+		}
+
+		balances, err := reg.IncomeRepo.ListBalances(userID)
+		if err != nil {
+			return err
+		}
+		created := 0
+		for _, old := range balances {
+			b, err := reg.IncomeRepo.GetBalance(old.UserID, old.WorkspaceID)
+			if err != nil {
+				return fmt.Errorf("balance of user %d in workspace %d: %w", old.UserID, old.WorkspaceID, err)
+			}
+			switch {
+			case old.ID == 0:
+				created++
+				fmt.Fprintf(c.out, "User %d, workspace %d: missing -> %s\n", b.UserID, b.WorkspaceID, b.Amount)
+			case b.Amount != old.Amount:
+				fmt.Fprintf(c.out, "User %d, workspace %d: %s -> %s\n", b.UserID, b.WorkspaceID, old.Amount, b.Amount)
+			}
+		}
+		fmt.Fprintf(c.out, "Recomputed %d balance(s), %d of them missing before\n", len(balances), created)
+		return nil
+	}, nil
+}
@@ -0,0 +1,127 @@
+// Package cli implements the commands of the backend binary: the HTTP server,
+// schema migrations and the administrative tasks operators run against the
+// same database. Every command except migrate is wired through the registry.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"expenses-tracker/src/handler"
+	"expenses-tracker/src/migrate"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/registry"
+)
+
+const usage = `usage: webserver [command] [flags]
+
+  serve                  start the HTTP server (the default)
+  migrate                manage the database schema; see "migrate" for its commands
+  create-user            add a user, reading the password from stdin
+  reset-password         set a user's password, reading it from stdin, and sign them out
+  seed-categories        add the default categories to a user's workspace
+  export-user            write a user's personal data export to a ZIP file
+  purge-expired-tokens   delete refresh and email tokens that can no longer be used
+  recompute-balances     derive the stored balances again from accounts, income and expenses
+
+Run "webserver <command> -h" for the flags of a command. USER is a user ID or email address.`
+
+// adminCommand parses its flags and then does its work with a registry.
+type adminCommand func(fs *flag.FlagSet, args []string) (func(*registry.Registry, *console) error, error)
+
+var adminCommands = map[string]adminCommand{
+	"create-user":          createUser,
+	"reset-password":       resetPassword,
+	"seed-categories":      seedCategories,
+	"export-user":          exportUser,
+	"purge-expired-tokens": purgeExpiredTokens,
+	"recompute-balances":   recomputeBalances,
+}
+
+// console is where a command reads input and reports what it did.
+type console struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Run runs the command named by args[0], or the server when there is none.
+func Run(args []string, in io.Reader, out io.Writer) error {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "serve":
+		if len(args) > 0 {
+			return errors.New(usage)
+		}
+		return serve()
+	case "migrate":
+		return migrate.Command(args, out)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprintln(out, usage)
+		return nil
+	}
+
+	cmd, ok := adminCommands[name]
+	if !ok {
+		return errors.New(usage)
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	run, err := cmd(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected argument %q", name, fs.Arg(0))
+	}
+
+	reg, err := registry.NewRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to initialize registry: %w", err)
+	}
+	defer reg.Close()
+	return run(reg, &console{in: bufio.NewReader(in), out: out})
+}
+
+// findUser looks a user up by ID or, failing that, by email address.
+func findUser(reg *registry.Registry, ref string) (*model.M_user, error) {
+	if ref == "" {
+		return nil, errors.New("-user is required")
+	}
+	var user *model.M_user
+	var err error
+	if id, parseErr := strconv.ParseUint(ref, 10, 64); parseErr == nil {
+		user, err = reg.UserRepo.GetByID(uint(id))
+	} else {
+		user, err = reg.UserRepo.GetByEmail(ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user %s: %w", ref, err)
+	}
+	return user, nil
+}
+
+// readPassword prompts for a password and reads it as one line, so it can be
+// piped in rather than passed where other processes could see it.
+func (c *console) readPassword() (string, error) {
+	fmt.Fprint(c.out, "Password: ")
+	line, err := c.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < handler.MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", handler.MinPasswordLength)
+	}
+	return password, nil
+}
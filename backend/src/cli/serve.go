@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"expenses-tracker/src/registry"
+	"expenses-tracker/src/route"
+
+	"github.com/labstack/echo/v4"
+)
+
+// serve starts the background jobs and the HTTP server on $PORT.
+func serve() error {
+	// Initialize registry (database, repositories, handlers)
+	reg, err := registry.NewRegistry()
+	if err != nil {
+		return fmt.Errorf("failed to initialize registry: %w", err)
+	}
+	defer reg.Close()
+
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reg.RecurringScheduler.Start(ctx)
+	go reg.TokenCleanupScheduler.Start(ctx)
+	go reg.TrashScheduler.Start(ctx)
+	go reg.AccountPurgeScheduler.Start(ctx)
+
+	// Setup Echo
+	e := echo.New()
+
+	// Setup routes
+	route.SetupRoutes(e, reg)
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	log.Printf("Server starting on port %s", port)
+	if err := e.Start(":" + port); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
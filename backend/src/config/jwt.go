@@ -0,0 +1,26 @@
+package config
+
+import "os"
+
+// JWTConfig locates the keys access tokens are signed with. KeysDir holds
+// one PEM file per key, named <kid>.pem: private keys can sign and verify,
+// public keys only verify, which keeps tokens from a retired key valid
+// until they expire. SigningKeyID picks the signing key when there are
+// several private keys.
+type JWTConfig struct {
+	KeysDir      string
+	SigningKeyID string
+}
+
+func LoadJWTConfig() JWTConfig {
+	return JWTConfig{
+		KeysDir:      os.Getenv("JWT_KEYS_DIR"),
+		SigningKeyID: os.Getenv("JWT_SIGNING_KEY_ID"),
+	}
+}
+
+// IsProduction reports whether APP_ENV is "production". Production refuses
+// to start with development fallbacks such as a throwaway signing key.
+func IsProduction() bool {
+	return os.Getenv("APP_ENV") == "production"
+}
@@ -0,0 +1,55 @@
+package config
+
+import "os"
+
+// SMTPConfig holds the outgoing mail server settings. Host is empty when
+// email delivery is not configured.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// MailConfig selects how outgoing mail is delivered: through SMTP when
+// SMTP.Host is set, otherwise into SinkDir as .eml files, or into the log
+// when ToLog is set. The last two are for development only.
+type MailConfig struct {
+	SMTP    SMTPConfig
+	SinkDir string
+	ToLog   bool
+}
+
+func LoadSMTPConfig() SMTPConfig {
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.From == "" {
+		cfg.From = "no-reply@expenses-tracker.local"
+	}
+	return cfg
+}
+
+func LoadMailConfig() MailConfig {
+	return MailConfig{
+		SMTP:    LoadSMTPConfig(),
+		SinkDir: os.Getenv("MAIL_SINK_DIR"),
+		ToLog:   os.Getenv("MAIL_TO_LOG") == "true",
+	}
+}
+
+// AppURL is the public address of the frontend, used for links in emails.
+func AppURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return url
+	}
+	return "http://localhost"
+}
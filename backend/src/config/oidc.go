@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// OIDCConfig describes the OpenID Connect provider users may sign in with.
+// Login through it is disabled while Issuer is empty.
+type OIDCConfig struct {
+	Name         string // shown on the login button
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+func LoadOIDCConfig() OIDCConfig {
+	cfg := OIDCConfig{
+		Name:         os.Getenv("OIDC_PROVIDER_NAME"),
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if cfg.Name == "" {
+		cfg.Name = "Single sign-on"
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = strings.TrimRight(AppURL(), "/") + "/api/apps/auth/oidc/callback"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return cfg
+}
+
+// Enabled reports whether OIDC login is configured.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
@@ -0,0 +1,15 @@
+package config
+
+import "os"
+
+// TOTPConfig holds the application key two-factor secrets are encrypted with
+// at rest: 32 random bytes in standard base64, e.g. from
+// `openssl rand -base64 32`. Changing the key makes existing enrollments
+// unreadable, so it must be kept along with the database.
+type TOTPConfig struct {
+	EncryptionKey string
+}
+
+func LoadTOTPConfig() TOTPConfig {
+	return TOTPConfig{EncryptionKey: os.Getenv("TOTP_ENCRYPTION_KEY")}
+}
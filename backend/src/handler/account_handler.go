@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AccountHandler struct {
+	accountRepo *repository.AccountRepository
+}
+
+func NewAccountHandler(accountRepo *repository.AccountRepository) *AccountHandler {
+	return &AccountHandler{accountRepo: accountRepo}
+}
+
+type AccountRequest struct {
+	Name           string        `json:"name"`
+	Type           string        `json:"type"` // cash, bank, credit_card or e_wallet
+	OpeningBalance *money.Amount `json:"openingBalance"`
+	IsArchived     *bool         `json:"isArchived"`
+	Sequence       *int          `json:"sequence"`
+}
+
+func (h *AccountHandler) GetAccounts(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	includeArchived, _ := strconv.ParseBool(c.QueryParam("includeArchived"))
+	items, err := h.accountRepo.GetAll(cc.UserID, cc.WorkspaceID, includeArchived)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch accounts"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *AccountHandler) GetBalances(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.accountRepo.Balances(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch account balances"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetLedger lists the movements on one account with the running balance after
+// each. Optional query params from/to (YYYY-MM-DD) limit the dates shown.
+func (h *AccountHandler) GetLedger(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	account, err := h.accountRepo.GetByID(cc.UserID, cc.WorkspaceID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Account not found"})
+	}
+
+	var from, to *time.Time
+	if v := c.QueryParam("from"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid from date"})
+		}
+		from = &d
+	}
+	if v := c.QueryParam("to"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid to date"})
+		}
+		to = &d
+	}
+
+	entries, err := h.accountRepo.Ledger(account, from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch account ledger"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"account": account,
+		"entries": entries,
+	})
+}
+
+func (h *AccountHandler) CreateAccount(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	var req AccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Name is required"})
+	}
+	if req.Type == "" {
+		req.Type = model.AccountTypeCash
+	}
+	if !model.IsValidAccountType(req.Type) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid account type"})
+	}
+
+	account := &model.M_account{
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		Name:        req.Name,
+		Type:        req.Type,
+	}
+	if req.OpeningBalance != nil {
+		if err := checkAmount(cc.Currency, *req.OpeningBalance); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+		account.OpeningBalance = *req.OpeningBalance
+	}
+	if req.Sequence != nil {
+		account.Sequence = *req.Sequence
+	}
+
+	if err := h.accountRepo.Create(account); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create account"})
+	}
+	return c.JSON(http.StatusCreated, account)
+}
+
+func (h *AccountHandler) UpdateAccount(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+
+	var req AccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	account, err := h.accountRepo.GetByID(cc.UserID, cc.WorkspaceID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Account not found"})
+	}
+
+	if req.Name != "" {
+		account.Name = req.Name
+	}
+	if req.Type != "" {
+		if !model.IsValidAccountType(req.Type) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid account type"})
+		}
+		account.Type = req.Type
+	}
+	if req.OpeningBalance != nil {
+		if err := checkAmount(cc.Currency, *req.OpeningBalance); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+		account.OpeningBalance = *req.OpeningBalance
+	}
+	if req.IsArchived != nil {
+		account.IsArchived = *req.IsArchived
+	}
+	if req.Sequence != nil {
+		account.Sequence = *req.Sequence
+	}
+
+	if err := h.accountRepo.Update(account); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update account"})
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
+// DeleteAccount removes an account nothing refers to. Accounts with history
+// should be archived instead so their balances stay explainable.
+func (h *AccountHandler) DeleteAccount(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	err = h.accountRepo.Delete(cc.UserID, cc.WorkspaceID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Account not found"})
+	}
+	if errors.Is(err, repository.ErrAccountInUse) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Account has transactions, archive it instead"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete account"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// resolveAccount checks that id names an active account of the workspace and
+// returns the value to store on the transaction. Accounts are optional: with
+// id nil or 0 it returns nil and the transaction stays unassigned, counting
+// towards the workspace balance but towards no account's. The error message
+// is meant for the client.
+func resolveAccount(repo *repository.AccountRepository, cc *model.CustomContext, id *uint) (*uint, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	account, err := repo.GetByID(cc.UserID, cc.WorkspaceID, *id)
+	if err != nil {
+		return nil, errors.New("Invalid account")
+	}
+	if account.IsArchived {
+		return nil, errors.New("Account is archived")
+	}
+	return &account.ID, nil
+}
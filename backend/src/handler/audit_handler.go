@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+type AuditHandler struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{auditRepo: auditRepo}
+}
+
+// AuditPage is one page of the audit log.
+type AuditPage struct {
+	Items    []model.T_audit_log `json:"items"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"pageSize"`
+}
+
+// GetAuditLog lists who changed what in the active workspace, newest first.
+// Query params: userId, action, entity, entityId, from and to (YYYY-MM-DD,
+// inclusive), page (from 1) and pageSize (default 50, at most 200).
+func (h *AuditHandler) GetAuditLog(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var filter repository.AuditFilter
+	filter.Action = c.QueryParam("action")
+	filter.Entity = c.QueryParam("entity")
+	if v := c.QueryParam("userId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid user ID"})
+		}
+		filter.UserID = uint(id)
+	}
+	if v := c.QueryParam("entityId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid entity ID"})
+		}
+		filter.EntityID = uint(id)
+	}
+	if v := c.QueryParam("from"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid from date"})
+		}
+		filter.From = &d
+	}
+	if v := c.QueryParam("to"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid to date"})
+		}
+		end := d.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	page := 1
+	if v := c.QueryParam("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid page"})
+		}
+		page = n
+	}
+	pageSize := defaultAuditPageSize
+	if v := c.QueryParam("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditPageSize {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "pageSize must be between 1 and " + strconv.Itoa(maxAuditPageSize)})
+		}
+		pageSize = n
+	}
+
+	items, total, err := h.auditRepo.List(cc.UserID, cc.WorkspaceID, filter, page, pageSize)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch audit log"})
+	}
+	return c.JSON(http.StatusOK, AuditPage{Items: items, Total: total, Page: page, PageSize: pageSize})
+}
+
+// recordAudit appends an audit entry for a change the caller made in
+// workspaceID. Callers pass the audit repository bound to the change's
+// transaction, so that neither is saved without the other.
+func recordAudit(repo *repository.AuditRepository, cc *model.CustomContext, workspaceID uint, action, entity string, entityID uint, before, after interface{}) error {
+	userID := cc.UserID
+	entry := &model.T_audit_log{
+		UserID:      &userID,
+		WorkspaceID: workspaceID,
+		Action:      action,
+		Entity:      entity,
+		IP:          cc.RealIP(),
+	}
+	if entityID != 0 {
+		entry.EntityID = &entityID
+	}
+	return repo.Record(entry, before, after)
+}
+
+// auditSnapshot captures v before it is modified in place, for use as the
+// before state of recordAudit.
+func auditSnapshot(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -1,13 +1,22 @@
 package handler
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
+	"errors"
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/mailer"
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+	"fmt"
+	"log"
 	"net/http"
-	"os"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -16,29 +25,75 @@ import (
 	"gorm.io/gorm"
 )
 
+// twoFactorChallengeTTL is how long the user has to enter their code after
+// the password step of a two-factor login.
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// Lifetimes of the links sent by email
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// MinPasswordLength applies to every new password: at signup, on a change,
+// through a reset or the admin CLI.
+const MinPasswordLength = 6
+
+// errEmailTaken aborts an email change whose address was claimed in the meantime.
+var errEmailTaken = errors.New("email already in use")
+
 type AuthHandler struct {
 	userRepo         *repository.UserRepository
 	refreshTokenRepo *repository.RefreshTokenRepository
+	accessTokenRepo  *repository.PersonalAccessTokenRepository
+	recoveryRepo     *repository.RecoveryCodeRepository
+	userTokenRepo    *repository.UserTokenRepository
+	rateRepo         *repository.ExchangeRateRepository
+	mailer           mailer.Mailer
+	guard            *security.LoginGuard
+	keys             *security.KeySet
+	secrets          *security.SecretBox
 	db               *gorm.DB
 }
 
-func NewAuthHandler(userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, db *gorm.DB) *AuthHandler {
+func NewAuthHandler(userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, accessTokenRepo *repository.PersonalAccessTokenRepository, recoveryRepo *repository.RecoveryCodeRepository, userTokenRepo *repository.UserTokenRepository, rateRepo *repository.ExchangeRateRepository, m mailer.Mailer, guard *security.LoginGuard, keys *security.KeySet, secrets *security.SecretBox, db *gorm.DB) *AuthHandler {
 	return &AuthHandler{
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
+		accessTokenRepo:  accessTokenRepo,
+		recoveryRepo:     recoveryRepo,
+		userTokenRepo:    userTokenRepo,
+		rateRepo:         rateRepo,
+		mailer:           m,
+		guard:            guard,
+		keys:             keys,
+		secrets:          secrets,
 		db:               db,
 	}
 }
 
 type SignupRequest struct {
-	Name     string `json:"name" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"` // TOTP code or recovery code
+}
+
+// TwoFactorChallengeResponse is returned by Login instead of tokens when the
+// user has two-factor authentication enabled.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	ChallengeToken    string    `json:"challengeToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
 }
 
 type AuthResponse struct {
@@ -48,7 +103,7 @@ type AuthResponse struct {
 }
 
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refreshToken" validate:"required"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 type RefreshTokenResponse struct {
@@ -61,9 +116,22 @@ func (h *AuthHandler) Signup(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Name is required"})
+	}
+	if len(req.Password) < MinPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("Password must be at least %d characters", MinPasswordLength)})
+	}
+
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid email address"})
+	}
+	req.Email = addr.Address
 
 	// Check if user already exists
-	_, err := h.userRepo.GetByEmail(req.Email)
+	_, err = h.userRepo.GetByEmail(req.Email)
 	if err == nil {
 		return c.JSON(http.StatusConflict, map[string]string{"message": "Email already registered"})
 	}
@@ -91,17 +159,21 @@ func (h *AuthHandler) Signup(c echo.Context) error {
 		// In production, you might want to log this to a monitoring service
 	}
 
-	// Generate access token (3 minutes) and refresh token (7 days)
-	accessToken, err := h.generateAccessToken(user.ID, user.Email)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
+	if err := h.sendVerificationEmail(&user, user.Email); err != nil {
+		log.Printf("Signup: verification email for user %d: %v", user.ID, err)
 	}
 
-	refreshToken, err := h.generateRefreshToken(user.ID)
+	// Generate refresh token (7 days) and access token (3 minutes)
+	refreshToken, session, err := h.generateRefreshToken(c, user.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate refresh token"})
 	}
 
+	accessToken, err := h.generateAccessToken(user.ID, user.Email, session.FamilyID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
+	}
+
 	return c.JSON(http.StatusCreated, AuthResponse{
 		Token:        accessToken,
 		RefreshToken: refreshToken,
@@ -115,28 +187,118 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
+	// Refuse guesses while the account or address is backing off
+	attempt, wait, err := h.guard.Begin(req.Email, c.RealIP(), time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to sign in"})
+	}
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
 	// Find user
 	user, err := h.userRepo.GetByEmail(req.Email)
 	if err != nil {
+		attempt.Failed(nil, time.Now())
 		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid email or password"})
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		attempt.Failed(&user.ID, time.Now())
 		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid email or password"})
 	}
+	refundAttempt(attempt)
 
-	// Generate access token (3 minutes) and refresh token (7 days)
-	accessToken, err := h.generateAccessToken(user.ID, user.Email)
+	return h.beginLogin(c, user)
+}
+
+// beginLogin follows a successful first factor: with 2FA it only returns a
+// challenge and tokens come from LoginTwoFactor, otherwise it signs in.
+func (h *AuthHandler) beginLogin(c echo.Context, user *model.M_user) error {
+	if user.TOTPEnabled {
+		expiresAt := time.Now().Add(twoFactorChallengeTTL)
+		challenge, err := h.generateChallengeToken(user.ID, expiresAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate challenge token"})
+		}
+		return c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresAt:         expiresAt,
+		})
+	}
+
+	return h.completeLogin(c, user)
+}
+
+// LoginTwoFactor finishes a two-factor login: it takes the challenge token
+// from Login plus a TOTP or recovery code and issues the session tokens.
+func (h *AuthHandler) LoginTwoFactor(c echo.Context) error {
+	var req LoginTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	userID, err := h.parseChallengeToken(req.ChallengeToken)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid or expired challenge, please sign in again"})
+	}
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid or expired challenge, please sign in again"})
+	}
+
+	// Wrong codes count against the account like wrong passwords
+	attempt, wait, err := h.guard.Begin(user.Email, c.RealIP(), time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify code"})
+	}
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
 	}
 
-	refreshToken, err := h.generateRefreshToken(user.ID)
+	ok, err := verifySecondFactor(h.userRepo, h.recoveryRepo, h.secrets, user, req.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify code"})
+	}
+	if !ok {
+		attempt.Failed(&user.ID, time.Now())
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid code"})
+	}
+	refundAttempt(attempt)
+
+	return h.completeLogin(c, user)
+}
+
+func refundAttempt(attempt *security.Attempt) {
+	if err := attempt.Refund(); err != nil {
+		log.Printf("Login guard: refund attempt: %v", err)
+	}
+}
+
+func tooManyAttempts(c echo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)))
+	return c.JSON(http.StatusTooManyRequests, map[string]string{"message": security.RetryMessage(wait)})
+}
+
+// completeLogin issues the access and refresh tokens of a new session.
+func (h *AuthHandler) completeLogin(c echo.Context, user *model.M_user) error {
+	if err := h.guard.Succeeded(user.Email); err != nil {
+		log.Printf("Login guard: reset user %d: %v", user.ID, err)
+	}
+
+	// Generate refresh token (7 days) and access token (3 minutes)
+	refreshToken, session, err := h.generateRefreshToken(c, user.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate refresh token"})
 	}
 
+	accessToken, err := h.generateAccessToken(user.ID, user.Email, session.FamilyID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
+	}
+
 	return c.JSON(http.StatusOK, AuthResponse{
 		Token:        accessToken,
 		RefreshToken: refreshToken,
@@ -162,12 +324,12 @@ type UpdateProfileRequest struct {
 }
 
 type UpdateCurrencyRequest struct {
-	Currency string `json:"currency" validate:"required,oneof=IDR USD EUR JPY"`
+	Currency string `json:"currency"`
 }
 
 type ChangePasswordRequest struct {
-	CurrentPassword string `json:"currentPassword" validate:"required"`
-	NewPassword     string `json:"newPassword" validate:"required,min=6"`
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
 }
 
 func (h *AuthHandler) UpdateProfile(c echo.Context) error {
@@ -184,13 +346,20 @@ func (h *AuthHandler) UpdateProfile(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
 	}
 
-	// Check if email is being changed and if it's already taken
-	if req.Email != user.Email {
-		exists, err := h.userRepo.EmailExists(req.Email, userID)
+	// Check if email is being changed and if it's already taken. The new
+	// address only replaces the current one once it has been verified.
+	emailChanged := false
+	if req.Email != "" && req.Email != user.Email {
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid email address"})
+		}
+		exists, err := h.userRepo.EmailExists(addr.Address, userID)
 		if err == nil && exists {
 			return c.JSON(http.StatusConflict, map[string]string{"message": "Email already in use"})
 		}
-		user.Email = req.Email
+		user.PendingEmail = addr.Address
+		emailChanged = true
 	}
 
 	if req.Name != "" {
@@ -201,6 +370,12 @@ func (h *AuthHandler) UpdateProfile(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update profile"})
 	}
 
+	if emailChanged {
+		if err := h.sendVerificationEmail(user, user.PendingEmail); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to send verification email"})
+		}
+	}
+
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -212,15 +387,32 @@ func (h *AuthHandler) UpdateCurrency(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if !money.IsCurrencyCode(req.Currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid currency"})
+	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
 	}
 
+	// The currency is the base currency of the personal workspace, whose
+	// transactions are relabeled along with it
+	from := user.Currency
 	user.Currency = req.Currency
-
-	if err := h.userRepo.Update(user); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if from != req.Currency {
+			if err := h.rateRepo.WithTx(tx).ChangeBaseCurrency(userID, 0, from, req.Currency); err != nil {
+				return err
+			}
+		}
+		return h.userRepo.WithTx(tx).Update(user)
+	})
+	if errors.Is(err, repository.ErrBaseCurrencyInUse) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Your personal workspace has exchange rates or transactions in other currencies, so its currency cannot change"})
+	}
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update currency"})
 	}
 
@@ -235,6 +427,9 @@ func (h *AuthHandler) ChangePassword(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	if len(req.NewPassword) < MinPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("Password must be at least %d characters", MinPasswordLength)})
+	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
@@ -254,94 +449,404 @@ func (h *AuthHandler) ChangePassword(c echo.Context) error {
 
 	user.Password = string(hashedPassword)
 
-	if err := h.userRepo.Update(user); err != nil {
+	// Signing out every session and access token is part of the change, so
+	// they all happen together
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+		if _, err := h.refreshTokenRepo.WithTx(tx).RevokeAll(user.ID, ""); err != nil {
+			return err
+		}
+		_, err := h.accessTokenRepo.WithTx(tx).RevokeAll(user.ID)
+		return err
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update password"})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Password updated successfully"})
-}
-
-func (h *AuthHandler) generateAccessToken(userID uint, email string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-in-production"
+	// Keep the caller signed in with a fresh session
+	refreshToken, session, err := h.generateRefreshToken(c, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate refresh token"})
+	}
+	accessToken, err := h.generateAccessToken(user.ID, user.Email, session.FamilyID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
 	}
 
+	return c.JSON(http.StatusOK, map[string]string{
+		"message":      "Password updated successfully",
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+	})
+}
+
+func (h *AuthHandler) generateAccessToken(userID uint, email string, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"userId": userID,
 		"email":  email,
 		"exp":    time.Now().Add(time.Minute * 3).Unix(), // 3 minutes
 		"type":   "access",
+		"sid":    sessionID,
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return h.keys.Sign(claims)
 }
 
-func (h *AuthHandler) generateRefreshToken(userID uint) (string, error) {
-	// Generate a random token string
-	tokenString := generateRandomString(32)
-
-	// Store refresh token in database
-	refreshToken := model.M_refresh_token{
-		UserID:    userID,
-		Token:     tokenString,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 7), // 7 days
+// generateChallengeToken proves the password step of a two-factor login. The
+// middleware only accepts access tokens, so it cannot be used for anything else.
+func (h *AuthHandler) generateChallengeToken(userID uint, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"userId": userID,
+		"exp":    expiresAt.Unix(),
+		"type":   "2fa_challenge",
 	}
+	return h.keys.Sign(claims)
+}
 
-	if err := h.refreshTokenRepo.Create(&refreshToken); err != nil {
-		return "", err
+func (h *AuthHandler) parseChallengeToken(tokenString string) (uint, error) {
+	claims, err := h.keys.Parse(tokenString)
+	if err != nil || claims["type"] != "2fa_challenge" {
+		return 0, echo.ErrUnauthorized
 	}
+	userID, ok := claims["userId"].(float64)
+	if !ok {
+		return 0, echo.ErrUnauthorized
+	}
+	return uint(userID), nil
+}
+
+// generateRefreshToken starts a session for the device making the request and
+// returns its refresh token, which is only stored hashed.
+func (h *AuthHandler) generateRefreshToken(c echo.Context, userID uint) (string, *model.M_refresh_token, error) {
+	return h.refreshTokenRepo.Issue(userID, c.Request().Header.Get("X-Device-Id"), truncate(c.Request().UserAgent(), 512))
+}
 
-	return tokenString, nil
+// JWKS publishes the public keys access tokens can be verified with, so
+// other services can check them without sharing a secret.
+func (h *AuthHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, map[string]interface{}{"keys": h.keys.JWKS()})
 }
 
+// RefreshToken exchanges a refresh token for a new access token and the next
+// refresh token of the same session.
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
 	var req RefreshTokenRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
-	// Find refresh token in database
-	refreshToken, err := h.refreshTokenRepo.GetByToken(req.RefreshToken)
-	if err != nil {
+	newRefreshToken, session, err := h.refreshTokenRepo.Rotate(req.RefreshToken, c.Request().Header.Get("X-Device-Id"))
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenReused):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Refresh token was already used; the session has been signed out"})
+	case errors.Is(err, repository.ErrRefreshTokenRotated):
+		// Another request rotated this token a moment ago
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Refresh token was just rotated; use the newer token"})
+	case err != nil:
 		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid or expired refresh token"})
 	}
 
 	// Get user
-	user, err := h.userRepo.GetByID(refreshToken.UserID)
+	user, err := h.userRepo.GetByID(session.UserID)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "User not found"})
 	}
 
 	// Generate new access token
-	accessToken, err := h.generateAccessToken(user.ID, user.Email)
+	accessToken, err := h.generateAccessToken(user.ID, user.Email, session.FamilyID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate access token"})
 	}
 
-	// Optionally rotate refresh token (delete old, create new)
-	h.refreshTokenRepo.Delete(refreshToken)
-	newRefreshToken, err := h.generateRefreshToken(user.ID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate refresh token"})
-	}
-
 	return c.JSON(http.StatusOK, RefreshTokenResponse{
 		Token:        accessToken,
 		RefreshToken: newRefreshToken,
 	})
 }
 
-func generateRandomString(length int) string {
-	b := make([]byte, length)
-	if _, err := rand.Read(b); err != nil {
-		// Fallback: use timestamp + random bytes as string
-		return time.Now().Format("20060102150405") + string(b)
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// SessionResponse describes one signed-in device without exposing its token.
+type SessionResponse struct {
+	ID         string     `json:"id"` // token family of the session
+	DeviceID   string     `json:"deviceId"`
+	UserAgent  string     `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	Current    bool       `json:"current"`
+}
+
+// GetSessions lists the caller's active sessions, one per signed-in device.
+func (h *AuthHandler) GetSessions(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	tokens, err := h.refreshTokenRepo.GetActive(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch sessions"})
+	}
+
+	current := h.currentSessionID(cc)
+	sessions := make([]SessionResponse, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, SessionResponse{
+			ID:         t.FamilyID,
+			DeviceID:   t.DeviceID,
+			UserAgent:  t.UserAgent,
+			CreatedAt:  t.StartedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    t.FamilyID == current,
+		})
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs one device out. Its access token stays valid until it
+// expires, at most a few minutes later.
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	if err := h.refreshTokenRepo.Revoke(cc.UserID, c.Param("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Session not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to revoke session"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs out every device except the one making the request.
+func (h *AuthHandler) RevokeOtherSessions(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	current := h.currentSessionID(cc)
+	if current == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Current session could not be determined"})
+	}
+
+	revoked, err := h.refreshTokenRepo.RevokeAll(cc.UserID, current)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to revoke sessions"})
+	}
+	return c.JSON(http.StatusOK, map[string]int64{"revoked": revoked})
+}
+
+// currentSessionID identifies the caller's session from the access token, or
+// from the refresh token header for tokens issued before sessions were tracked.
+func (h *AuthHandler) currentSessionID(cc *model.CustomContext) string {
+	if cc.SessionID != "" {
+		return cc.SessionID
 	}
-	encoded := base64.URLEncoding.EncodeToString(b)
-	if len(encoded) >= length {
-		return encoded[:length]
+	if header := cc.Request().Header.Get("X-Refresh-Token"); header != "" {
+		if rt, err := h.refreshTokenRepo.GetByToken(header); err == nil && rt.UserID == cc.UserID {
+			return rt.FamilyID
+		}
 	}
-	return encoded
+	return ""
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmail confirms the address a verification link was sent to. For an
+// email change this is when the new address replaces the old one.
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	err := h.userTokenRepo.Consume(req.Token, model.TokenEmailVerification, func(tx *gorm.DB, t *model.M_user_token) error {
+		userRepo := h.userRepo.WithTx(tx)
+		user, err := userRepo.GetByID(t.UserID)
+		if err != nil {
+			return repository.ErrUserTokenInvalid
+		}
+		if t.Email != user.Email {
+			// Links for an address the user has since moved away from are void
+			if t.Email != user.PendingEmail {
+				return repository.ErrUserTokenInvalid
+			}
+			exists, err := userRepo.EmailExists(t.Email, user.ID)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return errEmailTaken
+			}
+			user.Email = t.Email
+			user.PendingEmail = ""
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return userRepo.Update(user)
+	})
+	switch {
+	case errors.Is(err, repository.ErrUserTokenInvalid):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid or expired verification link"})
+	case errors.Is(err, errEmailTaken):
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Email already in use"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify email"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Email address verified"})
+}
+
+// ResendVerification sends a new link for the pending or unverified address.
+func (h *AuthHandler) ResendVerification(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	address := user.PendingEmail
+	if address == "" {
+		if user.EmailVerifiedAt != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Email address is already verified"})
+		}
+		address = user.Email
+	}
+	if err := h.sendVerificationEmail(user, address); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to send verification email"})
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the address belongs to an account, and so is its timing:
+// the lookup and everything after it happen once the response is sent.
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	go h.startPasswordReset(strings.TrimSpace(req.Email))
+
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "If an account exists for that address, a reset link has been sent"})
+}
+
+// startPasswordReset issues a reset token for the account registered under
+// email, if any, and mails the link. Failures are logged.
+func (h *AuthHandler) startPasswordReset(email string) {
+	user, err := h.userRepo.GetByEmail(email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Forgot password: lookup: %v", err)
+		}
+		return
+	}
+	token, err := h.userTokenRepo.Issue(user.ID, model.TokenPasswordReset, user.Email, passwordResetTTL)
+	if err != nil {
+		log.Printf("Forgot password: token for user %d: %v", user.ID, err)
+		return
+	}
+	h.sendMail(mailer.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes and works once.\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Name, int(passwordResetTTL.Minutes()), appLink("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password from a reset link, signs out every
+// session and revokes every access token. It does not sign the user in, so two-factor login still applies.
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	if len(req.Password) < MinPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("Password must be at least %d characters", MinPasswordLength)})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to hash password"})
+	}
+
+	err = h.userTokenRepo.Consume(req.Token, model.TokenPasswordReset, func(tx *gorm.DB, t *model.M_user_token) error {
+		userRepo := h.userRepo.WithTx(tx)
+		user, err := userRepo.GetByID(t.UserID)
+		if err != nil || user.Email != t.Email {
+			return repository.ErrUserTokenInvalid
+		}
+		user.Password = string(hashedPassword)
+		// Following the link proved the user reads this inbox
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		if err := userRepo.Update(user); err != nil {
+			return err
+		}
+		if _, err := h.refreshTokenRepo.WithTx(tx).RevokeAll(user.ID, ""); err != nil {
+			return err
+		}
+		_, err = h.accessTokenRepo.WithTx(tx).RevokeAll(user.ID)
+		return err
+	})
+	switch {
+	case errors.Is(err, repository.ErrUserTokenInvalid):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid or expired reset link"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reset password"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+// sendVerificationEmail sends user a link confirming address.
+func (h *AuthHandler) sendVerificationEmail(user *model.M_user, address string) error {
+	token, err := h.userTokenRepo.Issue(user.ID, model.TokenEmailVerification, address, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	h.sendMail(mailer.Email{
+		To:      address,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm %s as your email address by opening the link below. It expires in %d hours.\n\n%s\n",
+			user.Name, address, int(emailVerificationTTL.Hours()), appLink("/verify-email", token)),
+	})
+	return nil
+}
+
+func (h *AuthHandler) sendMail(email mailer.Email) {
+	sendMailAsync(h.mailer, email)
+}
+
+// sendMailAsync delivers email in the background so a slow mail server does
+// not hold up the request. Failures are logged.
+func sendMailAsync(m mailer.Mailer, email mailer.Email) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := m.Send(ctx, email); err != nil {
+			log.Printf("Mail to %s: %v", email.To, err)
+		}
+	}()
+}
+
+// appLink builds a frontend link carrying token.
+func appLink(path, token string) string {
+	return strings.TrimRight(config.AppURL(), "/") + path + "?token=" + url.QueryEscape(token)
 }
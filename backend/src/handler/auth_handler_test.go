@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+	"expenses-tracker/src/testdb"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordChangesRevokeAccessTokens(t *testing.T) {
+	db := testdb.Open(t)
+	keys, err := security.NewKeySet(config.JWTConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRepo := repository.NewUserRepository(db)
+	refreshRepo := repository.NewRefreshTokenRepository(db)
+	accessRepo := repository.NewPersonalAccessTokenRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	h := NewAuthHandler(userRepo, refreshRepo, accessRepo, repository.NewRecoveryCodeRepository(db), userTokenRepo,
+		repository.NewExchangeRateRepository(db), nil, nil, keys, nil, db)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: string(hashed), Currency: "IDR"}
+	mustCreate(t, db, &user)
+
+	// issue gives the user a session and an access token, and returns the token
+	issue := func() string {
+		t.Helper()
+		if _, _, err := refreshRepo.Issue(user.ID, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		token, err := accessRepo.Create(&model.M_personal_access_token{UserID: user.ID, Name: "script", Scope: model.TokenScopeRead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	token := issue()
+	rec := call(h.ChangePassword, user.ID, 0, http.MethodPut, "/api/apps/auth/password", `{"currentPassword":"old password","newPassword":"new password"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := accessRepo.GetActive(token); err == nil {
+		t.Error("access token still works after changing the password")
+	}
+
+	token = issue()
+	reset, err := userTokenRepo.Issue(user.ID, model.TokenPasswordReset, user.Email, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = call(h.ResetPassword, 0, 0, http.MethodPost, "/api/apps/auth/reset-password", `{"token":"`+reset+`","password":"newer password"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset password: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := accessRepo.GetActive(token); err == nil {
+		t.Error("access token still works after resetting the password")
+	}
+	var sessions int64
+	db.Model(&model.M_refresh_token{}).Where("user_id = ?", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions left after resetting the password, want 0", sessions)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxThresholdPercent allows alerts well past the budget, e.g. at 150%.
+const maxThresholdPercent = 1000
+
+type BudgetAlertHandler struct {
+	alertRepo  *repository.BudgetAlertRepository
+	budgetRepo *repository.BudgetRepository
+}
+
+func NewBudgetAlertHandler(alertRepo *repository.BudgetAlertRepository, budgetRepo *repository.BudgetRepository) *BudgetAlertHandler {
+	return &BudgetAlertHandler{
+		alertRepo:  alertRepo,
+		budgetRepo: budgetRepo,
+	}
+}
+
+// GetThresholds lists the thresholds with their budgets, optionally for
+// ?month (YYYY-MM) only.
+func (h *BudgetAlertHandler) GetThresholds(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.alertRepo.GetThresholds(cc.UserID, cc.WorkspaceID, c.QueryParam("month"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch thresholds"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// SetThresholds replaces the alert percentages of one budget, e.g.
+// {"percents": [50, 80, 100]}. An empty list removes them all. Copying a
+// month's budgets copies their thresholds along.
+func (h *BudgetAlertHandler) SetThresholds(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	budgetID, err := strconv.Atoi(c.Param("budgetId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid budget ID"})
+	}
+
+	var req struct {
+		Percents []int `json:"percents"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	for _, p := range req.Percents {
+		if p <= 0 || p > maxThresholdPercent {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Threshold percentages must be between 1 and 1000"})
+		}
+	}
+	sort.Ints(req.Percents)
+
+	budget, err := h.budgetRepo.GetByID(cc.UserID, cc.WorkspaceID, uint(budgetID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Budget not found"})
+	}
+
+	items, err := h.alertRepo.ReplaceThresholds(cc.UserID, cc.WorkspaceID, budget.ID, req.Percents)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update thresholds"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetAlerts lists the thresholds that fired, optionally for ?month (YYYY-MM).
+func (h *BudgetAlertHandler) GetAlerts(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.alertRepo.GetAlerts(cc.UserID, cc.WorkspaceID, c.QueryParam("month"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch alerts"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
@@ -1,28 +1,65 @@
 package handler
 
 import (
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type BudgetHandler struct {
+	db           *gorm.DB
 	budgetRepo   *repository.BudgetRepository
 	categoryRepo *repository.CategoryRepository
+	expenseRepo  *repository.ExpenseRepository
+	alertRepo    *repository.BudgetAlertRepository
+	auditRepo    *repository.AuditRepository
 }
 
-func NewBudgetHandler(budgetRepo *repository.BudgetRepository, categoryRepo *repository.CategoryRepository) *BudgetHandler {
+func NewBudgetHandler(db *gorm.DB, budgetRepo *repository.BudgetRepository, categoryRepo *repository.CategoryRepository, expenseRepo *repository.ExpenseRepository, alertRepo *repository.BudgetAlertRepository, auditRepo *repository.AuditRepository) *BudgetHandler {
 	return &BudgetHandler{
+		db:           db,
 		budgetRepo:   budgetRepo,
 		categoryRepo: categoryRepo,
+		expenseRepo:  expenseRepo,
+		alertRepo:    alertRepo,
+		auditRepo:    auditRepo,
 	}
 }
 
+// BudgetReportLine compares planned and actual spending for one category, or
+// for all budgeted categories in the report totals.
+type BudgetReportLine struct {
+	CategoryID  uint         `json:"categoryId,omitempty"`
+	Category    string       `json:"category,omitempty"`
+	Planned     money.Amount `json:"planned"`
+	CarriedIn   money.Amount `json:"carriedIn"` // envelope balance brought into the period by rollover categories
+	Available   money.Amount `json:"available"` // planned plus carried in
+	Spent       money.Amount `json:"spent"`
+	Remaining   money.Amount `json:"remaining"`
+	PercentUsed float64      `json:"percentUsed"` // share of the available amount spent, 0 when nothing is available
+	DailyBurn   money.Amount `json:"dailyBurn"`   // average spend per elapsed day
+	Projected   money.Amount `json:"projected"`   // expected spend by the end of the period at the current burn rate
+}
+
+type BudgetReport struct {
+	From        string                     `json:"from"` // YYYY-MM
+	To          string                     `json:"to"`   // YYYY-MM
+	Days        int                        `json:"days"`
+	ElapsedDays int                        `json:"elapsedDays"`
+	Categories  []BudgetReportLine         `json:"categories"`
+	Totals      BudgetReportLine           `json:"totals"`
+	Unbudgeted  []repository.CategoryTotal `json:"unbudgeted"` // spending in categories without a budget
+}
+
 func (h *BudgetHandler) GetBudgets(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	month := c.QueryParam("month")
@@ -30,9 +67,9 @@ func (h *BudgetHandler) GetBudgets(c echo.Context) error {
 	var items []model.R_budget
 	var err error
 	if month != "" {
-		items, err = h.budgetRepo.GetByUserAndMonth(cc.UserID, month)
+		items, err = h.budgetRepo.GetByUserAndMonth(cc.UserID, cc.WorkspaceID, month)
 	} else {
-		items, err = h.budgetRepo.GetByUser(cc.UserID)
+		items, err = h.budgetRepo.GetByUser(cc.UserID, cc.WorkspaceID)
 	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch budgets"})
@@ -41,10 +78,10 @@ func (h *BudgetHandler) GetBudgets(c echo.Context) error {
 	// Shape response to what frontend expects:
 	// [{ categoryId, categoryName, amount, month }]
 	type resp struct {
-		CategoryID   uint    `json:"categoryId"`
-		CategoryName string  `json:"categoryName"`
-		Amount       float64 `json:"amount"`
-		Month        string  `json:"month"`
+		CategoryID   uint         `json:"categoryId"`
+		CategoryName string       `json:"categoryName"`
+		Amount       money.Amount `json:"amount"`
+		Month        string       `json:"month"`
 	}
 	out := make([]resp, 0, len(items))
 	for _, b := range items {
@@ -63,34 +100,224 @@ func (h *BudgetHandler) GetBudgets(c echo.Context) error {
 	return c.JSON(http.StatusOK, out)
 }
 
+// GetReport compares each budgeted category's plan with what was actually spent.
+// Query params: month (YYYY-MM), or from and to (YYYY-MM, inclusive) for a
+// range; defaults to the current month. Spending is aggregated from expense
+// splits exactly as in the month details.
+func (h *BudgetHandler) GetReport(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	fromMonth, toMonth := c.QueryParam("from"), c.QueryParam("to")
+	if m := c.QueryParam("month"); m != "" {
+		fromMonth, toMonth = m, m
+	}
+	if fromMonth == "" && toMonth == "" {
+		fromMonth = time.Now().Format("2006-01")
+	}
+	if fromMonth == "" {
+		fromMonth = toMonth
+	}
+	if toMonth == "" {
+		toMonth = fromMonth
+	}
+	start, err := time.Parse("2006-01", fromMonth)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid from month"})
+	}
+	last, err := time.Parse("2006-01", toMonth)
+	if err != nil || last.Before(start) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid to month"})
+	}
+	end := last.AddDate(0, 1, 0) // exclusive
+
+	planned, err := h.budgetRepo.SumByCategory(cc.UserID, cc.WorkspaceID, fromMonth, toMonth)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch budgets"})
+	}
+	spent, err := h.expenseRepo.SumByCategory(cc.UserID, cc.WorkspaceID, start, end)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch expenses"})
+	}
+
+	// Days of the period that have started, counting today
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(end.Sub(start).Hours() / 24)
+	elapsed := int(today.Sub(start).Hours()/24) + 1
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > days {
+		elapsed = days
+	}
+
+	spentByCategory := make(map[uint]money.Amount, len(spent))
+	for _, s := range spent {
+		spentByCategory[s.CategoryID] = s.Total
+	}
+
+	// Rollover categories bring their envelope balance into the period, and
+	// are reported even when nothing is planned for it
+	rollover, err := h.categoryRepo.GetWithRollover(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch categories"})
+	}
+	carried, err := h.budgetRepo.CarriedInto(cc.UserID, cc.WorkspaceID, rollover, fromMonth)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to compute budget rollover"})
+	}
+	budgeted := make(map[uint]bool, len(planned))
+	for _, p := range planned {
+		budgeted[p.CategoryID] = true
+	}
+	for _, cat := range rollover {
+		if !budgeted[cat.ID] && (carried[cat.ID] != 0 || *cat.RolloverSince <= toMonth) {
+			planned = append(planned, repository.CategoryTotal{CategoryID: cat.ID, Category: cat.Name})
+		}
+	}
+
+	report := BudgetReport{
+		From:        fromMonth,
+		To:          toMonth,
+		Days:        days,
+		ElapsedDays: elapsed,
+		Categories:  make([]BudgetReportLine, 0, len(planned)),
+		Unbudgeted:  []repository.CategoryTotal{},
+	}
+	var totalPlanned, totalCarried, totalSpent money.Amount
+	for _, p := range planned {
+		line := newBudgetReportLine(p.Total, carried[p.CategoryID], spentByCategory[p.CategoryID], elapsed, days, cc.Currency)
+		line.CategoryID = p.CategoryID
+		line.Category = p.Category
+		report.Categories = append(report.Categories, line)
+		totalPlanned += p.Total
+		totalCarried += carried[p.CategoryID]
+		totalSpent += spentByCategory[p.CategoryID]
+		delete(spentByCategory, p.CategoryID)
+	}
+	for _, s := range spent {
+		if _, ok := spentByCategory[s.CategoryID]; ok {
+			report.Unbudgeted = append(report.Unbudgeted, s)
+		}
+	}
+	report.Totals = newBudgetReportLine(totalPlanned, totalCarried, totalSpent, elapsed, days, cc.Currency)
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// newBudgetReportLine derives the remaining amount, usage and burn figures for
+// a period of days of which elapsed have started. The derived amounts are
+// rounded to the decimal places of currency.
+func newBudgetReportLine(planned, carried, spent money.Amount, elapsed, days int, currency string) BudgetReportLine {
+	available := planned + carried
+	line := BudgetReportLine{
+		Planned:   planned,
+		CarriedIn: carried,
+		Available: available,
+		Spent:     spent,
+		Remaining: available - spent,
+		Projected: spent,
+	}
+	if available > 0 {
+		line.PercentUsed = math.Round(spent.Ratio(available)*10000) / 100
+	}
+	if elapsed > 0 {
+		// A day's share never exceeds the spending; a projection past the
+		// largest amount is shown as that amount, unrounded so it stays in range
+		burn, _ := spent.MulDiv(1, int64(elapsed))
+		line.DailyBurn = burn.Round(currency)
+		projected, err := spent.MulDiv(int64(days), int64(elapsed))
+		switch {
+		case err != nil && spent < 0:
+			line.Projected = money.Min
+		case err != nil:
+			line.Projected = money.Max
+		default:
+			line.Projected = projected.Round(currency)
+		}
+	}
+	return line
+}
+
+// CreateBudget sets the planned amount of a category for a month. A month
+// holds one budget per category, so when the category is already budgeted
+// its amount is replaced and the response is 200 instead of 201.
 func (h *BudgetHandler) CreateBudget(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
-		CategoryID uint    `json:"categoryId"`
-		Month      string  `json:"month"`
-		Amount     float64 `json:"amount"`
+		CategoryID uint         `json:"categoryId"`
+		Month      string       `json:"month"`
+		Amount     money.Amount `json:"amount"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	if err := checkAmount(cc.Currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	if _, err := h.categoryRepo.GetByID(cc.UserID, cc.WorkspaceID, req.CategoryID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category"})
+	}
 
 	b := &model.R_budget{
-		UserID:     cc.UserID,
-		CategoryID: req.CategoryID,
-		Month:      req.Month,
-		Amount:     req.Amount,
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		CategoryID:  req.CategoryID,
+		Month:       req.Month,
+		Amount:      req.Amount,
 	}
 
-	if err := h.budgetRepo.Create(b); err != nil {
+	var previous *model.R_budget
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if previous, err = h.budgetRepo.WithTx(tx).Upsert(b); err != nil {
+			return err
+		}
+		return auditUpsert(h.auditRepo.WithTx(tx), cc, previous, b)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create budget"})
 	}
+	if previous != nil {
+		return c.JSON(http.StatusOK, b)
+	}
 	return c.JSON(http.StatusCreated, b)
 }
 
+// auditUpsert records the outcome of BudgetRepository.Upsert.
+func auditUpsert(auditRepo *repository.AuditRepository, cc *model.CustomContext, previous, b *model.R_budget) error {
+	if previous == nil {
+		return recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditCreate, model.AuditBudget, b.ID, nil, budgetAudit(b))
+	}
+	return recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditUpdate, model.AuditBudget, b.ID, budgetAudit(previous), budgetAudit(b))
+}
+
+// budgetAudit is the audited state of a budget, without its category, which
+// is usually not loaded.
+func budgetAudit(b *model.R_budget) map[string]interface{} {
+	return map[string]interface{}{
+		"categoryId": b.CategoryID,
+		"month":      b.Month,
+		"amount":     b.Amount,
+	}
+}
+
 // CopyBudgets copies all budgets from sourceMonth to targetMonth for the current user.
+// Only planned amounts are copied: what a rollover category carries over is
+// derived from its envelope history, so copying it as well would count it
+// twice. Categories already budgeted in the target month are overwritten
+// rather than duplicated. Alert thresholds are copied to the target budgets
+// that have none.
 func (h *BudgetHandler) CopyBudgets(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
 		FromMonth string `json:"fromMonth"`
@@ -100,30 +327,142 @@ func (h *BudgetHandler) CopyBudgets(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
-	// Simple implementation: read all source, create new rows for target
-	items, err := h.budgetRepo.GetByUserAndMonth(cc.UserID, req.FromMonth)
+	if _, err := time.Parse("2006-01", req.FromMonth); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid from month"})
+	}
+	if _, err := time.Parse("2006-01", req.ToMonth); err != nil || req.ToMonth == req.FromMonth {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid to month"})
+	}
+
+	// Planned amount per category; older data may hold several rows per category
+	planned, err := h.budgetRepo.SumByCategory(cc.UserID, cc.WorkspaceID, req.FromMonth, req.FromMonth)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch budgets"})
 	}
 
-	for _, b := range items {
-		nb := model.R_budget{
-			UserID:     cc.UserID,
-			CategoryID: b.CategoryID,
-			Month:      req.ToMonth,
-			Amount:     b.Amount,
-		}
-		if err := h.budgetRepo.Create(&nb); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to copy budget"})
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		budgetRepo, auditRepo := h.budgetRepo.WithTx(tx), h.auditRepo.WithTx(tx)
+		for _, p := range planned {
+			nb := model.R_budget{
+				UserID:      cc.UserID,
+				WorkspaceID: cc.WorkspaceID,
+				CategoryID:  p.CategoryID,
+				Month:       req.ToMonth,
+				Amount:      p.Total,
+			}
+			previous, err := budgetRepo.Upsert(&nb)
+			if err != nil {
+				return err
+			}
+			if err := auditUpsert(auditRepo, cc, previous, &nb); err != nil {
+				return err
+			}
 		}
+		return h.alertRepo.WithTx(tx).CopyThresholds(cc.UserID, cc.WorkspaceID, req.FromMonth, req.ToMonth)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to copy budgets"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Budgets copied"})
 }
 
+// SetRollover turns envelope budgeting on or off for a category. While on,
+// what is left of a month's budget (or overspent) carries into the next month
+// starting from the given month, which defaults to the current one.
+func (h *BudgetHandler) SetRollover(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	catID, err := strconv.Atoi(c.Param("categoryId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category ID"})
+	}
+
+	var req struct {
+		Enabled bool   `json:"enabled"`
+		Since   string `json:"since"` // YYYY-MM
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	category, err := h.categoryRepo.GetByID(cc.UserID, cc.WorkspaceID, uint(catID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Category not found"})
+	}
+	if category.Type != "expense" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Only expense categories can roll over"})
+	}
+	before := auditSnapshot(category)
+
+	if req.Enabled {
+		since := req.Since
+		if since == "" {
+			since = time.Now().Format("2006-01")
+		}
+		if _, err := time.Parse("2006-01", since); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid month"})
+		}
+		category.RolloverSince = &since
+	} else {
+		category.RolloverSince = nil
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.categoryRepo.WithTx(tx).Update(category); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditUpdate, model.AuditCategory, category.ID, before, category)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update category"})
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+// GetEnvelope shows a rollover category's envelope month by month, from the
+// month rollover started through ?to (YYYY-MM, default the current month).
+func (h *BudgetHandler) GetEnvelope(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	catID, err := strconv.Atoi(c.Param("categoryId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category ID"})
+	}
+	category, err := h.categoryRepo.GetByID(cc.UserID, cc.WorkspaceID, uint(catID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Category not found"})
+	}
+	if category.RolloverSince == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Rollover is not enabled for this category"})
+	}
+
+	to := c.QueryParam("to")
+	if to == "" {
+		to = time.Now().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", to); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid month"})
+	}
+
+	history, err := h.budgetRepo.EnvelopeHistory(cc.UserID, cc.WorkspaceID, category.ID, *category.RolloverSince, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch envelope history"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"categoryId":    category.ID,
+		"category":      category.Name,
+		"rolloverSince": *category.RolloverSince,
+		"months":        history,
+	})
+}
+
 func (h *BudgetHandler) GetLatestBudgetMonth(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
-	month, err := h.budgetRepo.LatestMonth(cc.UserID)
+	month, err := h.budgetRepo.LatestMonth(cc.UserID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get latest budget month"})
 	}
@@ -132,6 +471,10 @@ func (h *BudgetHandler) GetLatestBudgetMonth(c echo.Context) error {
 
 func (h *BudgetHandler) DeleteBudget(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("categoryId")
 	catID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -140,10 +483,21 @@ func (h *BudgetHandler) DeleteBudget(c echo.Context) error {
 
 	month := c.QueryParam("month")
 
-	if err := h.budgetRepo.DeleteByCategory(cc.UserID, uint(catID), month); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		deleted, err := h.budgetRepo.WithTx(tx).DeleteByCategory(cc.UserID, cc.WorkspaceID, uint(catID), month)
+		if err != nil {
+			return err
+		}
+		auditRepo := h.auditRepo.WithTx(tx)
+		for i := range deleted {
+			if err := recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditDelete, model.AuditBudget, deleted[i].ID, budgetAudit(&deleted[i]), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete budget"})
 	}
 	return c.NoContent(http.StatusNoContent)
 }
-
-
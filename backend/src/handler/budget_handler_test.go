@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/testdb"
+)
+
+func TestCreateBudgetRefusesExtraDecimals(t *testing.T) {
+	h := NewBudgetHandler(nil, nil, nil, nil, nil, nil)
+	for _, body := range []string{`{"categoryId":1,"month":"2024-03","amount":12.345}`, `{"categoryId":1,"month":"2024-03","amount":"12.345"}`} {
+		rec := call(h.CreateBudget, 1, 0, http.MethodPost, "/api/apps/budgets", body)
+		var res map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+		if rec.Code != http.StatusBadRequest || res["message"] != "Amounts in IDR have at most 2 decimal places" {
+			t.Errorf("%s: %d %v", body, rec.Code, res)
+		}
+	}
+}
+
+func TestNewBudgetReportLine(t *testing.T) {
+	const huge = 50000000000000 * money.One
+	tests := []struct {
+		name                    string
+		planned, carried, spent money.Amount
+		elapsed, days           int
+		currency                string
+		want                    BudgetReportLine
+	}{
+		{
+			name: "nothing elapsed", planned: 300 * money.One, spent: 50 * money.One, days: 30, currency: "IDR",
+			want: BudgetReportLine{Planned: 300 * money.One, Available: 300 * money.One, Spent: 50 * money.One, Remaining: 250 * money.One, PercentUsed: 16.67, Projected: 50 * money.One},
+		},
+		{
+			name: "burn and projection", planned: 300 * money.One, spent: 100 * money.One, elapsed: 10, days: 30, currency: "IDR",
+			want: BudgetReportLine{Planned: 300 * money.One, Available: 300 * money.One, Spent: 100 * money.One, Remaining: 200 * money.One, PercentUsed: 33.33, DailyBurn: 10 * money.One, Projected: 300 * money.One},
+		},
+		{
+			name: "rounded to the currency", planned: 1000 * money.One, spent: 100 * money.One, elapsed: 3, days: 31, currency: "JPY",
+			want: BudgetReportLine{Planned: 1000 * money.One, Available: 1000 * money.One, Spent: 100 * money.One, Remaining: 900 * money.One, PercentUsed: 10, DailyBurn: 33 * money.One, Projected: 1033 * money.One},
+		},
+		{
+			name: "carried in", planned: 100 * money.One, carried: 50 * money.One, spent: 120 * money.One, elapsed: 15, days: 30, currency: "IDR",
+			want: BudgetReportLine{Planned: 100 * money.One, CarriedIn: 50 * money.One, Available: 150 * money.One, Spent: 120 * money.One, Remaining: 30 * money.One, PercentUsed: 80, DailyBurn: 8 * money.One, Projected: 240 * money.One},
+		},
+		{
+			name: "overspend carried in", planned: 100 * money.One, carried: -40 * money.One, spent: 90 * money.One, elapsed: 30, days: 30, currency: "IDR",
+			want: BudgetReportLine{Planned: 100 * money.One, CarriedIn: -40 * money.One, Available: 60 * money.One, Spent: 90 * money.One, Remaining: -30 * money.One, PercentUsed: 150, DailyBurn: 3 * money.One, Projected: 90 * money.One},
+		},
+		{
+			name: "nothing available", carried: -10 * money.One, spent: 5 * money.One, days: 30, currency: "IDR",
+			want: BudgetReportLine{CarriedIn: -10 * money.One, Available: -10 * money.One, Spent: 5 * money.One, Remaining: -15 * money.One, Projected: 5 * money.One},
+		},
+		{
+			name: "projection past the largest amount", spent: huge, elapsed: 1, days: 31, currency: "IDR",
+			want: BudgetReportLine{Spent: huge, Remaining: -huge, DailyBurn: huge, Projected: money.Max},
+		},
+		{
+			name: "projection past the smallest amount", spent: -huge, elapsed: 1, days: 31, currency: "IDR",
+			want: BudgetReportLine{Spent: -huge, Remaining: huge, DailyBurn: -huge, Projected: money.Min},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newBudgetReportLine(tt.planned, tt.carried, tt.spent, tt.elapsed, tt.days, tt.currency)
+			if got != tt.want {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyBudgetsLeavesRolloverToTheEnvelope(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	mustCreate(t, db, &user)
+	since := "2024-03"
+	cat := model.M_category{Name: "Food", Type: "expense", UserID: user.ID, RolloverSince: &since}
+	mustCreate(t, db, &cat)
+
+	budgetRepo := repository.NewBudgetRepository(db)
+	if _, err := budgetRepo.Upsert(&model.R_budget{UserID: user.ID, CategoryID: cat.ID, Month: "2024-03", Amount: 100 * money.One}); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, db, &model.T_expense{
+		UserID: user.ID, Date: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), Currency: "IDR",
+		Amount: 40 * money.One, OriginalAmount: 40 * money.One,
+		Splits: []model.T_expense_split{{CategoryID: cat.ID, Amount: 40 * money.One}},
+	})
+
+	h := NewBudgetHandler(db, budgetRepo, repository.NewCategoryRepository(db), repository.NewExpenseRepository(db),
+		repository.NewBudgetAlertRepository(db), repository.NewAuditRepository(db))
+	rec := call(h.CopyBudgets, user.ID, 0, http.MethodPost, "/api/apps/budgets/copy", `{"fromMonth":"2024-03","toMonth":"2024-04"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("copy: %d %s", rec.Code, rec.Body)
+	}
+	april, err := budgetRepo.GetByUserAndMonth(user.ID, 0, "2024-04")
+	if err != nil || len(april) != 1 || april[0].Amount != 100*money.One {
+		t.Fatalf("April budgets: %+v, %v; want the 100 planned for March only", april, err)
+	}
+
+	rec = call(h.GetReport, user.ID, 0, http.MethodGet, "/api/apps/budgets/report?month=2024-04", "")
+	var report BudgetReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("report: %d %s", rec.Code, rec.Body)
+	}
+	if len(report.Categories) != 1 {
+		t.Fatalf("report categories: %+v", report.Categories)
+	}
+	line := report.Categories[0]
+	if line.Planned != 100*money.One || line.CarriedIn != 60*money.One || line.Available != 160*money.One {
+		t.Errorf("April line = %+v; want 100 planned plus 60 carried in", line)
+	}
+}
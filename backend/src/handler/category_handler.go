@@ -7,26 +7,30 @@ import (
 	"expenses-tracker/src/utils"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type CategoryHandler struct {
+	db           *gorm.DB
 	categoryRepo *repository.CategoryRepository
+	auditRepo    *repository.AuditRepository
 }
 
-func NewCategoryHandler(categoryRepo *repository.CategoryRepository) *CategoryHandler {
-	return &CategoryHandler{categoryRepo: categoryRepo}
+func NewCategoryHandler(db *gorm.DB, categoryRepo *repository.CategoryRepository, auditRepo *repository.AuditRepository) *CategoryHandler {
+	return &CategoryHandler{db: db, categoryRepo: categoryRepo, auditRepo: auditRepo}
 }
 
 type CreateCategoryRequest struct {
-	Name string `json:"name" validate:"required"`
-	Type string `json:"type" validate:"required,oneof=income expense"`
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 type UpdateCategoryRequest struct {
 	Name     string `json:"name"`
-	Type     string `json:"type" validate:"omitempty,oneof=income expense"`
+	Type     string `json:"type"`
 	IsActive bool   `json:"isActive"`
 	Sequence int    `json:"sequence"`
 }
@@ -50,18 +54,25 @@ func (h *CategoryHandler) GetCategories(c echo.Context) error {
 func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req CreateCategoryRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || (req.Type != "income" && req.Type != "expense") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
 
 	// Generate slug from name
 	baseSlug := utils.GenerateSlug(req.Name)
 
 	// Check if slug exists and generate unique one if needed
 	slug := baseSlug
-	slugExists, err := h.categoryRepo.SlugExists(userID, slug, 0)
+	slugExists, err := h.categoryRepo.SlugExists(userID, cc.WorkspaceID, slug, 0)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to validate slug"})
 	}
@@ -71,7 +82,7 @@ func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 		counter := 1
 		for slugExists {
 			slug = baseSlug + "-" + strconv.Itoa(counter)
-			slugExists, err = h.categoryRepo.SlugExists(userID, slug, 0)
+			slugExists, err = h.categoryRepo.SlugExists(userID, cc.WorkspaceID, slug, 0)
 			if err != nil {
 				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to validate slug"})
 			}
@@ -88,7 +99,13 @@ func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 		IsActive:    true,
 	}
 
-	if err := h.categoryRepo.Create(&category); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.categoryRepo.WithTx(tx).Create(&category); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditCreate, model.AuditCategory, category.ID, nil, category)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create category"})
 	}
 
@@ -98,6 +115,10 @@ func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	id := c.Param("id")
 	if id == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category ID"})
@@ -113,10 +134,11 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category ID"})
 	}
 
-	category, err := h.categoryRepo.GetByID(userID, categoryID)
+	category, err := h.categoryRepo.GetByID(userID, cc.WorkspaceID, categoryID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Category not found"})
 	}
+	before := auditSnapshot(category)
 
 	if req.Name != "" && req.Name != category.Name {
 		// Generate new slug if name changed
@@ -124,7 +146,7 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 
 		// Check if slug exists (excluding current category)
 		slug := baseSlug
-		slugExists, err := h.categoryRepo.SlugExists(userID, slug, categoryID)
+		slugExists, err := h.categoryRepo.SlugExists(userID, cc.WorkspaceID, slug, categoryID)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to validate slug"})
 		}
@@ -134,7 +156,7 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 			counter := 1
 			for slugExists {
 				slug = baseSlug + "-" + strconv.Itoa(counter)
-				slugExists, err = h.categoryRepo.SlugExists(userID, slug, categoryID)
+				slugExists, err = h.categoryRepo.SlugExists(userID, cc.WorkspaceID, slug, categoryID)
 				if err != nil {
 					return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to validate slug"})
 				}
@@ -156,7 +178,13 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 		category.Sequence = req.Sequence
 	}
 
-	if err := h.categoryRepo.Update(category); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.categoryRepo.WithTx(tx).Update(category); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditUpdate, model.AuditCategory, category.ID, before, category)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update category"})
 	}
 
@@ -166,6 +194,10 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	id := c.Param("id")
 
 	categoryID, err := parseUint(id)
@@ -173,9 +205,19 @@ func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category ID"})
 	}
 
-	if err := h.categoryRepo.Delete(userID, categoryID); err != nil {
+	category, err := h.categoryRepo.GetByID(userID, cc.WorkspaceID, categoryID)
+	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Category not found"})
 	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.categoryRepo.WithTx(tx).Delete(userID, cc.WorkspaceID, categoryID); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditDelete, model.AuditCategory, category.ID, category, nil)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete category"})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Category deleted successfully"})
 }
@@ -183,6 +225,9 @@ func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 func (h *CategoryHandler) UpdateCategoriesSequence(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
 		Categories []struct {
@@ -195,15 +240,26 @@ func (h *CategoryHandler) UpdateCategoriesSequence(c echo.Context) error {
 	}
 
 	// Update each category's sequence
-	for _, catReq := range req.Categories {
-		category, err := h.categoryRepo.GetByID(userID, catReq.ID)
-		if err != nil {
-			continue // Skip if category not found
-		}
-		category.Sequence = catReq.Sequence
-		if err := h.categoryRepo.Update(category); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update category sequence"})
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		categoryRepo, auditRepo := h.categoryRepo.WithTx(tx), h.auditRepo.WithTx(tx)
+		for _, catReq := range req.Categories {
+			category, err := categoryRepo.GetByID(userID, cc.WorkspaceID, catReq.ID)
+			if err != nil {
+				continue // Skip if category not found
+			}
+			before := auditSnapshot(category)
+			category.Sequence = catReq.Sequence
+			if err := categoryRepo.Update(category); err != nil {
+				return err
+			}
+			if err := recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditUpdate, model.AuditCategory, category.ID, before, category); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update category sequence"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Categories reordered successfully"})
@@ -213,3 +269,23 @@ func parseUint(s string) (uint, error) {
 	result, err := strconv.ParseUint(s, 10, 32)
 	return uint(result), err
 }
+
+// resolveCategories loads the categories referenced by ids from the active
+// workspace. ok is false when any id is unknown, belongs to another workspace
+// or is not a category of categoryType, "income" or "expense".
+func resolveCategories(repo *repository.CategoryRepository, cc *model.CustomContext, ids []uint, categoryType string) ([]model.M_category, bool, error) {
+	unique := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	categories, err := repo.GetByIDs(cc.UserID, cc.WorkspaceID, ids)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, c := range categories {
+		if c.Type != categoryType {
+			return nil, false, nil
+		}
+	}
+	return categories, len(categories) == len(unique), nil
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ExchangeRateHandler struct {
+	rateRepo *repository.ExchangeRateRepository
+}
+
+func NewExchangeRateHandler(rateRepo *repository.ExchangeRateRepository) *ExchangeRateHandler {
+	return &ExchangeRateHandler{rateRepo: rateRepo}
+}
+
+// GetRates lists the workspace's exchange rates newest first, optionally only
+// those of ?currency.
+func (h *ExchangeRateHandler) GetRates(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.rateRepo.List(cc.UserID, cc.WorkspaceID, strings.ToUpper(c.QueryParam("currency")))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch exchange rates"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// SetRate records what one unit of a currency was worth in the base currency
+// from a day on, replacing the rate already given for that day.
+func (h *ExchangeRateHandler) SetRate(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	var req struct {
+		Currency string     `json:"currency"`
+		Date     string     `json:"date"` // YYYY-MM-DD
+		Rate     money.Rate `json:"rate"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	rate, err := newExchangeRate(cc, req.Currency, req.Date, req.Rate)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	rates := []model.M_exchange_rate{*rate}
+	err = h.rateRepo.Set(cc.UserID, cc.WorkspaceID, cc.Currency, rates)
+	if errors.Is(err, money.ErrRange) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Rate converts a transaction out of range"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save exchange rate"})
+	}
+	return c.JSON(http.StatusOK, rates[0])
+}
+
+// ImportRates reads a CSV rate file with a header naming the date, currency
+// and rate columns, in any order, and records every rate in it. Nothing is
+// saved unless every line is valid.
+func (h *ExchangeRateHandler) ImportRates(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Rate file is required"})
+	}
+	if fh.Size > maxImportFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"message": "Rate file is too large"})
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Failed to read rate file"})
+	}
+	defer f.Close()
+
+	rates, err := parseRateFile(f, cc)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	err = h.rateRepo.Set(cc.UserID, cc.WorkspaceID, cc.Currency, rates)
+	if errors.Is(err, money.ErrRange) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Rates convert a transaction out of range"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save exchange rates"})
+	}
+	return c.JSON(http.StatusOK, map[string]int{"imported": len(rates)})
+}
+
+// DeleteRate removes a rate. The transactions it converted are converted
+// again with the rate before it, and the rate stays when there is none.
+func (h *ExchangeRateHandler) DeleteRate(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+
+	err = h.rateRepo.Delete(uint(id), cc.UserID, cc.WorkspaceID, cc.Currency)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Exchange rate not found"})
+	case errors.Is(err, repository.ErrNoExchangeRate):
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Transactions depend on this rate and no earlier rate can convert them"})
+	case errors.Is(err, money.ErrRange):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "The earlier rate converts a transaction out of range"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete exchange rate"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// parseRateFile reads the rates of a CSV rate file. The first invalid line
+// fails the whole file.
+func parseRateFile(r io.Reader, cc *model.CustomContext) ([]model.M_exchange_rate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("Rate file is empty")
+	}
+	dateCol, err := resolveColumn(header, "date")
+	if err != nil {
+		return nil, err
+	}
+	currencyCol, err := resolveColumn(header, "currency")
+	if err != nil {
+		return nil, err
+	}
+	rateCol, err := resolveColumn(header, "rate")
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.M_exchange_rate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		if len(rates) >= maxImportRows {
+			return nil, fmt.Errorf("Rate files are limited to %d lines", maxImportRows)
+		}
+		field := func(col int) string {
+			if col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+
+		value, err := money.ParseRate(field(rateCol))
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: invalid rate %q", line, field(rateCol))
+		}
+		rate, err := newExchangeRate(cc, field(currencyCol), field(dateCol), value)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		rates = append(rates, *rate)
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("Rate file has no rates")
+	}
+	return rates, nil
+}
+
+// newExchangeRate validates a rate of currency into the caller's base
+// currency dated date, a YYYY-MM-DD string.
+func newExchangeRate(cc *model.CustomContext, currency, date string, rate money.Rate) (*model.M_exchange_rate, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !money.IsCurrencyCode(currency) {
+		return nil, fmt.Errorf("Invalid currency %q", currency)
+	}
+	if currency == cc.Currency {
+		return nil, fmt.Errorf("%s is the base currency and needs no rate", currency)
+	}
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid date %q", date)
+	}
+	if rate <= 0 {
+		return nil, errors.New("Rate must be positive")
+	}
+	return &model.M_exchange_rate{Currency: currency, Date: d, Rate: rate}, nil
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
@@ -9,6 +11,8 @@ import (
 
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/notify"
 	"expenses-tracker/src/repository"
 
 	"github.com/labstack/echo/v4"
@@ -19,24 +23,45 @@ type ExpenseHandler struct {
 	db           *gorm.DB
 	expenseRepo  *repository.ExpenseRepository
 	categoryRepo *repository.CategoryRepository
+	accountRepo  *repository.AccountRepository
+	rateRepo     *repository.ExchangeRateRepository
+	alerter      *notify.BudgetAlerter
+	auditRepo    *repository.AuditRepository
 }
 
-func NewExpenseHandler(db *gorm.DB, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository) *ExpenseHandler {
+func NewExpenseHandler(db *gorm.DB, expenseRepo *repository.ExpenseRepository, categoryRepo *repository.CategoryRepository, accountRepo *repository.AccountRepository, rateRepo *repository.ExchangeRateRepository, alerter *notify.BudgetAlerter, auditRepo *repository.AuditRepository) *ExpenseHandler {
 	return &ExpenseHandler{
 		db:           db,
 		expenseRepo:  expenseRepo,
 		categoryRepo: categoryRepo,
+		accountRepo:  accountRepo,
+		rateRepo:     rateRepo,
+		alerter:      alerter,
+		auditRepo:    auditRepo,
 	}
 }
 
+// ExpenseSplitRequest assigns part of an expense amount to one category. Split
+// amounts are in the currency of the expense.
+type ExpenseSplitRequest struct {
+	CategoryID uint         `json:"categoryId"`
+	Amount     money.Amount `json:"amount"`
+}
+
 func (h *ExpenseHandler) CreateExpense(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
-		CategoryIDs []uint  `json:"categoryIds"`
-		Date        string  `json:"date"` // YYYY-MM-DD
-		Notes       string  `json:"notes"`
-		Amount      float64 `json:"amount"`
+		CategoryIDs []uint                `json:"categoryIds"`
+		Splits      []ExpenseSplitRequest `json:"splits"`
+		AccountID   *uint                 `json:"accountId"` // optional, see resolveAccount
+		Date        string                `json:"date"`      // YYYY-MM-DD
+		Notes       string                `json:"notes"`
+		Amount      money.Amount          `json:"amount"`
+		Currency    string                `json:"currency"` // defaults to the workspace's base currency
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
@@ -47,39 +72,64 @@ func (h *ExpenseHandler) CreateExpense(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date"})
 	}
 
-	if len(req.CategoryIDs) == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "At least one category is required"})
+	currency, err := transactionCurrency(cc, req.Currency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 	}
-
-	cats := make([]model.M_category, len(req.CategoryIDs))
-	for i, id := range req.CategoryIDs {
-		cats[i] = model.M_category{ID: id}
+	if err := checkAmount(currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 	}
-
-	exp := &model.T_expense{
-		UserID:      cc.UserID,
-		WorkspaceID: cc.WorkspaceID,
-		Categories:  cats,
-		Date:        d,
-		Notes:       req.Notes,
-		Amount:      req.Amount,
+	splits, status, err := h.buildSplits(cc, currency, req.Amount, req.CategoryIDs, req.Splits)
+	if err != nil {
+		return c.JSON(status, map[string]string{"message": err.Error()})
+	}
+	amount, status, err := convertAmount(h.rateRepo, cc, req.Amount, currency, d)
+	if err != nil {
+		return c.JSON(status, map[string]string{"message": err.Error()})
+	}
+	if err := repository.RescaleSplits(splits, req.Amount, amount, cc.Currency); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Converted amount is out of range"})
+	}
+	accountID, err := resolveAccount(h.accountRepo, cc, req.AccountID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 	}
 
-	if err := h.expenseRepo.Create(exp); err != nil {
+	exp := &model.T_expense{
+		UserID:         cc.UserID,
+		WorkspaceID:    cc.WorkspaceID,
+		Splits:         splits,
+		AccountID:      accountID,
+		Date:           d,
+		Notes:          req.Notes,
+		Amount:         amount,
+		Currency:       currency,
+		OriginalAmount: req.Amount,
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.expenseRepo.WithTx(tx).Create(exp); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditCreate, model.AuditExpense, exp.ID, nil, exp)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create expense"})
 	}
+	h.alerter.Check(cc.UserID, cc.WorkspaceID, cc.Currency, exp.Date)
 	return c.JSON(http.StatusCreated, exp)
 }
 
 type DateTotal struct {
-	Date       string  `json:"date"`
-	Total      float64 `json:"total"`
-	HasIncome  bool    `json:"hasIncome,omitempty"`
-	HasExpense bool    `json:"hasExpense,omitempty"`
+	Date       string       `json:"date"`
+	Total      money.Amount `json:"total"`
+	HasIncome  bool         `json:"hasIncome,omitempty"`
+	HasExpense bool         `json:"hasExpense,omitempty"`
 }
 
 func (h *ExpenseHandler) GetMonths(c echo.Context) error {
-	userID := middleware.GetCustomContext(c).UserID
+	cc := middleware.GetCustomContext(c)
+	scope := repository.WorkspaceScope(cc.UserID, cc.WorkspaceID)
 
 	now := time.Now()
 	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
@@ -108,14 +158,15 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 	}
 
 	type MonthResult struct {
-		Month string  `json:"month"`
-		Total float64 `json:"total"`
+		Month string       `json:"month"`
+		Total money.Amount `json:"total"`
 	}
 
 	var results []MonthResult
 	err := h.db.Model(&model.T_expense{}).
 		Select("TO_CHAR(date, 'YYYY-MM') as month, COALESCE(SUM(amount), 0) as total").
-		Where("user_id = ? AND date >= ? AND date < ?", userID, startMonth, endMonth.AddDate(0, 1, 0)).
+		Scopes(scope).
+		Where("date >= ? AND date < ?", startMonth, endMonth.AddDate(0, 1, 0)).
 		Group("TO_CHAR(date, 'YYYY-MM')").
 		Order("month DESC").
 		Scan(&results).Error
@@ -126,7 +177,7 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 
 	// Generate all months in range (including empty ones)
 	allMonths := make(map[string]bool)
-	monthMap := make(map[string]float64)
+	monthMap := make(map[string]money.Amount)
 	for _, result := range results {
 		allMonths[result.Month] = true
 		monthMap[result.Month] = result.Total
@@ -144,10 +195,10 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 	}
 
 	type MonthWithDates struct {
-		Month    string      `json:"month"`
-		Total    float64     `json:"total"`    // total expenses for the month
-		NetTotal float64     `json:"netTotal"` // income - expenses for the month
-		Dates    []DateTotal `json:"dates"`
+		Month    string       `json:"month"`
+		Total    money.Amount `json:"total"`    // total expenses for the month
+		NetTotal money.Amount `json:"netTotal"` // income - expenses for the month
+		Dates    []DateTotal  `json:"dates"`
 	}
 
 	// Build response with all months (in descending order)
@@ -157,39 +208,41 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 		monthKey := iterMonth.Format("2006-01")
 
 		type DateResult struct {
-			Date  time.Time `json:"date"`
-			Total float64   `json:"total"`
+			Date  time.Time    `json:"date"`
+			Total money.Amount `json:"total"`
 		}
 		var dateResults []DateResult
 		h.db.Model(&model.T_expense{}).
 			Select("date, COALESCE(SUM(amount), 0) as total").
-			Where("user_id = ? AND TO_CHAR(date, 'YYYY-MM') = ?", userID, monthKey).
+			Scopes(scope).
+			Where("TO_CHAR(date, 'YYYY-MM') = ?", monthKey).
 			Group("date").
 			Order("date DESC").
 			Scan(&dateResults)
 
 		// Get all unique dates (from expenses and income) for this month
 		dateMap := make(map[time.Time]struct {
-			Expense float64
-			Income  float64
+			Expense money.Amount
+			Income  money.Amount
 		})
 
 		// Add expenses to date map
 		for _, dr := range dateResults {
 			dateMap[dr.Date] = struct {
-				Expense float64
-				Income  float64
+				Expense money.Amount
+				Income  money.Amount
 			}{Expense: dr.Total, Income: 0}
 		}
 
 		// Get income for this month
 		var incomeResults []struct {
-			Date  time.Time `json:"date"`
-			Total float64   `json:"total"`
+			Date  time.Time    `json:"date"`
+			Total money.Amount `json:"total"`
 		}
 		h.db.Model(&model.T_income{}).
 			Select("date, COALESCE(SUM(amount), 0) as total").
-			Where("user_id = ? AND TO_CHAR(date, 'YYYY-MM') = ?", userID, monthKey).
+			Scopes(scope).
+			Where("TO_CHAR(date, 'YYYY-MM') = ?", monthKey).
 			Group("date").
 			Scan(&incomeResults)
 
@@ -200,18 +253,18 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 				dateMap[ir.Date] = existing
 			} else {
 				dateMap[ir.Date] = struct {
-					Expense float64
-					Income  float64
+					Expense money.Amount
+					Income  money.Amount
 				}{Expense: 0, Income: ir.Total}
 			}
 		}
 
 		// Convert to DateTotal slice with net total (income - expense) and type info
 		type DateTotalWithType struct {
-			Date       string  `json:"date"`
-			Total      float64 `json:"total"`
-			HasIncome  bool    `json:"hasIncome"`
-			HasExpense bool    `json:"hasExpense"`
+			Date       string       `json:"date"`
+			Total      money.Amount `json:"total"`
+			HasIncome  bool         `json:"hasIncome"`
+			HasExpense bool         `json:"hasExpense"`
 		}
 		dateTotalsWithType := make([]DateTotalWithType, 0, len(dateMap))
 		for date, amounts := range dateMap {
@@ -234,7 +287,7 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 		}
 
 		// Convert to DateTotal format with income/expense flags and compute month net total
-		monthNetTotal := 0.0
+		var monthNetTotal money.Amount
 		dateTotals := make([]DateTotal, len(dateTotalsWithType))
 		for j, dt := range dateTotalsWithType {
 			monthNetTotal += dt.Total
@@ -268,13 +321,18 @@ func (h *ExpenseHandler) GetMonths(c echo.Context) error {
 func (h *ExpenseHandler) GetMonthDetails(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	month := c.Param("month") // YYYY-MM
-	userID := cc.UserID
+	scope := repository.WorkspaceScope(cc.UserID, cc.WorkspaceID)
 
-	// Load all expenses for this month with categories
+	monthStart, err := time.Parse("2006-01", month)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid month"})
+	}
+
+	// Load all expenses for this month
 	var expenses []model.T_expense
 	if err := h.db.
-		Preload("Categories").
-		Where("user_id = ? AND TO_CHAR(date, 'YYYY-MM') = ?", userID, month).
+		Scopes(scope).
+		Where("TO_CHAR(date, 'YYYY-MM') = ?", month).
 		Find(&expenses).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch expenses"})
 	}
@@ -282,45 +340,24 @@ func (h *ExpenseHandler) GetMonthDetails(c echo.Context) error {
 	// Load all incomes for this month
 	var incomes []model.T_income
 	if err := h.db.
-		Where("user_id = ? AND TO_CHAR(date, 'YYYY-MM') = ?", userID, month).
+		Scopes(scope).
+		Where("TO_CHAR(date, 'YYYY-MM') = ?", month).
 		Find(&incomes).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch income"})
 	}
 
-	// Aggregate by category
-	type CategorySummary struct {
-		CategoryID uint    `json:"categoryId"`
-		Category   string  `json:"category"`
-		Total      float64 `json:"total"`
-	}
-	categoryMap := make(map[uint]*CategorySummary)
-
-	for _, e := range expenses {
-		for _, cat := range e.Categories {
-			cs, ok := categoryMap[cat.ID]
-			if !ok {
-				cs = &CategorySummary{
-					CategoryID: cat.ID,
-					Category:   cat.Name,
-					Total:      0,
-				}
-				categoryMap[cat.ID] = cs
-			}
-			cs.Total += e.Amount
-		}
-	}
-
-	categories := make([]CategorySummary, 0, len(categoryMap))
-	for _, cs := range categoryMap {
-		categories = append(categories, *cs)
+	// Aggregate by category using each expense's split amounts
+	categories, err := h.expenseRepo.SumByCategory(cc.UserID, cc.WorkspaceID, monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch expenses"})
 	}
 
 	// Aggregate by day for income/expenses
 	type DailySummary struct {
-		Date     string  `json:"date"`
-		Income   float64 `json:"income"`
-		Expense  float64 `json:"expense"`
-		NetTotal float64 `json:"netTotal"`
+		Date     string       `json:"date"`
+		Income   money.Amount `json:"income"`
+		Expense  money.Amount `json:"expense"`
+		NetTotal money.Amount `json:"netTotal"`
 	}
 	dailyMap := make(map[string]*DailySummary)
 
@@ -374,26 +411,35 @@ func (h *ExpenseHandler) GetDateExpenses(c echo.Context) error {
 	}
 
 	// Shape for DateExpensesModal: categories as []string and categoryIds for editing
+	type splitItem struct {
+		CategoryID uint         `json:"categoryId"`
+		Category   string       `json:"category"`
+		Amount     money.Amount `json:"amount"`
+	}
 	type respItem struct {
-		ID          uint      `json:"id"`
-		Categories  []string  `json:"categories"`
-		CategoryIDs []uint    `json:"categoryIds"`
-		Date        time.Time `json:"date"`
-		Notes       string    `json:"notes"`
-		Amount      float64   `json:"amount"`
+		ID          uint         `json:"id"`
+		Categories  []string     `json:"categories"`
+		CategoryIDs []uint       `json:"categoryIds"`
+		Splits      []splitItem  `json:"splits"`
+		Date        time.Time    `json:"date"`
+		Notes       string       `json:"notes"`
+		Amount      money.Amount `json:"amount"`
 	}
 	out := make([]respItem, 0, len(items))
 	for _, e := range items {
-		names := make([]string, 0, len(e.Categories))
-		ids := make([]uint, 0, len(e.Categories))
-		for _, c := range e.Categories {
-			names = append(names, c.Name)
-			ids = append(ids, c.ID)
+		names := make([]string, 0, len(e.Splits))
+		ids := make([]uint, 0, len(e.Splits))
+		splits := make([]splitItem, 0, len(e.Splits))
+		for _, s := range e.Splits {
+			names = append(names, s.Category.Name)
+			ids = append(ids, s.CategoryID)
+			splits = append(splits, splitItem{CategoryID: s.CategoryID, Category: s.Category.Name, Amount: s.Amount})
 		}
 		out = append(out, respItem{
 			ID:          e.ID,
 			Categories:  names,
 			CategoryIDs: ids,
+			Splits:      splits,
 			Date:        e.Date,
 			Notes:       e.Notes,
 			Amount:      e.Amount,
@@ -428,34 +474,42 @@ func (h *ExpenseHandler) SearchExpenses(c echo.Context) error {
 		}
 	}
 
-	items, err := h.expenseRepo.Search(cc.UserID, q, categoryID, dateFrom, dateTo)
+	items, err := h.expenseRepo.Search(cc.UserID, cc.WorkspaceID, q, categoryID, dateFrom, dateTo)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to search expenses"})
 	}
 
-	// Shape response to what the frontend expects in ExpensesHistory.svelte
+	// Shape response to what the frontend expects in ExpensesHistory.svelte.
+	// When filtering by category, categoryAmount is that category's share.
 	type respItem struct {
-		Date     time.Time `json:"date"`
-		Category string    `json:"category"`
-		Notes    string    `json:"notes"`
-		Amount   float64   `json:"amount"`
+		Date           time.Time     `json:"date"`
+		Category       string        `json:"category"`
+		Notes          string        `json:"notes"`
+		Amount         money.Amount  `json:"amount"`
+		CategoryAmount *money.Amount `json:"categoryAmount,omitempty"`
 	}
 	out := make([]respItem, 0, len(items))
 	for _, e := range items {
 		// For multi-category expenses, join category names with comma
 		categoryName := ""
-		if len(e.Categories) > 0 {
-			names := make([]string, 0, len(e.Categories))
-			for _, c := range e.Categories {
-				names = append(names, c.Name)
+		var categoryAmount *money.Amount
+		if len(e.Splits) > 0 {
+			names := make([]string, 0, len(e.Splits))
+			for _, s := range e.Splits {
+				names = append(names, s.Category.Name)
+				if categoryID != nil && s.CategoryID == *categoryID {
+					share := s.Amount
+					categoryAmount = &share
+				}
 			}
 			categoryName = strings.Join(names, ", ")
 		}
 		out = append(out, respItem{
-			Date:     e.Date,
-			Category: categoryName,
-			Notes:    e.Notes,
-			Amount:   e.Amount,
+			Date:           e.Date,
+			Category:       categoryName,
+			Notes:          e.Notes,
+			Amount:         e.Amount,
+			CategoryAmount: categoryAmount,
 		})
 	}
 
@@ -464,66 +518,226 @@ func (h *ExpenseHandler) SearchExpenses(c echo.Context) error {
 
 func (h *ExpenseHandler) UpdateExpense(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
 	}
 
-	exp, err := h.expenseRepo.GetByID(uint(id), cc.UserID)
+	exp, err := h.expenseRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Expense not found"})
 	}
+	oldDate := exp.Date
+	before := auditSnapshot(exp)
 
 	var req struct {
-		CategoryIDs *[]uint  `json:"categoryIds"`
-		Date        *string  `json:"date"`
-		Notes       *string  `json:"notes"`
-		Amount      *float64 `json:"amount"`
+		CategoryIDs *[]uint                `json:"categoryIds"`
+		Splits      *[]ExpenseSplitRequest `json:"splits"`
+		AccountID   *uint                  `json:"accountId"` // 0 detaches the account
+		Date        *string                `json:"date"`
+		Notes       *string                `json:"notes"`
+		Amount      *money.Amount          `json:"amount"`
+		Currency    *string                `json:"currency"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
-	if req.CategoryIDs != nil {
-		ids := *req.CategoryIDs
-		cats := make([]model.M_category, len(ids))
-		for i, id := range ids {
-			cats[i] = model.M_category{ID: id}
+	date := exp.Date
+	if req.Date != nil {
+		if d, err := time.Parse("2006-01-02", *req.Date); err == nil {
+			date = d
 		}
-		// Replace categories via repository helper
-		if err := h.expenseRepo.ReplaceCategories(exp, cats); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update categories"})
+	}
+	currency := exp.Currency
+	if req.Currency != nil {
+		if currency, err = transactionCurrency(cc, *req.Currency); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 		}
 	}
-	if req.Date != nil {
-		if d, err := time.Parse("2006-01-02", *req.Date); err == nil {
-			exp.Date = d
+	original := exp.OriginalAmount
+	if req.Amount != nil {
+		original = *req.Amount
+	}
+	if err := checkAmount(currency, original); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	// The amount is converted again when anything it depends on changes
+	amount := exp.Amount
+	if req.Amount != nil || req.Currency != nil || req.Date != nil {
+		var status int
+		if amount, status, err = convertAmount(h.rateRepo, cc, original, currency, date); err != nil {
+			return c.JSON(status, map[string]string{"message": err.Error()})
 		}
 	}
+
+	switch {
+	case req.Splits != nil || req.CategoryIDs != nil:
+		var ids []uint
+		if req.CategoryIDs != nil {
+			ids = *req.CategoryIDs
+		}
+		var reqSplits []ExpenseSplitRequest
+		if req.Splits != nil {
+			reqSplits = *req.Splits
+		}
+		splits, status, err := h.buildSplits(cc, currency, original, ids, reqSplits)
+		if err != nil {
+			return c.JSON(status, map[string]string{"message": err.Error()})
+		}
+		if err := repository.RescaleSplits(splits, original, amount, cc.Currency); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Converted amount is out of range"})
+		}
+		exp.Splits = splits
+	case len(exp.Splits) == 1:
+		// A single category always carries the whole amount
+		exp.Splits[0].Amount = amount
+	case req.Amount == nil:
+		// Only the rate changed, so the splits keep their shares
+		if err := repository.RescaleSplits(exp.Splits, exp.Amount, amount, cc.Currency); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Converted amount is out of range"})
+		}
+	case !repository.SplitsMatchAmount(exp.Splits, amount):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Split amounts must add up to the expense amount"})
+	}
+	if req.AccountID != nil {
+		accountID, err := resolveAccount(h.accountRepo, cc, req.AccountID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+		exp.AccountID = accountID
+	}
 	if req.Notes != nil {
 		exp.Notes = *req.Notes
 	}
-	if req.Amount != nil {
-		exp.Amount = *req.Amount
-	}
+	exp.Date = date
+	exp.Amount = amount
+	exp.Currency = currency
+	exp.OriginalAmount = original
 
-	if err := h.expenseRepo.Update(exp); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.expenseRepo.WithTx(tx).Update(exp); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditUpdate, model.AuditExpense, exp.ID, before, exp)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update expense"})
 	}
+	h.alerter.Check(cc.UserID, cc.WorkspaceID, cc.Currency, oldDate, exp.Date)
 	return c.JSON(http.StatusOK, exp)
 }
 
 func (h *ExpenseHandler) DeleteExpense(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
 	}
 
-	if err := h.expenseRepo.Delete(uint(id), cc.UserID); err != nil {
+	exp, err := h.expenseRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.expenseRepo.WithTx(tx).Delete(uint(id), cc.UserID, cc.WorkspaceID); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditDelete, model.AuditExpense, exp.ID, exp, nil)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete expense"})
 	}
+	h.alerter.Check(cc.UserID, cc.WorkspaceID, cc.Currency, exp.Date)
 	return c.NoContent(http.StatusNoContent)
 }
+
+// buildSplits turns explicit split amounts, or failing that a list of category
+// IDs, into the category splits of an expense of the given amount in currency.
+// Categories given without amounts share the expense evenly. On failure it
+// returns the status to answer with and an error whose message is the reply.
+func (h *ExpenseHandler) buildSplits(cc *model.CustomContext, currency string, amount money.Amount, categoryIDs []uint, reqSplits []ExpenseSplitRequest) ([]model.T_expense_split, int, error) {
+	var splits []model.T_expense_split
+	if len(reqSplits) > 0 {
+		categoryIDs = make([]uint, 0, len(reqSplits))
+		seen := make(map[uint]bool, len(reqSplits))
+		for _, s := range reqSplits {
+			if seen[s.CategoryID] {
+				return nil, http.StatusBadRequest, errors.New("Each category can only appear once in splits")
+			}
+			seen[s.CategoryID] = true
+			if err := checkAmount(currency, s.Amount); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			categoryIDs = append(categoryIDs, s.CategoryID)
+			splits = append(splits, model.T_expense_split{CategoryID: s.CategoryID, Amount: s.Amount})
+		}
+		if !repository.SplitsMatchAmount(splits, amount) {
+			return nil, http.StatusBadRequest, errors.New("Split amounts must add up to the expense amount")
+		}
+	} else {
+		splits = repository.SplitEvenly(amount, currency, categoryIDs)
+	}
+
+	if len(splits) == 0 {
+		return nil, http.StatusBadRequest, errors.New("At least one category is required")
+	}
+
+	_, ok, err := resolveCategories(h.categoryRepo, cc, categoryIDs, "expense")
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to load categories")
+	}
+	if !ok {
+		return nil, http.StatusBadRequest, errors.New("Invalid category")
+	}
+	return splits, 0, nil
+}
+
+// checkAmount refuses an amount with more decimal places than currency has,
+// such as cents of a yen. The error is the message for the client.
+func checkAmount(currency string, amount money.Amount) error {
+	if !amount.Fits(currency) {
+		return fmt.Errorf("Amounts in %s have at most %d decimal places", currency, money.Decimals(currency))
+	}
+	return nil
+}
+
+// transactionCurrency returns the ISO 4217 code a transaction is recorded in:
+// code itself, or the workspace's base currency when code is empty. The error
+// is the message for the client.
+func transactionCurrency(cc *model.CustomContext, code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return cc.Currency, nil
+	}
+	if !money.IsCurrencyCode(code) {
+		return "", errors.New("Invalid currency")
+	}
+	return code, nil
+}
+
+// convertAmount converts an amount in currency into the workspace's base
+// currency at the rate on date. On failure it returns the HTTP status and the
+// message for the client.
+func convertAmount(rateRepo *repository.ExchangeRateRepository, cc *model.CustomContext, amount money.Amount, currency string, date time.Time) (money.Amount, int, error) {
+	converted, err := rateRepo.Convert(cc.UserID, cc.WorkspaceID, amount, currency, cc.Currency, date)
+	switch {
+	case errors.Is(err, repository.ErrNoExchangeRate):
+		return 0, http.StatusBadRequest, fmt.Errorf("No exchange rate from %s to %s on or before %s", currency, cc.Currency, date.Format("2006-01-02"))
+	case errors.Is(err, money.ErrRange):
+		return 0, http.StatusBadRequest, errors.New("Converted amount is out of range")
+	case err != nil:
+		return 0, http.StatusInternalServerError, errors.New("Failed to convert amount")
+	}
+	return converted, http.StatusOK, nil
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/testdb"
+)
+
+func TestCategoriesMatchTransactionType(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	mustCreate(t, db, &user)
+	food := model.M_category{Name: "Food", Type: "expense", UserID: user.ID}
+	salary := model.M_category{Name: "Salary", Type: "income", UserID: user.ID}
+	mustCreate(t, db, &food, &salary)
+
+	categoryRepo := repository.NewCategoryRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+	rateRepo := repository.NewExchangeRateRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	dispatcher := notify.NewDispatcher(notify.NewInboxNotifier(notificationRepo), nil, notificationRepo, repository.NewWorkspaceRepository(db))
+	alerter := notify.NewBudgetAlerter(repository.NewBudgetAlertRepository(db), dispatcher)
+	expenses := NewExpenseHandler(db, repository.NewExpenseRepository(db), categoryRepo, accountRepo, rateRepo, alerter, auditRepo)
+	incomes := NewIncomeHandler(db, repository.NewIncomeRepository(db), categoryRepo, accountRepo, rateRepo, auditRepo)
+	recurring := NewRecurringHandler(repository.NewRecurringRepository(db), categoryRepo, accountRepo)
+
+	cases := []struct {
+		name   string
+		create func(body string) int
+		body   string
+		want   int
+	}{
+		{"expense in an income category", func(b string) int {
+			return call(expenses.CreateExpense, user.ID, 0, http.MethodPost, "/api/apps/expenses", b).Code
+		}, fmt.Sprintf(`{"date":"2026-03-01","amount":"10","categoryIds":[%d]}`, salary.ID), http.StatusBadRequest},
+		{"expense split into an income category", func(b string) int {
+			return call(expenses.CreateExpense, user.ID, 0, http.MethodPost, "/api/apps/expenses", b).Code
+		}, fmt.Sprintf(`{"date":"2026-03-01","amount":"10","splits":[{"categoryId":%d,"amount":"6"},{"categoryId":%d,"amount":"4"}]}`, food.ID, salary.ID), http.StatusBadRequest},
+		{"income in an expense category", func(b string) int {
+			return call(incomes.CreateIncome, user.ID, 0, http.MethodPost, "/api/apps/income", b).Code
+		}, fmt.Sprintf(`{"date":"2026-03-01","amount":"10","categoryIds":[%d]}`, food.ID), http.StatusBadRequest},
+		{"recurring expense in an income category", func(b string) int {
+			return call(recurring.CreateRule, user.ID, 0, http.MethodPost, "/api/apps/recurring", b).Code
+		}, fmt.Sprintf(`{"type":"expense","amount":"10","frequency":"monthly","interval":1,"startDate":"2026-03-01","categoryIds":[%d]}`, salary.ID), http.StatusBadRequest},
+		{"expense in an expense category", func(b string) int {
+			return call(expenses.CreateExpense, user.ID, 0, http.MethodPost, "/api/apps/expenses", b).Code
+		}, fmt.Sprintf(`{"date":"2026-03-01","amount":"10","categoryIds":[%d]}`, food.ID), http.StatusCreated},
+		{"recurring income in an income category", func(b string) int {
+			return call(recurring.CreateRule, user.ID, 0, http.MethodPost, "/api/apps/recurring", b).Code
+		}, fmt.Sprintf(`{"type":"income","amount":"10","frequency":"monthly","interval":1,"startDate":"2026-03-01","categoryIds":[%d]}`, salary.ID), http.StatusCreated},
+	}
+	for _, c := range cases {
+		if got := c.create(c.body); got != c.want {
+			t.Errorf("%s: %d, want %d", c.name, got, c.want)
+		}
+	}
+}
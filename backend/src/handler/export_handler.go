@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+	ExportXLSX = "xlsx"
+)
+
+// exportFlushEvery is how many rows are written between flushes to the client.
+const exportFlushEvery = 200
+
+// exportColumns lists the columns of every exportable dataset, in order.
+var exportColumns = map[string][]string{
+	"expenses":   {"id", "date", "amount", "currency", "originalAmount", "notes", "account", "categories", "categorySlugs", "splitAmounts"},
+	"income":     {"id", "date", "amount", "currency", "originalAmount", "notes", "account", "categories", "categorySlugs"},
+	"budgets":    {"month", "categoryId", "category", "categorySlug", "amount"},
+	"categories": {"id", "name", "slug", "type", "isActive", "sequence"},
+}
+
+var exportContentTypes = map[string]string{
+	ExportCSV:  "text/csv; charset=utf-8",
+	ExportJSON: echo.MIMEApplicationJSONCharsetUTF8,
+	ExportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type ExportHandler struct {
+	exportRepo *repository.ExportRepository
+}
+
+func NewExportHandler(exportRepo *repository.ExportRepository) *ExportHandler {
+	return &ExportHandler{exportRepo: exportRepo}
+}
+
+// Export streams one dataset of the active workspace as a file download.
+// Query params: format (csv, json or xlsx; default csv) and optional from/to
+// dates (YYYY-MM-DD, inclusive). Budgets are filtered by month, categories are
+// always exported whole.
+func (h *ExportHandler) Export(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	dataset := c.Param("dataset")
+	columns, ok := exportColumns[dataset]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid dataset"})
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid format"})
+	}
+
+	var from, to *time.Time
+	if v := c.QueryParam("from"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid from date"})
+		}
+		from = &d
+	}
+	if v := c.QueryParam("to"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid to date"})
+		}
+		to = &d
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date range"})
+	}
+
+	// The writer holds its output until the first flush, so a failure to set
+	// it up can still be answered with an error status
+	res := c.Response()
+	w, err := newExportWriter(format, res, dataset, columns)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to export data"})
+	}
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", exportFilename(dataset, format, from, to)))
+	res.WriteHeader(http.StatusOK)
+
+	if err := h.stream(cc, dataset, from, to, w); err != nil {
+		// The response is already committed; echo only logs this error and
+		// the client sees a truncated file
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+func (h *ExportHandler) stream(cc *model.CustomContext, dataset string, from, to *time.Time, w exportWriter) error {
+	switch dataset {
+	case "expenses":
+		return h.exportRepo.EachExpense(cc.UserID, cc.WorkspaceID, from, to, func(r *repository.ExpenseExportRow) error {
+			return w.Write([]interface{}{r.ID, r.Date, r.Amount, r.Currency, r.OriginalAmount, r.Notes, r.Account, r.Categories, r.CategorySlugs, r.SplitAmounts})
+		})
+	case "income":
+		return h.exportRepo.EachIncome(cc.UserID, cc.WorkspaceID, from, to, func(r *repository.IncomeExportRow) error {
+			return w.Write([]interface{}{r.ID, r.Date, r.Amount, r.Currency, r.OriginalAmount, r.Notes, r.Account, r.Categories, r.CategorySlugs})
+		})
+	case "budgets":
+		return h.exportRepo.EachBudget(cc.UserID, cc.WorkspaceID, from, to, func(r *repository.BudgetExportRow) error {
+			return w.Write([]interface{}{r.Month, r.CategoryID, r.Category, r.CategorySlug, r.Amount})
+		})
+	default:
+		return h.exportRepo.EachCategory(cc.UserID, cc.WorkspaceID, func(r *repository.CategoryExportRow) error {
+			return w.Write([]interface{}{r.ID, r.Name, r.Slug, r.Type, r.IsActive, r.Sequence})
+		})
+	}
+}
+
+func exportFilename(dataset, format string, from, to *time.Time) string {
+	name := dataset
+	if from != nil {
+		name += "_from_" + from.Format("2006-01-02")
+	}
+	if to != nil {
+		name += "_to_" + to.Format("2006-01-02")
+	}
+	return name + "." + format
+}
+
+// exportWriter encodes rows for one export format. Creating one writes
+// nothing to the response. Write receives the values in the dataset's column
+// order, Close completes the file and Abort releases its resources without
+// completing it.
+type exportWriter interface {
+	Write(values []interface{}) error
+	Close() error
+	Abort()
+}
+
+func newExportWriter(format string, res *echo.Response, sheet string, columns []string) (exportWriter, error) {
+	switch format {
+	case ExportJSON:
+		return newJSONExportWriter(res, columns)
+	case ExportXLSX:
+		return newXLSXExportWriter(res, sheet, columns)
+	default:
+		return newCSVExportWriter(res, columns)
+	}
+}
+
+// formatExportValue renders a value as text for CSV cells.
+func formatExportValue(v interface{}) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format("2006-01-02")
+	case money.Amount:
+		return t.String()
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+type csvExportWriter struct {
+	res    *echo.Response
+	csv    *csv.Writer
+	record []string
+	rows   int
+}
+
+func newCSVExportWriter(res *echo.Response, columns []string) (*csvExportWriter, error) {
+	w := &csvExportWriter{res: res, csv: csv.NewWriter(res), record: make([]string, len(columns))}
+	if err := w.csv.Write(columns); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *csvExportWriter) Write(values []interface{}) error {
+	for i, v := range values {
+		w.record[i] = formatExportValue(v)
+	}
+	if err := w.csv.Write(w.record); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportFlushEvery == 0 {
+		w.csv.Flush()
+		w.res.Flush()
+	}
+	return w.csv.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvExportWriter) Abort() {}
+
+// jsonExportWriter writes a JSON array of objects whose keys keep the column order.
+type jsonExportWriter struct {
+	res  *echo.Response
+	keys [][]byte
+	rows int
+}
+
+func newJSONExportWriter(res *echo.Response, columns []string) (*jsonExportWriter, error) {
+	keys := make([][]byte, len(columns))
+	for i, col := range columns {
+		b, _ := json.Marshal(col)
+		keys[i] = append(b, ':')
+	}
+	return &jsonExportWriter{res: res, keys: keys}, nil
+}
+
+func (w *jsonExportWriter) Write(values []interface{}) error {
+	buf := make([]byte, 0, 256)
+	if w.rows == 0 {
+		buf = append(buf, '[')
+	} else {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, '\n', '{')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.Format("2006-01-02")
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, w.keys[i]...)
+		buf = append(buf, b...)
+	}
+	buf = append(buf, '}')
+	if _, err := w.res.Write(buf); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportFlushEvery == 0 {
+		w.res.Flush()
+	}
+	return nil
+}
+
+func (w *jsonExportWriter) Close() error {
+	end := "\n]\n"
+	if w.rows == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.res, end)
+	return err
+}
+
+func (w *jsonExportWriter) Abort() {}
+
+// xlsxExportWriter uses excelize's stream writer, which spills rows to a
+// temporary file instead of keeping the whole sheet in memory. The workbook
+// is sent once complete.
+type xlsxExportWriter struct {
+	res       *echo.Response
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	dateStyle int
+	row       int
+}
+
+func newXLSXExportWriter(res *echo.Response, sheet string, columns []string) (*xlsxExportWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+	stream, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	dateFormat := "yyyy-mm-dd"
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxExportWriter{res: res, file: f, stream: stream, dateStyle: dateStyle, row: 1}, nil
+}
+
+func (w *xlsxExportWriter) Write(values []interface{}) error {
+	w.row++
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			cells[i] = excelize.Cell{StyleID: w.dateStyle, Value: t}
+			continue
+		}
+		if a, ok := v.(money.Amount); ok {
+			// Spreadsheets have no decimal type; a number keeps the column summable
+			cells[i] = a.Float64()
+			continue
+		}
+		cells[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, cells)
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.res)
+	return err
+}
+
+func (w *xlsxExportWriter) Abort() {
+	w.file.Close()
+}
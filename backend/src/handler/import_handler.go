@@ -0,0 +1,459 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportFileSize = 5 << 20 // 5 MB
+	maxImportRows     = 10000
+)
+
+// Amount sign conventions for CSV imports
+const (
+	SignAbsolute          = "absolute"            // amounts are magnitudes, the type comes from the mapping
+	SignNegativeIsExpense = "negative_is_expense" // bank exports: debits negative, credits positive
+	SignNegativeIsIncome  = "negative_is_income"  // card statements: charges positive, refunds negative
+)
+
+// errDryRun rolls back the import transaction after a preview.
+var errDryRun = errors.New("dry run")
+
+type ImportHandler struct {
+	db           *gorm.DB
+	categoryRepo *repository.CategoryRepository
+	expenseRepo  *repository.ExpenseRepository
+	incomeRepo   *repository.IncomeRepository
+	accountRepo  *repository.AccountRepository
+	rateRepo     *repository.ExchangeRateRepository
+	alerter      *notify.BudgetAlerter
+	auditRepo    *repository.AuditRepository
+}
+
+func NewImportHandler(db *gorm.DB, categoryRepo *repository.CategoryRepository, expenseRepo *repository.ExpenseRepository, incomeRepo *repository.IncomeRepository, accountRepo *repository.AccountRepository, rateRepo *repository.ExchangeRateRepository, alerter *notify.BudgetAlerter, auditRepo *repository.AuditRepository) *ImportHandler {
+	return &ImportHandler{
+		db:           db,
+		categoryRepo: categoryRepo,
+		expenseRepo:  expenseRepo,
+		incomeRepo:   incomeRepo,
+		accountRepo:  accountRepo,
+		rateRepo:     rateRepo,
+		alerter:      alerter,
+		auditRepo:    auditRepo,
+	}
+}
+
+// CSVImportMapping tells the importer how to read the uploaded file. Columns
+// are header names (matched case-insensitively) or 0-based column indexes.
+type CSVImportMapping struct {
+	DateColumn       string `json:"dateColumn"`
+	DateFormat       string `json:"dateFormat"` // e.g. "DD/MM/YYYY" or a Go layout; defaults to YYYY-MM-DD
+	AmountColumn     string `json:"amountColumn"`
+	AmountSign       string `json:"amountSign"` // absolute, negative_is_expense, negative_is_income
+	DecimalComma     bool   `json:"decimalComma"`
+	NotesColumn      string `json:"notesColumn"`
+	CategoryColumn   string `json:"categoryColumn"`
+	CurrencyColumn   string `json:"currencyColumn"`   // ISO code of each amount; empty cells mean the base currency
+	DefaultCategory  string `json:"defaultCategory"`  // used when the row has no category
+	Type             string `json:"type"`             // expense or income, for absolute amounts
+	Delimiter        string `json:"delimiter"`        // defaults to ","
+	NoHeader         bool   `json:"noHeader"`         // the first line is data
+	CreateCategories bool   `json:"createCategories"` // create categories that do not exist yet
+	SkipInvalid      bool   `json:"skipInvalid"`      // import valid rows even when some rows fail
+	AccountID        *uint  `json:"accountId"`        // account every imported row is attributed to
+}
+
+type ImportRowResult struct {
+	Line     int          `json:"line"`
+	Status   string       `json:"status"` // ok or error
+	Type     string       `json:"type,omitempty"`
+	Date     string       `json:"date,omitempty"`
+	Amount   money.Amount `json:"amount,omitempty"`
+	Currency string       `json:"currency,omitempty"`
+	Notes    string       `json:"notes,omitempty"`
+	Category string       `json:"category,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun            bool              `json:"dryRun"`
+	Imported          int               `json:"imported"`
+	Failed            int               `json:"failed"`
+	CreatedCategories []string          `json:"createdCategories"`
+	Rows              []ImportRowResult `json:"rows"`
+}
+
+// importRow is a parsed CSV line waiting to be inserted.
+type importRow struct {
+	result   *ImportRowResult
+	date     time.Time
+	category string
+}
+
+// ImportCSV imports expenses and income from an uploaded CSV file.
+// Form fields: file (the CSV), mapping (CSVImportMapping as JSON) and dryRun.
+func (h *ImportHandler) ImportCSV(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	var mapping CSVImportMapping
+	if err := json.Unmarshal([]byte(c.FormValue("mapping")), &mapping); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid mapping"})
+	}
+	if mapping.AmountSign == "" {
+		mapping.AmountSign = SignAbsolute
+	}
+	switch mapping.AmountSign {
+	case SignAbsolute:
+		if mapping.Type != "expense" && mapping.Type != "income" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Mapping type must be expense or income"})
+		}
+	case SignNegativeIsExpense, SignNegativeIsIncome:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid amount sign convention"})
+	}
+	if mapping.DateColumn == "" || mapping.AmountColumn == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Date and amount columns are required"})
+	}
+	accountID, err := resolveAccount(h.accountRepo, cc, mapping.AccountID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	mapping.AccountID = accountID
+	dryRun, _ := strconv.ParseBool(c.FormValue("dryRun"))
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "CSV file is required"})
+	}
+	if fh.Size > maxImportFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"message": "CSV file is too large"})
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Failed to read CSV file"})
+	}
+	defer f.Close()
+
+	rows, err := parseImportCSV(f, &mapping, cc.Currency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	result := &ImportResult{DryRun: dryRun, CreatedCategories: []string{}, Rows: make([]ImportRowResult, 0, len(rows))}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.insertRows(tx, cc, &mapping, rows, result); err != nil {
+			return err
+		}
+		if dryRun || (result.Failed > 0 && !mapping.SkipInvalid) {
+			return errDryRun
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditImport, model.AuditCSV, 0, nil, map[string]interface{}{
+			"file":              fh.Filename,
+			"imported":          result.Imported,
+			"failed":            result.Failed,
+			"createdCategories": result.CreatedCategories,
+		})
+	})
+	for _, r := range rows {
+		result.Rows = append(result.Rows, *r.result)
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to import CSV"})
+	}
+
+	if result.Failed > 0 && !mapping.SkipInvalid && !dryRun {
+		// Nothing was written; report what needs fixing
+		result.Imported = 0
+		result.CreatedCategories = []string{}
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	var dates []time.Time
+	for _, r := range rows {
+		if r.result.Type == "expense" && r.result.Status != "error" {
+			dates = append(dates, r.date)
+		}
+	}
+	h.alerter.Check(cc.UserID, cc.WorkspaceID, cc.Currency, dates...)
+	return c.JSON(http.StatusCreated, result)
+}
+
+// insertRows resolves categories and inserts every valid row inside tx,
+// recording the outcome of each row in its result.
+func (h *ImportHandler) insertRows(tx *gorm.DB, cc *model.CustomContext, mapping *CSVImportMapping, rows []importRow, result *ImportResult) error {
+	categoryRepo := h.categoryRepo.WithTx(tx)
+	expenseRepo := h.expenseRepo.WithTx(tx)
+	incomeRepo := h.incomeRepo.WithTx(tx)
+	categories := make(map[string]*model.M_category)
+
+	for _, row := range rows {
+		res := row.result
+		if res.Status == "error" {
+			result.Failed++
+			continue
+		}
+		amount, err := h.rateRepo.Convert(cc.UserID, cc.WorkspaceID, res.Amount, res.Currency, cc.Currency, row.date)
+		if errors.Is(err, repository.ErrNoExchangeRate) {
+			res.Status, res.Error = "error", fmt.Sprintf("no exchange rate from %s to %s on or before %s", res.Currency, cc.Currency, res.Date)
+			result.Failed++
+			continue
+		}
+		if errors.Is(err, money.ErrRange) {
+			res.Status, res.Error = "error", "converted amount is out of range"
+			result.Failed++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		name := row.category
+		if name == "" {
+			name = mapping.DefaultCategory
+		}
+		if name == "" {
+			res.Status, res.Error = "error", "category is required"
+			result.Failed++
+			continue
+		}
+		slug := utils.GenerateSlug(name)
+
+		cat, ok := categories[slug]
+		if !ok {
+			found, err := categoryRepo.GetBySlug(cc.UserID, cc.WorkspaceID, slug)
+			switch {
+			case err == nil:
+				cat = found
+			case errors.Is(err, gorm.ErrRecordNotFound) && mapping.CreateCategories:
+				cat = &model.M_category{
+					UserID:      cc.UserID,
+					WorkspaceID: cc.WorkspaceID,
+					Name:        strings.TrimSpace(name),
+					Slug:        slug,
+					Type:        res.Type,
+					IsActive:    true,
+				}
+				if err := categoryRepo.Create(cat); err != nil {
+					return err
+				}
+				result.CreatedCategories = append(result.CreatedCategories, cat.Name)
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				cat = nil
+			default:
+				return err
+			}
+			categories[slug] = cat
+		}
+		if cat == nil {
+			res.Status, res.Error = "error", fmt.Sprintf("category %q not found", name)
+			result.Failed++
+			continue
+		}
+		if cat.Type != res.Type {
+			res.Status, res.Error = "error", fmt.Sprintf("category %q is for %s, not %s", cat.Name, cat.Type, res.Type)
+			result.Failed++
+			continue
+		}
+		res.Category = cat.Name
+
+		if res.Type == "income" {
+			err := incomeRepo.Create(&model.T_income{
+				UserID:         cc.UserID,
+				WorkspaceID:    cc.WorkspaceID,
+				Categories:     []model.M_category{{ID: cat.ID}},
+				AccountID:      mapping.AccountID,
+				Date:           row.date,
+				Amount:         amount,
+				Currency:       res.Currency,
+				OriginalAmount: res.Amount,
+				Notes:          res.Notes,
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			err := expenseRepo.Create(&model.T_expense{
+				UserID:         cc.UserID,
+				WorkspaceID:    cc.WorkspaceID,
+				Splits:         []model.T_expense_split{{CategoryID: cat.ID, Amount: amount}},
+				AccountID:      mapping.AccountID,
+				Date:           row.date,
+				Notes:          res.Notes,
+				Amount:         amount,
+				Currency:       res.Currency,
+				OriginalAmount: res.Amount,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result.Imported++
+	}
+	return nil
+}
+
+// parseImportCSV reads the file and converts each data line according to the
+// mapping. Amounts without a currency of their own are in base. Lines that
+// cannot be parsed, or whose amount has more decimal places than its currency,
+// are returned with an error status.
+func parseImportCSV(r io.Reader, mapping *CSVImportMapping, base string) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		d := []rune(mapping.Delimiter)
+		if len(d) != 1 {
+			return nil, errors.New("Delimiter must be a single character")
+		}
+		reader.Comma = d[0]
+	}
+
+	var header []string
+	if !mapping.NoHeader {
+		h, err := reader.Read()
+		if err != nil {
+			return nil, errors.New("CSV file is empty")
+		}
+		header = h
+	}
+
+	dateCol, err := resolveColumn(header, mapping.DateColumn)
+	if err != nil {
+		return nil, err
+	}
+	amountCol, err := resolveColumn(header, mapping.AmountColumn)
+	if err != nil {
+		return nil, err
+	}
+	notesCol, categoryCol, currencyCol := -1, -1, -1
+	if mapping.NotesColumn != "" {
+		if notesCol, err = resolveColumn(header, mapping.NotesColumn); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.CategoryColumn != "" {
+		if categoryCol, err = resolveColumn(header, mapping.CategoryColumn); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.CurrencyColumn != "" {
+		if currencyCol, err = resolveColumn(header, mapping.CurrencyColumn); err != nil {
+			return nil, err
+		}
+	}
+	layout := utils.DateLayout(mapping.DateFormat)
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line := 0
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		} else if err == nil {
+			line, _ = reader.FieldPos(0)
+		}
+		if len(rows) >= maxImportRows {
+			return nil, fmt.Errorf("CSV file has more than %d rows", maxImportRows)
+		}
+		res := &ImportRowResult{Line: line, Status: "ok"}
+		rows = append(rows, importRow{result: res})
+
+		if err != nil {
+			res.Status, res.Error = "error", err.Error()
+			continue
+		}
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+
+		d, err := time.Parse(layout, field(dateCol))
+		if err != nil {
+			res.Status, res.Error = "error", fmt.Sprintf("invalid date %q", field(dateCol))
+			continue
+		}
+		amount, err := utils.ParseAmount(field(amountCol), mapping.DecimalComma)
+		if err != nil || amount == 0 {
+			res.Status, res.Error = "error", fmt.Sprintf("invalid amount %q", field(amountCol))
+			continue
+		}
+		currency := strings.ToUpper(field(currencyCol))
+		if currency == "" {
+			currency = base
+		}
+		if !money.IsCurrencyCode(currency) {
+			res.Status, res.Error = "error", fmt.Sprintf("invalid currency %q", field(currencyCol))
+			continue
+		}
+		if !amount.Fits(currency) {
+			res.Status, res.Error = "error", fmt.Sprintf("amount %q has more than %d decimal places", field(amountCol), money.Decimals(currency))
+			continue
+		}
+
+		switch mapping.AmountSign {
+		case SignNegativeIsExpense:
+			res.Type = "income"
+			if amount < 0 {
+				res.Type = "expense"
+			}
+		case SignNegativeIsIncome:
+			res.Type = "expense"
+			if amount < 0 {
+				res.Type = "income"
+			}
+		default:
+			res.Type = mapping.Type
+		}
+
+		rows[len(rows)-1].date = d
+		rows[len(rows)-1].category = field(categoryCol)
+		res.Date = d.Format("2006-01-02")
+		res.Amount = amount.Abs()
+		res.Currency = currency
+		res.Notes = field(notesCol)
+	}
+	return rows, nil
+}
+
+// resolveColumn finds a column by header name or 0-based index.
+func resolveColumn(header []string, spec string) (int, error) {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(spec)) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(spec); err == nil && i >= 0 {
+		return i, nil
+	}
+	return -1, fmt.Errorf("Column %q not found", spec)
+}
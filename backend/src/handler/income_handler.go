@@ -7,92 +7,151 @@ import (
 
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type IncomeHandler struct {
-	incomeRepo  *repository.IncomeRepository
+	db           *gorm.DB
+	incomeRepo   *repository.IncomeRepository
 	categoryRepo *repository.CategoryRepository
+	accountRepo  *repository.AccountRepository
+	rateRepo     *repository.ExchangeRateRepository
+	auditRepo    *repository.AuditRepository
 }
 
-func NewIncomeHandler(incomeRepo *repository.IncomeRepository, categoryRepo *repository.CategoryRepository) *IncomeHandler {
+func NewIncomeHandler(db *gorm.DB, incomeRepo *repository.IncomeRepository, categoryRepo *repository.CategoryRepository, accountRepo *repository.AccountRepository, rateRepo *repository.ExchangeRateRepository, auditRepo *repository.AuditRepository) *IncomeHandler {
 	return &IncomeHandler{
-		incomeRepo:  incomeRepo,
+		db:           db,
+		incomeRepo:   incomeRepo,
 		categoryRepo: categoryRepo,
+		accountRepo:  accountRepo,
+		rateRepo:     rateRepo,
+		auditRepo:    auditRepo,
 	}
 }
 
 func (h *IncomeHandler) CreateIncome(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
-		CategoryIDs []uint  `json:"categoryIds"`
-		Date        string  `json:"date"`
-		Notes       string  `json:"notes"`
-		Amount      float64 `json:"amount"`
+		CategoryIDs []uint       `json:"categoryIds"`
+		AccountID   *uint        `json:"accountId"` // optional, see resolveAccount
+		Date        string       `json:"date"`
+		Notes       string       `json:"notes"`
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"` // defaults to the workspace's base currency
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	currency, err := transactionCurrency(cc, req.Currency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	if err := checkAmount(currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
 
 	d, err := time.Parse("2006-01-02", req.Date)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date"})
 	}
+	amount, status, err := convertAmount(h.rateRepo, cc, req.Amount, currency, d)
+	if err != nil {
+		return c.JSON(status, map[string]string{"message": err.Error()})
+	}
 
 	if len(req.CategoryIDs) == 0 {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "At least one category is required"})
 	}
 
-	cats := make([]model.M_category, len(req.CategoryIDs))
-	for i, id := range req.CategoryIDs {
-		cats[i] = model.M_category{ID: id}
+	cats, ok, err := resolveCategories(h.categoryRepo, cc, req.CategoryIDs, "income")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load categories"})
+	}
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category"})
+	}
+	accountID, err := resolveAccount(h.accountRepo, cc, req.AccountID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 	}
 
 	in := &model.T_income{
-		UserID:      cc.UserID,
-		WorkspaceID: cc.WorkspaceID,
-		Categories:  cats,
-		Date:        d,
-		Notes:       req.Notes,
-		Amount:      req.Amount,
+		UserID:         cc.UserID,
+		WorkspaceID:    cc.WorkspaceID,
+		Categories:     cats,
+		AccountID:      accountID,
+		Date:           d,
+		Notes:          req.Notes,
+		Amount:         amount,
+		Currency:       currency,
+		OriginalAmount: req.Amount,
 	}
 
-	if err := h.incomeRepo.Create(in); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.incomeRepo.WithTx(tx).Create(in); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditCreate, model.AuditIncome, in.ID, nil, in)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create income"})
 	}
 	return c.JSON(http.StatusCreated, in)
 }
 
+// BalanceResponse is the workspace total together with the balance of each account.
+type BalanceResponse struct {
+	*model.R_balance
+	Accounts []repository.AccountBalance `json:"accounts"`
+}
+
 func (h *IncomeHandler) GetBalance(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
-	b, err := h.incomeRepo.GetBalance(cc.UserID)
+	b, err := h.incomeRepo.GetBalance(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get balance"})
+	}
+	accounts, err := h.accountRepo.Balances(cc.UserID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get balance"})
 	}
 	if b == nil {
-		return c.JSON(http.StatusOK, map[string]interface{}{"amount": 0})
+		return c.JSON(http.StatusOK, map[string]interface{}{"amount": 0, "accounts": accounts})
 	}
-	return c.JSON(http.StatusOK, b)
+	return c.JSON(http.StatusOK, BalanceResponse{R_balance: b, Accounts: accounts})
 }
 
 func (h *IncomeHandler) UpdateBalance(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
-		Amount float64 `json:"amount"`
-		Notes  string  `json:"notes"`
+		Amount money.Amount `json:"amount"`
+		Notes  string       `json:"notes"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	if err := checkAmount(cc.Currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
 
 	b := &model.R_balance{
-		UserID: cc.UserID,
-		Amount: req.Amount,
-		Notes:  req.Notes,
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		Amount:      req.Amount,
+		Notes:       req.Notes,
 	}
 
 	if err := h.incomeRepo.UpsertBalance(b); err != nil {
@@ -109,7 +168,7 @@ func (h *IncomeHandler) GetDateIncome(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date"})
 	}
 
-	items, err := h.incomeRepo.GetByDate(cc.UserID, d)
+	items, err := h.incomeRepo.GetByDate(cc.UserID, cc.WorkspaceID, d)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch income"})
 	}
@@ -118,6 +177,10 @@ func (h *IncomeHandler) GetDateIncome(c echo.Context) error {
 
 func (h *IncomeHandler) UpdateIncome(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -125,43 +188,91 @@ func (h *IncomeHandler) UpdateIncome(c echo.Context) error {
 	}
 
 	var req struct {
-		CategoryIDs []uint  `json:"categoryIds"`
-		Date        *string `json:"date"`
-		Notes       *string `json:"notes"`
-		Amount      *float64 `json:"amount"`
+		CategoryIDs []uint        `json:"categoryIds"`
+		AccountID   *uint         `json:"accountId"` // 0 detaches the account
+		Date        *string       `json:"date"`
+		Notes       *string       `json:"notes"`
+		Amount      *money.Amount `json:"amount"`
+		Currency    *string       `json:"currency"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
-	in, err := h.incomeRepo.GetByID(uint(id), cc.UserID)
+	in, err := h.incomeRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Income not found"})
 	}
+	before := auditSnapshot(in)
+
+	date := in.Date
+	if req.Date != nil {
+		if d, err := time.Parse("2006-01-02", *req.Date); err == nil {
+			date = d
+		}
+	}
+	currency := in.Currency
+	if req.Currency != nil {
+		if currency, err = transactionCurrency(cc, *req.Currency); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+	}
+	original := in.OriginalAmount
+	if req.Amount != nil {
+		original = *req.Amount
+	}
+	if err := checkAmount(currency, original); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	// The amount is converted again when anything it depends on changes
+	amount := in.Amount
+	if req.Amount != nil || req.Currency != nil || req.Date != nil {
+		var status int
+		if amount, status, err = convertAmount(h.rateRepo, cc, original, currency, date); err != nil {
+			return c.JSON(status, map[string]string{"message": err.Error()})
+		}
+	}
 
+	var cats []model.M_category
 	if req.CategoryIDs != nil && len(req.CategoryIDs) > 0 {
-		cats := make([]model.M_category, len(req.CategoryIDs))
-		for i, catID := range req.CategoryIDs {
-			cats[i] = model.M_category{ID: catID}
+		var ok bool
+		cats, ok, err = resolveCategories(h.categoryRepo, cc, req.CategoryIDs, "income")
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load categories"})
 		}
-		if err := h.incomeRepo.ReplaceCategories(in, cats); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update categories"})
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category"})
 		}
 	}
 
-	if req.Date != nil {
-		if d, err := time.Parse("2006-01-02", *req.Date); err == nil {
-			in.Date = d
+	if req.AccountID != nil {
+		accountID, err := resolveAccount(h.accountRepo, cc, req.AccountID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 		}
+		in.AccountID = accountID
 	}
 	if req.Notes != nil {
 		in.Notes = *req.Notes
 	}
-	if req.Amount != nil {
-		in.Amount = *req.Amount
-	}
+	in.Date = date
+	in.Amount = amount
+	in.Currency = currency
+	in.OriginalAmount = original
 
-	if err := h.incomeRepo.Update(in); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		incomeRepo := h.incomeRepo.WithTx(tx)
+		if cats != nil {
+			if err := incomeRepo.ReplaceCategories(in, cats); err != nil {
+				return err
+			}
+		}
+		if err := incomeRepo.Update(in); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditUpdate, model.AuditIncome, in.ID, before, in)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update income"})
 	}
 	return c.JSON(http.StatusOK, in)
@@ -169,13 +280,27 @@ func (h *IncomeHandler) UpdateIncome(c echo.Context) error {
 
 func (h *IncomeHandler) DeleteIncome(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
 	}
 
-	if err := h.incomeRepo.Delete(uint(id), cc.UserID); err != nil {
+	in, err := h.incomeRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.incomeRepo.WithTx(tx).Delete(uint(id), cc.UserID, cc.WorkspaceID); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditDelete, model.AuditIncome, in.ID, in, nil)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete income"})
 	}
 	return c.NoContent(http.StatusNoContent)
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationHandler struct {
+	notificationRepo *repository.NotificationRepository
+	dispatcher       *notify.Dispatcher
+}
+
+func NewNotificationHandler(notificationRepo *repository.NotificationRepository, dispatcher *notify.Dispatcher) *NotificationHandler {
+	return &NotificationHandler{
+		notificationRepo: notificationRepo,
+		dispatcher:       dispatcher,
+	}
+}
+
+// GetNotifications returns the user's inbox for the active workspace, newest
+// first. Query params: unread=true to hide read entries, limit (default 50).
+func (h *NotificationHandler) GetNotifications(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	unreadOnly, _ := strconv.ParseBool(c.QueryParam("unread"))
+	limit := 50
+	if v := c.QueryParam("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	items, err := h.notificationRepo.List(cc.UserID, cc.WorkspaceID, unreadOnly, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch notifications"})
+	}
+	unread, err := h.notificationRepo.CountUnread(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch notifications"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"unread": unread,
+		"items":  items,
+	})
+}
+
+func (h *NotificationHandler) MarkRead(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	if err := h.notificationRepo.MarkRead(cc.UserID, cc.WorkspaceID, uint(id)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update notification"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) MarkAllRead(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if err := h.notificationRepo.MarkRead(cc.UserID, cc.WorkspaceID, 0); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update notifications"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) GetChannels(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.notificationRepo.GetChannels(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch channels"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// CreateChannel adds a webhook or email target for the user's notifications
+// in the active workspace. Email targets default to the user's address.
+func (h *NotificationHandler) CreateChannel(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req struct {
+		Type   string `json:"type"` // webhook or email
+		Target string `json:"target"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	switch req.Type {
+	case model.ChannelWebhook:
+		if err := notify.CheckWebhookURL(c.Request().Context(), req.Target); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid webhook URL, it must be http(s) and point to a public host"})
+		}
+	case model.ChannelEmail:
+		if req.Target == "" {
+			req.Target = cc.Email
+		}
+		addr, err := mail.ParseAddress(req.Target)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid email address"})
+		}
+		req.Target = addr.Address
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid channel type"})
+	}
+	if !h.dispatcher.Supports(req.Type) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "This channel type is not configured on the server"})
+	}
+
+	ch := &model.M_notification_channel{
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		Type:        req.Type,
+		Target:      req.Target,
+		IsActive:    true,
+	}
+	if err := h.notificationRepo.CreateChannel(ch); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create channel"})
+	}
+	return c.JSON(http.StatusCreated, ch)
+}
+
+func (h *NotificationHandler) UpdateChannel(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+
+	var req struct {
+		IsActive *bool `json:"isActive"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	ch, err := h.notificationRepo.GetChannel(cc.UserID, cc.WorkspaceID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Channel not found"})
+	}
+	if req.IsActive != nil {
+		ch.IsActive = *req.IsActive
+	}
+	if err := h.notificationRepo.UpdateChannel(ch); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update channel"})
+	}
+	return c.JSON(http.StatusOK, ch)
+}
+
+func (h *NotificationHandler) DeleteChannel(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	if err := h.notificationRepo.DeleteChannel(cc.UserID, cc.WorkspaceID, uint(id)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete channel"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TestChannel sends a test message through one channel and reports the result.
+func (h *NotificationHandler) TestChannel(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	ch, err := h.notificationRepo.GetChannel(cc.UserID, cc.WorkspaceID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Channel not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+	to := notify.Recipient{UserID: cc.UserID, WorkspaceID: cc.WorkspaceID, Target: ch.Target}
+	msg := notify.Message{
+		Kind:    "test",
+		Subject: "Test notification",
+		Body:    "This channel is set up to receive notifications from Expenses Tracker.",
+	}
+	if err := h.dispatcher.Send(ctx, ch.Type, to, msg); err != nil {
+		// The cause may describe the target's network, so it is only logged
+		log.Printf("Notify: test of %s channel %d: %v", ch.Type, ch.ID, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"message": "Delivery failed, check that the channel's target is reachable"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Test notification sent"})
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcCookie carries the state, nonce and PKCE verifier of a login in
+	// progress between the redirect to the provider and the callback.
+	oidcCookie = "oidc_login"
+	// oidcLoginTTL is how long the user has to finish signing in at the provider.
+	oidcLoginTTL = 10 * time.Minute
+	// oidcCodeTTL is how long the frontend has to exchange the login code.
+	oidcCodeTTL = time.Minute
+)
+
+// Reasons resolveUser refuses a provider account; Callback explains them to
+// the user on the login page
+var (
+	errOIDCNoEmail    = errors.New("identity provider did not share an email address")
+	errOIDCEmailTaken = errors.New("email belongs to an account that cannot be linked")
+)
+
+// OIDCHandler signs users in through an OpenID Connect provider using the
+// authorization code flow with PKCE. The callback hands the result to the
+// frontend as a single-use code, which it exchanges for the usual tokens.
+type OIDCHandler struct {
+	cfg           config.OIDCConfig
+	userRepo      *repository.UserRepository
+	identityRepo  *repository.UserIdentityRepository
+	userTokenRepo *repository.UserTokenRepository
+	auth          *AuthHandler
+	keys          *security.KeySet
+	db            *gorm.DB
+
+	mu       sync.Mutex
+	provider *oidc.Provider // discovered on first use
+}
+
+func NewOIDCHandler(cfg config.OIDCConfig, userRepo *repository.UserRepository, identityRepo *repository.UserIdentityRepository, userTokenRepo *repository.UserTokenRepository, auth *AuthHandler, keys *security.KeySet, db *gorm.DB) *OIDCHandler {
+	return &OIDCHandler{
+		cfg:           cfg,
+		userRepo:      userRepo,
+		identityRepo:  identityRepo,
+		userTokenRepo: userTokenRepo,
+		auth:          auth,
+		keys:          keys,
+		db:            db,
+	}
+}
+
+type OIDCExchangeRequest struct {
+	Code string `json:"code"`
+}
+
+// oidcClaims are the ID token claims used to find or create the user.
+type oidcClaims struct {
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // some providers send "true"
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+}
+
+func (o oidcClaims) emailVerified() bool {
+	switch v := o.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		ok, _ := strconv.ParseBool(v)
+		return ok
+	}
+	return false
+}
+
+// GetConfig tells the login page whether to offer OIDC login.
+func (h *OIDCHandler) GetConfig(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled": h.cfg.Enabled(),
+		"name":    h.cfg.Name,
+	})
+}
+
+// Login redirects the browser to the provider.
+func (h *OIDCHandler) Login(c echo.Context) error {
+	if !h.cfg.Enabled() {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Single sign-on is not configured"})
+	}
+	provider, err := h.getProvider(c.Request().Context())
+	if err != nil {
+		log.Printf("OIDC: discovery: %v", err)
+		return c.Redirect(http.StatusFound, oidcLoginRedirect("oidcError", "The identity provider is unavailable"))
+	}
+
+	state, err := repository.RandomToken(24)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start sign-in"})
+	}
+	nonce, err := repository.RandomToken(24)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start sign-in"})
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	expiresAt := time.Now().Add(oidcLoginTTL)
+	login, err := h.keys.Sign(jwt.MapClaims{
+		"type":     "oidc_login",
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      expiresAt.Unix(),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start sign-in"})
+	}
+	c.SetCookie(h.loginCookie(login, expiresAt))
+
+	authURL := h.oauthConfig(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the login at the provider and sends the browser back to
+// the login page with either a login code or an error.
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	fail := func(message string) error {
+		return c.Redirect(http.StatusFound, oidcLoginRedirect("oidcError", message))
+	}
+
+	cookie, err := c.Cookie(oidcCookie)
+	c.SetCookie(h.loginCookie("", time.Unix(0, 0)))
+	if err != nil {
+		return fail("Sign-in expired, please try again")
+	}
+	login, err := h.keys.Parse(cookie.Value)
+	if err != nil || login["type"] != "oidc_login" {
+		return fail("Sign-in expired, please try again")
+	}
+	state, _ := login["state"].(string)
+	nonce, _ := login["nonce"].(string)
+	verifier, _ := login["verifier"].(string)
+	if subtle.ConstantTimeCompare([]byte(state), []byte(c.QueryParam("state"))) != 1 {
+		return fail("Sign-in expired, please try again")
+	}
+	if e := c.QueryParam("error"); e != "" {
+		log.Printf("OIDC: provider returned %s: %s", e, c.QueryParam("error_description"))
+		return fail("The identity provider refused the sign-in")
+	}
+
+	ctx := c.Request().Context()
+	provider, err := h.getProvider(ctx)
+	if err != nil {
+		log.Printf("OIDC: discovery: %v", err)
+		return fail("The identity provider is unavailable")
+	}
+	token, err := h.oauthConfig(provider).Exchange(ctx, c.QueryParam("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		log.Printf("OIDC: code exchange: %v", err)
+		return fail("Sign-in failed, please try again")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		log.Printf("OIDC: token response without id_token")
+		return fail("Sign-in failed, please try again")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: h.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Printf("OIDC: ID token: %v", err)
+		return fail("Sign-in failed, please try again")
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return fail("Sign-in failed, please try again")
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return fail("Sign-in failed, please try again")
+	}
+
+	user, err := h.resolveUser(idToken.Issuer, idToken.Subject, claims)
+	switch {
+	case errors.Is(err, errOIDCNoEmail):
+		return fail("The identity provider did not share an email address")
+	case errors.Is(err, errOIDCEmailTaken):
+		return fail("An account with this email already exists; sign in with your password")
+	case err != nil:
+		log.Printf("OIDC: resolve user %s at %s: %v", idToken.Subject, idToken.Issuer, err)
+		return fail("Sign-in failed, please try again")
+	}
+
+	code, err := h.userTokenRepo.Issue(user.ID, model.TokenOIDCLogin, user.Email, oidcCodeTTL)
+	if err != nil {
+		return fail("Sign-in failed, please try again")
+	}
+	return c.Redirect(http.StatusFound, oidcLoginRedirect("oidcCode", code))
+}
+
+// Exchange trades the code from Callback for session tokens, or for a
+// two-factor challenge like a password login.
+func (h *OIDCHandler) Exchange(c echo.Context) error {
+	var req OIDCExchangeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	var userID uint
+	err := h.userTokenRepo.Consume(req.Code, model.TokenOIDCLogin, func(tx *gorm.DB, t *model.M_user_token) error {
+		userID = t.UserID
+		return nil
+	})
+	if errors.Is(err, repository.ErrUserTokenInvalid) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid or expired sign-in, please try again"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to sign in"})
+	}
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid or expired sign-in, please try again"})
+	}
+	return h.auth.beginLogin(c, user)
+}
+
+// GetIdentities lists the provider accounts linked to the user.
+func (h *OIDCHandler) GetIdentities(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.identityRepo.GetByUser(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch linked accounts"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// DeleteIdentity unlinks a provider account. Users created through OIDC have
+// no usable password and can set one with a password reset.
+func (h *OIDCHandler) DeleteIdentity(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	if err := h.identityRepo.Delete(cc.UserID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Linked account not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to unlink account"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// resolveUser finds the user linked to the provider account. Unknown
+// accounts are linked to the user with the same email when both the
+// provider and the user have verified it, and otherwise become new users.
+func (h *OIDCHandler) resolveUser(issuer, subject string, claims oidcClaims) (*model.M_user, error) {
+	now := time.Now()
+	identity, err := h.identityRepo.GetBySubject(issuer, subject)
+	if err == nil {
+		if err := h.identityRepo.Touch(identity.ID, claims.Email, now); err != nil {
+			log.Printf("OIDC: record login of identity %d: %v", identity.ID, err)
+		}
+		return h.userRepo.GetByID(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, errOIDCNoEmail
+	}
+	identity = &model.M_user_identity{Issuer: issuer, Subject: subject, Email: claims.Email, LastLoginAt: &now}
+
+	existing, err := h.userRepo.GetByEmail(claims.Email)
+	if err == nil {
+		if !claims.emailVerified() || existing.EmailVerifiedAt == nil {
+			return nil, errOIDCEmailTaken
+		}
+		identity.UserID = existing.ID
+		if err := h.identityRepo.Create(identity); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// The password is never told to anyone; the user can set one through a reset
+	secret, err := repository.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &model.M_user{
+		Name:     claims.Name,
+		Email:    claims.Email,
+		Password: string(hashedPassword),
+	}
+	if user.Name == "" {
+		user.Name = claims.PreferredUsername
+	}
+	if user.Name == "" {
+		user.Name = strings.Split(claims.Email, "@")[0]
+	}
+	if claims.emailVerified() {
+		user.EmailVerifiedAt = &now
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.userRepo.WithTx(tx).Create(user); err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		if err := h.identityRepo.WithTx(tx).Create(identity); err != nil {
+			return err
+		}
+		return repository.SeedDefaultCategories(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// getProvider fetches the provider's discovery document once it is first
+// needed, so the server starts while the provider is unreachable.
+func (h *OIDCHandler) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.provider != nil {
+		return h.provider, nil
+	}
+	provider, err := oidc.NewProvider(ctx, h.cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	h.provider = provider
+	return provider, nil
+}
+
+func (h *OIDCHandler) oauthConfig(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     h.cfg.ClientID,
+		ClientSecret: h.cfg.ClientSecret,
+		RedirectURL:  h.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       h.cfg.Scopes,
+	}
+}
+
+// loginCookie scopes the login state to the OIDC routes. It must be Lax so
+// the browser sends it on the redirect back from the provider.
+func (h *OIDCHandler) loginCookie(value string, expiresAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/api/apps/auth/oidc",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.cfg.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// oidcLoginRedirect links to the login page with key=value in the fragment,
+// which browsers do not send to servers or in Referer headers.
+func oidcLoginRedirect(key, value string) string {
+	return strings.TrimRight(config.AppURL(), "/") + "/app/login#" + key + "=" + url.QueryEscape(value)
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+	"expenses-tracker/src/testdb"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// mockProvider is an OpenID Connect provider serving discovery, keys and a
+// token endpoint. Codes are handed out by the test through expect, each
+// with the ID token claims it redeems for.
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string // PKCE code challenge of the authorization request
+	claims    jwt.MapClaims
+	key       *rsa.PrivateKey // signs the ID token instead of the provider's key
+}
+
+const mockClientID = "expenses-tracker"
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key, codes: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		grant, ok := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		p.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss": p.URL,
+			"aud": mockClientID,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		for k, v := range grant.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		key := p.key
+		if grant.key != nil {
+			key = grant.key
+		}
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockProvider) expect(code string, grant mockGrant) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.codes[code] = grant
+}
+
+func newTestOIDCHandler(t *testing.T, p *mockProvider, db *gorm.DB) *OIDCHandler {
+	t.Helper()
+	keys, err := security.NewKeySet(config.JWTConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.OIDCConfig{
+		Issuer:      p.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost/api/apps/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+	return NewOIDCHandler(cfg, repository.NewUserRepository(db), repository.NewUserIdentityRepository(db),
+		repository.NewUserTokenRepository(db), nil, keys, db)
+}
+
+// oidcSignIn goes through Login and Callback the way a browser would, with
+// the provider answering the code exchange with claims plus the nonce from
+// the authorization request, unless claims has its own. It returns the key
+// and value the login page receives.
+func oidcSignIn(t *testing.T, h *OIDCHandler, p *mockProvider, grant mockGrant) (string, string) {
+	t.Helper()
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	if err := h.Login(e.NewContext(httptest.NewRequest(http.MethodGet, "/api/apps/auth/oidc/login", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(authURL.String(), p.URL+"/authorize") {
+		t.Fatalf("Login redirected to %q", rec.Header().Get("Location"))
+	}
+	query := authURL.Query()
+	if query.Get("client_id") != mockClientID || query.Get("code_challenge_method") != "S256" || query.Get("state") == "" {
+		t.Fatalf("authorization request %v", query)
+	}
+
+	grant.challenge = query.Get("code_challenge")
+	claims := jwt.MapClaims{"nonce": query.Get("nonce")}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+	grant.claims = claims
+	p.expect("code-1", grant)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/apps/auth/oidc/callback?code=code-1&state="+url.QueryEscape(query.Get("state")), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	if err := h.Callback(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	location := rec.Header().Get("Location")
+	i := strings.Index(location, "#")
+	if i < 0 {
+		t.Fatalf("Callback redirected to %q", location)
+	}
+	fragment, err := url.ParseQuery(location[i+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range fragment {
+		return key, fragment.Get(key)
+	}
+	t.Fatalf("Callback redirected to %q", location)
+	return "", ""
+}
+
+func TestOIDCCallbackRefusesBadIDTokens(t *testing.T) {
+	p := newMockProvider(t)
+	h := newTestOIDCHandler(t, p, nil)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := map[string]mockGrant{
+		"another nonce":    {claims: jwt.MapClaims{"sub": "alice", "nonce": "replayed"}},
+		"another audience": {claims: jwt.MapClaims{"sub": "alice", "aud": "someone-else"}},
+		"expired":          {claims: jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}},
+		"unknown key":      {claims: jwt.MapClaims{"sub": "alice"}, key: other},
+	}
+	for name, grant := range grants {
+		key, value := oidcSignIn(t, h, p, grant)
+		if key != "oidcError" || value != "Sign-in failed, please try again" {
+			t.Errorf("%s: login page got %s=%q", name, key, value)
+		}
+	}
+}
+
+func TestOIDCCallbackRefusesForeignState(t *testing.T) {
+	p := newMockProvider(t)
+	h := newTestOIDCHandler(t, p, nil)
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	if err := h.Login(e.NewContext(httptest.NewRequest(http.MethodGet, "/api/apps/auth/oidc/login", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/apps/auth/oidc/callback?code=code-1&state=forged", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	if err := h.Callback(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "#oidcError=") {
+		t.Errorf("Callback with a forged state redirected to %q", location)
+	}
+}
+
+func TestOIDCSignInCreatesAndLinksUsers(t *testing.T) {
+	db := testdb.Open(t)
+	p := newMockProvider(t)
+	h := newTestOIDCHandler(t, p, db)
+
+	// A new provider account becomes a new user
+	key, _ := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "alice", "email": "alice@example.com", "email_verified": true, "name": "Alice"}})
+	if key != "oidcCode" {
+		t.Fatalf("first sign-in: login page got %s", key)
+	}
+	var alice model.M_user
+	if err := db.Where("email = ?", "alice@example.com").First(&alice).Error; err != nil || alice.Name != "Alice" || alice.EmailVerifiedAt == nil {
+		t.Fatalf("created user %+v, %v", alice, err)
+	}
+
+	// Later sign-ins find the user by subject, whatever the email says
+	if key, _ := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "alice", "email": "alice@new.example.com"}}); key != "oidcCode" {
+		t.Errorf("second sign-in: login page got %s", key)
+	}
+	var users int64
+	db.Model(&model.M_user{}).Count(&users)
+	if users != 1 {
+		t.Errorf("%d users after signing in twice, want 1", users)
+	}
+
+	// Password accounts are linked only when both sides verified the address
+	now := time.Now()
+	bob := model.M_user{Name: "Bob", Email: "bob@example.com", Password: "x", EmailVerifiedAt: &now}
+	carol := model.M_user{Name: "Carol", Email: "carol@example.com", Password: "x"}
+	mustCreate(t, db, &bob, &carol)
+
+	if key, value := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "bob", "email": "bob@example.com", "email_verified": false}}); key != "oidcError" || !strings.Contains(value, "already exists") {
+		t.Errorf("unverified at the provider: login page got %s=%q", key, value)
+	}
+	if key, value := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "carol", "email": "carol@example.com", "email_verified": "true"}}); key != "oidcError" || !strings.Contains(value, "already exists") {
+		t.Errorf("unverified here: login page got %s=%q", key, value)
+	}
+	if key, _ := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "bob", "email": "bob@example.com", "email_verified": "true"}}); key != "oidcCode" {
+		t.Errorf("verified on both sides: login page got %s", key)
+	}
+	identity, err := repository.NewUserIdentityRepository(db).GetBySubject(p.URL, "bob")
+	if err != nil || identity.UserID != bob.ID {
+		t.Errorf("bob's identity %+v, %v; want linked to user %d", identity, err, bob.ID)
+	}
+
+	if key, value := oidcSignIn(t, h, p, mockGrant{claims: jwt.MapClaims{"sub": "dave"}}); key != "oidcError" || !strings.Contains(value, "email") {
+		t.Errorf("without email: login page got %s=%q", key, value)
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxTokenLifetimeDays bounds the expiry that can be requested for a token.
+const maxTokenLifetimeDays = 3650
+
+type PersonalAccessTokenHandler struct {
+	patRepo *repository.PersonalAccessTokenRepository
+}
+
+func NewPersonalAccessTokenHandler(patRepo *repository.PersonalAccessTokenRepository) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{patRepo: patRepo}
+}
+
+type CreateTokenRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope"`
+	ExpiresInDays int    `json:"expiresInDays"` // 0 never expires
+}
+
+// CreateTokenResponse carries the token in plaintext. It is only ever shown
+// in this response.
+type CreateTokenResponse struct {
+	Token string                        `json:"token"`
+	Item  model.M_personal_access_token `json:"item"`
+}
+
+// GetTokens lists the user's personal access tokens across all workspaces.
+func (h *PersonalAccessTokenHandler) GetTokens(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.patRepo.GetByUser(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch access tokens"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// CreateToken issues a token bound to the active workspace. What it may do
+// there is limited both by its scope and by the user's own role.
+func (h *PersonalAccessTokenHandler) CreateToken(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req CreateTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Name is required and must be at most 100 characters"})
+	}
+	if !model.IsValidTokenScope(req.Scope) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Scope must be one of read, write_expenses, admin"})
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenLifetimeDays {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "expiresInDays must be between 0 and " + strconv.Itoa(maxTokenLifetimeDays)})
+	}
+
+	item := model.M_personal_access_token{
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		Name:        req.Name,
+		Scope:       req.Scope,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		item.ExpiresAt = &expiresAt
+	}
+	token, err := h.patRepo.Create(&item)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create access token"})
+	}
+	return c.JSON(http.StatusCreated, CreateTokenResponse{Token: token, Item: item})
+}
+
+// RevokeToken deletes one of the user's tokens; requests using it fail from
+// then on.
+func (h *PersonalAccessTokenHandler) RevokeToken(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	if err := h.patRepo.Revoke(cc.UserID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Access token not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to revoke access token"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"expenses-tracker/src/mailer"
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PersonalDataHandler struct {
+	userRepo     *repository.UserRepository
+	recoveryRepo *repository.RecoveryCodeRepository
+	secrets      *security.SecretBox
+	dataRepo     *repository.PersonalDataRepository
+	mailer       mailer.Mailer
+	grace        time.Duration
+}
+
+// NewPersonalDataHandler serves the data export and account deletion. grace
+// is how long a deleted account can still be recovered before it is purged.
+func NewPersonalDataHandler(userRepo *repository.UserRepository, recoveryRepo *repository.RecoveryCodeRepository, secrets *security.SecretBox, dataRepo *repository.PersonalDataRepository, m mailer.Mailer, grace time.Duration) *PersonalDataHandler {
+	return &PersonalDataHandler{userRepo: userRepo, recoveryRepo: recoveryRepo, secrets: secrets, dataRepo: dataRepo, mailer: m, grace: grace}
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // TOTP or recovery code, required with two-factor authentication
+}
+
+// ExportData sends a ZIP archive with the caller's profile and, one JSON file
+// per kind, every record they created in any workspace.
+func (h *PersonalDataHandler) ExportData(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	// The archive is built before anything is sent, so a failure is an error
+	// response rather than a truncated download
+	var buf bytes.Buffer
+	if err := WritePersonalData(&buf, user, h.dataRepo); err != nil {
+		log.Printf("Export personal data of user %d: %v", user.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to export personal data"})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "personal-data-"+time.Now().Format("2006-01-02")+".zip"))
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// WritePersonalData writes the ZIP archive of a personal data export to out:
+// profile.json with the user, then one JSON file per kind of record.
+func WritePersonalData(out io.Writer, user *model.M_user, dataRepo *repository.PersonalDataRepository) error {
+	w := &zipJSONWriter{zip: zip.NewWriter(out)}
+	profile, err := w.zip.Create("profile.json")
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(user, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := profile.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := dataRepo.Export(user.ID, w); err != nil {
+		return err
+	}
+	if err := w.end(); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// RequestDeletion schedules the caller's account for deletion once the grace
+// period has passed. The password, and with two-factor authentication a code,
+// confirm the request. Until then the user can sign in and cancel.
+func (h *PersonalDataHandler) RequestDeletion(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Password is incorrect"})
+	}
+	if user.TOTPEnabled {
+		ok, err := verifySecondFactor(h.userRepo, h.recoveryRepo, h.secrets, user, req.Code)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify code"})
+		}
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid code"})
+		}
+	}
+
+	if user.DeletionScheduledAt == nil {
+		at := time.Now().Add(h.grace)
+		user.DeletionScheduledAt = &at
+		if err := h.userRepo.Update(user); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to schedule account deletion"})
+		}
+		sendMailAsync(h.mailer, mailer.Email{
+			To:      user.Email,
+			Subject: "Your account will be deleted",
+			Body: fmt.Sprintf("Hi %s,\n\nYour account and all workspaces you own will be deleted permanently on %s. Workspaces shared with you lose your membership.\n\nIf you change your mind, sign in and cancel the deletion before then.\n",
+				user.Name, at.UTC().Format("2 January 2006 15:04 MST")),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message":             "Account scheduled for deletion",
+		"deletionScheduledAt": user.DeletionScheduledAt,
+	})
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion.
+func (h *PersonalDataHandler) CancelDeletion(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if user.DeletionScheduledAt == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Account is not scheduled for deletion"})
+	}
+
+	user.DeletionScheduledAt = nil
+	if err := h.userRepo.Update(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to cancel account deletion"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Account deletion cancelled"})
+}
+
+// zipJSONWriter writes each dataset of an export as a JSON array file in a
+// ZIP archive, one batch of records at a time.
+type zipJSONWriter struct {
+	zip   *zip.Writer
+	file  io.Writer
+	count int
+}
+
+func (w *zipJSONWriter) Begin(name string) error {
+	if err := w.end(); err != nil {
+		return err
+	}
+	f, err := w.zip.Create(name + ".json")
+	if err != nil {
+		return err
+	}
+	w.file, w.count = f, 0
+	_, err = io.WriteString(f, "[")
+	return err
+}
+
+func (w *zipJSONWriter) Write(rows interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	for i := 0; i < v.Len(); i++ {
+		b, err := json.MarshalIndent(v.Index(i).Interface(), "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if w.count == 0 {
+			sep = "\n  "
+		}
+		if _, err := io.WriteString(w.file, sep); err != nil {
+			return err
+		}
+		if _, err := w.file.Write(b); err != nil {
+			return err
+		}
+		w.count++
+	}
+	return nil
+}
+
+// end closes the array of the current dataset, if any.
+func (w *zipJSONWriter) end() error {
+	if w.file == nil {
+		return nil
+	}
+	closing := "\n]\n"
+	if w.count == 0 {
+		closing = "]\n"
+	}
+	_, err := io.WriteString(w.file, closing)
+	w.file = nil
+	return err
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"testing"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/testdb"
+)
+
+func TestExportData(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	mustCreate(t, db, &user)
+	mustCreate(t, db, &model.M_category{Name: "Food", Type: "expense", UserID: user.ID})
+	h := NewPersonalDataHandler(repository.NewUserRepository(db), nil, nil, repository.NewPersonalDataRepository(db), nil, 0)
+
+	rec := call(h.ExportData, user.ID, 0, http.MethodGet, "/api/apps/auth/account/export", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("export: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, f := range archive.File {
+		files[f.Name] = true
+	}
+	for _, name := range []string{"profile.json", "categories.json", "audit_log.json"} {
+		if !files[name] {
+			t.Errorf("archive lacks %s", name)
+		}
+	}
+
+	// A query failing halfway through is an error, not a truncated archive
+	if err := db.Exec("DROP TABLE t_transfers").Error; err != nil {
+		t.Fatal(err)
+	}
+	rec = call(h.ExportData, user.ID, 0, http.MethodGet, "/api/apps/auth/account/export", "")
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") == "application/zip" {
+		t.Errorf("export with a failing query: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
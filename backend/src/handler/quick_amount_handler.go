@@ -2,6 +2,7 @@ package handler
 
 import (
 	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
 	"net/http"
 
@@ -17,19 +18,19 @@ func NewQuickAmountHandler(repo *repository.QuickAmountRepository) *QuickAmountH
 }
 
 type QuickAmountPayload struct {
-	Amounts []float64 `json:"amounts"`
+	Amounts []money.Amount `json:"amounts"`
 }
 
 func (h *QuickAmountHandler) GetQuickAmounts(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
 
-	list, err := h.repo.GetByUser(userID)
+	list, err := h.repo.GetByUser(userID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load quick amounts"})
 	}
 
-	out := make([]float64, len(list))
+	out := make([]money.Amount, len(list))
 	for i, qa := range list {
 		out[i] = qa.Value
 	}
@@ -40,18 +41,24 @@ func (h *QuickAmountHandler) GetQuickAmounts(c echo.Context) error {
 func (h *QuickAmountHandler) SetQuickAmounts(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
 	userID := cc.UserID
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var payload QuickAmountPayload
 	if err := c.Bind(&payload); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid payload"})
 	}
-	amounts := make([]float64, 0, len(payload.Amounts))
+	amounts := make([]money.Amount, 0, len(payload.Amounts))
 	for _, v := range payload.Amounts {
+		if err := checkAmount(cc.Currency, v); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
 		if v > 0 {
 			amounts = append(amounts, v)
 		}
 	}
-	if err := h.repo.ReplaceForUser(userID, amounts); err != nil {
+	if err := h.repo.ReplaceForUser(userID, cc.WorkspaceID, amounts); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save quick amounts"})
 	}
 	return c.NoContent(http.StatusOK)
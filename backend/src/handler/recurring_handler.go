@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxOccurrenceScan bounds how many occurrences SkipOccurrence inspects.
+const maxOccurrenceScan = 1000
+
+type RecurringHandler struct {
+	recurringRepo *repository.RecurringRepository
+	categoryRepo  *repository.CategoryRepository
+	accountRepo   *repository.AccountRepository
+}
+
+func NewRecurringHandler(recurringRepo *repository.RecurringRepository, categoryRepo *repository.CategoryRepository, accountRepo *repository.AccountRepository) *RecurringHandler {
+	return &RecurringHandler{
+		recurringRepo: recurringRepo,
+		categoryRepo:  categoryRepo,
+		accountRepo:   accountRepo,
+	}
+}
+
+type CreateRecurringRequest struct {
+	Type           string       `json:"type"`
+	CategoryIDs    []uint       `json:"categoryIds"`
+	AccountID      *uint        `json:"accountId"`
+	Amount         money.Amount `json:"amount"`
+	Notes          string       `json:"notes"`
+	Frequency      string       `json:"frequency"`
+	Interval       int          `json:"interval"`
+	Anchor         string       `json:"anchor"`
+	StartDate      string       `json:"startDate"` // YYYY-MM-DD
+	EndDate        *string      `json:"endDate"`   // YYYY-MM-DD
+	MaxOccurrences *int         `json:"maxOccurrences"`
+}
+
+type occurrencePreview struct {
+	Date    string `json:"date"`
+	Skipped bool   `json:"skipped"`
+}
+
+func (h *RecurringHandler) GetRules(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	items, err := h.recurringRepo.GetByUser(cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch recurring rules"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *RecurringHandler) CreateRule(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	var req CreateRecurringRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if req.Type != "income" && req.Type != "expense" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid type"})
+	}
+	if !utils.IsValidFrequency(req.Frequency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid frequency"})
+	}
+	if !utils.IsValidAnchor(req.Anchor) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid anchor"})
+	}
+	if err := checkAmount(cc.Currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	if req.Interval == 0 {
+		req.Interval = 1
+	}
+	if req.Interval < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid interval"})
+	}
+	if req.MaxOccurrences != nil && *req.MaxOccurrences < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid occurrence count"})
+	}
+
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid start date"})
+	}
+	var end *time.Time
+	if req.EndDate != nil && *req.EndDate != "" {
+		d, err := time.Parse("2006-01-02", *req.EndDate)
+		if err != nil || d.Before(start) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid end date"})
+		}
+		end = &d
+	}
+
+	if len(req.CategoryIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "At least one category is required"})
+	}
+	cats, ok, err := resolveCategories(h.categoryRepo, cc, req.CategoryIDs, req.Type)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to load categories"})
+	}
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid category"})
+	}
+	accountID, err := resolveAccount(h.accountRepo, cc, req.AccountID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	rule := &model.M_recurring_rule{
+		UserID:         cc.UserID,
+		WorkspaceID:    cc.WorkspaceID,
+		Type:           req.Type,
+		Categories:     cats,
+		AccountID:      accountID,
+		Amount:         req.Amount,
+		Notes:          req.Notes,
+		Frequency:      req.Frequency,
+		Interval:       req.Interval,
+		Anchor:         req.Anchor,
+		StartDate:      start,
+		EndDate:        end,
+		MaxOccurrences: req.MaxOccurrences,
+	}
+	rule.NextDate = repository.RecurrenceOf(rule).Occurrence(0)
+	rule.IsFinished = repository.RuleEndsBefore(rule, 0, rule.NextDate)
+
+	if err := h.recurringRepo.Create(rule); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create recurring rule"})
+	}
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// PreviewRule lists the upcoming occurrences of a rule, marking skipped ones.
+func (h *RecurringHandler) PreviewRule(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	rule, err := h.recurringRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Recurring rule not found"})
+	}
+
+	count := 10
+	if v := c.QueryParam("count"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
+			count = n
+		}
+	}
+
+	skipped := make(map[string]bool, len(rule.Skips))
+	for _, s := range rule.Skips {
+		skipped[s.Date.Format("2006-01-02")] = true
+	}
+
+	out := make([]occurrencePreview, 0, count)
+	series := repository.RecurrenceOf(rule)
+	for n := rule.OccurrenceCount; len(out) < count && !rule.IsFinished; n++ {
+		d := series.Occurrence(n)
+		if repository.RuleEndsBefore(rule, n, d) {
+			break
+		}
+		key := d.Format("2006-01-02")
+		out = append(out, occurrencePreview{Date: key, Skipped: skipped[key]})
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+func (h *RecurringHandler) PauseRule(c echo.Context) error {
+	return h.setPaused(c, true)
+}
+
+// ResumeRule restarts a paused rule from its next occurrence on or after
+// today; occurrences that fell due while it was paused are not created.
+func (h *RecurringHandler) ResumeRule(c echo.Context) error {
+	return h.setPaused(c, false)
+}
+
+// SkipOccurrence prevents one upcoming occurrence from being created. Without a
+// date the next occurrence is skipped.
+func (h *RecurringHandler) SkipOccurrence(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	rule, err := h.recurringRepo.GetByID(uint(id), cc.UserID, cc.WorkspaceID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Recurring rule not found"})
+	}
+
+	var req struct {
+		Date string `json:"date"` // YYYY-MM-DD
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	date := rule.NextDate
+	if req.Date != "" {
+		d, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date"})
+		}
+		date = d
+	}
+
+	// The date must be one of the rule's upcoming occurrences
+	series := repository.RecurrenceOf(rule)
+	found := false
+	for n := rule.OccurrenceCount; n < rule.OccurrenceCount+maxOccurrenceScan; n++ {
+		d := series.Occurrence(n)
+		if d.After(date) || repository.RuleEndsBefore(rule, n, d) {
+			break
+		}
+		if d.Equal(date) {
+			found = true
+			break
+		}
+	}
+	if !found || rule.IsFinished {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Date is not an upcoming occurrence"})
+	}
+
+	for _, s := range rule.Skips {
+		if s.Date.Equal(date) {
+			return c.JSON(http.StatusOK, s)
+		}
+	}
+
+	skip := &model.M_recurring_skip{RecurringRuleID: rule.ID, Date: date}
+	if err := h.recurringRepo.AddSkip(skip); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to skip occurrence"})
+	}
+	return c.JSON(http.StatusCreated, skip)
+}
+
+func (h *RecurringHandler) DeleteRule(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+
+	if err := h.recurringRepo.Delete(uint(id), cc.UserID, cc.WorkspaceID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete recurring rule"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *RecurringHandler) setPaused(c echo.Context, paused bool) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	rule, err := h.recurringRepo.SetPaused(uint(id), cc.UserID, cc.WorkspaceID, paused, today)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Recurring rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update recurring rule"})
+	}
+	return c.JSON(http.StatusOK, rule)
+}
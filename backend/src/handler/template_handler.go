@@ -6,6 +6,7 @@ import (
 
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
 
 	"github.com/labstack/echo/v4"
@@ -25,7 +26,7 @@ func NewTemplateHandler(templateRepo *repository.TemplateRepository, categoryRep
 
 func (h *TemplateHandler) GetTemplates(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
-	items, err := h.templateRepo.GetByUser(cc.UserID)
+	items, err := h.templateRepo.GetByUser(cc.UserID, cc.WorkspaceID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch templates"})
 	}
@@ -34,22 +35,29 @@ func (h *TemplateHandler) GetTemplates(c echo.Context) error {
 
 func (h *TemplateHandler) CreateTemplate(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
 
 	var req struct {
-		Name       string  `json:"name"`
-		CategoryID uint    `json:"categoryId"`
-		Amount     float64 `json:"amount"`
-		Notes      string  `json:"notes"`
+		Name       string       `json:"name"`
+		CategoryID uint         `json:"categoryId"`
+		Amount     money.Amount `json:"amount"`
+		Notes      string       `json:"notes"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	if err := checkAmount(cc.Currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
 
 	t := &model.M_expense_template{
-		UserID: cc.UserID,
-		Name:   req.Name,
-		Amount: req.Amount,
-		Notes:  req.Notes,
+		UserID:      cc.UserID,
+		WorkspaceID: cc.WorkspaceID,
+		Name:        req.Name,
+		Amount:      req.Amount,
+		Notes:       req.Notes,
 	}
 
 	if err := h.templateRepo.Create(t); err != nil {
@@ -60,13 +68,17 @@ func (h *TemplateHandler) CreateTemplate(c echo.Context) error {
 
 func (h *TemplateHandler) DeleteTemplate(c echo.Context) error {
 	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
 	}
 
-	if err := h.templateRepo.Delete(uint(id), cc.UserID); err != nil {
+	if err := h.templateRepo.Delete(uint(id), cc.UserID, cc.WorkspaceID); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete template"})
 	}
 	return c.NoContent(http.StatusNoContent)
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TransferHandler struct {
+	transferRepo *repository.TransferRepository
+	accountRepo  *repository.AccountRepository
+}
+
+func NewTransferHandler(transferRepo *repository.TransferRepository, accountRepo *repository.AccountRepository) *TransferHandler {
+	return &TransferHandler{
+		transferRepo: transferRepo,
+		accountRepo:  accountRepo,
+	}
+}
+
+// GetTransfers lists transfers, optionally only those touching ?accountId.
+func (h *TransferHandler) GetTransfers(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var accountID *uint
+	if v := c.QueryParam("accountId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid account"})
+		}
+		uid := uint(id)
+		accountID = &uid
+	}
+
+	items, err := h.transferRepo.GetByUser(cc.UserID, cc.WorkspaceID, accountID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch transfers"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *TransferHandler) CreateTransfer(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	var req struct {
+		FromAccountID uint         `json:"fromAccountId"`
+		ToAccountID   uint         `json:"toAccountId"`
+		Date          string       `json:"date"` // YYYY-MM-DD
+		Amount        money.Amount `json:"amount"`
+		Notes         string       `json:"notes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	d, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid date"})
+	}
+	if req.Amount <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Amount must be positive"})
+	}
+	if err := checkAmount(cc.Currency, req.Amount); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	if req.FromAccountID == 0 || req.ToAccountID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Both accounts are required"})
+	}
+	if req.FromAccountID == req.ToAccountID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Cannot transfer to the same account"})
+	}
+	if _, err := resolveAccount(h.accountRepo, cc, &req.FromAccountID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	if _, err := resolveAccount(h.accountRepo, cc, &req.ToAccountID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+
+	t := &model.T_transfer{
+		UserID:        cc.UserID,
+		WorkspaceID:   cc.WorkspaceID,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Date:          d,
+		Amount:        req.Amount,
+		Notes:         req.Notes,
+	}
+	if err := h.transferRepo.Create(t); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create transfer"})
+	}
+	return c.JSON(http.StatusCreated, t)
+}
+
+func (h *TransferHandler) DeleteTransfer(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ID"})
+	}
+
+	if err := h.transferRepo.Delete(uint(id), cc.UserID, cc.WorkspaceID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete transfer"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrashLimit = 100
+	maxTrashLimit     = 500
+)
+
+type TrashHandler struct {
+	db        *gorm.DB
+	trashRepo *repository.TrashRepository
+	alerter   *notify.BudgetAlerter
+	auditRepo *repository.AuditRepository
+}
+
+func NewTrashHandler(db *gorm.DB, trashRepo *repository.TrashRepository, alerter *notify.BudgetAlerter, auditRepo *repository.AuditRepository) *TrashHandler {
+	return &TrashHandler{db: db, trashRepo: trashRepo, alerter: alerter, auditRepo: auditRepo}
+}
+
+// GetTrash lists the deleted expenses, income and categories of the active
+// workspace, most recently deleted first. Query params: type (expense, income
+// or category; default all) and limit (default 100, at most 500).
+func (h *TrashHandler) GetTrash(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	itemType := c.QueryParam("type")
+	if itemType != "" && !isTrashType(itemType) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid type"})
+	}
+	limit := defaultTrashLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrashLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "limit must be between 1 and " + strconv.Itoa(maxTrashLimit)})
+		}
+		limit = n
+	}
+
+	items, err := h.trashRepo.List(cc.UserID, cc.WorkspaceID, itemType, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch trash"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// RestoreItem takes an item out of the trash. Restoring an expense or income
+// also restores the deleted categories it was filed under.
+func (h *TrashHandler) RestoreItem(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+	itemType, id, msg := trashItemParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	switch itemType {
+	case repository.TrashExpense:
+		e, err := h.trashRepo.GetExpense(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.restore(cc, model.AuditExpense, e.ID, e, func(trashRepo *repository.TrashRepository) ([]model.M_category, error) {
+			return trashRepo.RestoreExpense(e)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to restore expense"})
+		}
+		h.alerter.Check(cc.UserID, cc.WorkspaceID, cc.Currency, e.Date)
+	case repository.TrashIncome:
+		in, err := h.trashRepo.GetIncome(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.restore(cc, model.AuditIncome, in.ID, in, func(trashRepo *repository.TrashRepository) ([]model.M_category, error) {
+			return trashRepo.RestoreIncome(in)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to restore income"})
+		}
+	default:
+		category, err := h.trashRepo.GetCategory(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.restore(cc, model.AuditCategory, category.ID, category, func(trashRepo *repository.TrashRepository) ([]model.M_category, error) {
+			return nil, trashRepo.RestoreCategory(category)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to restore category"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Item restored successfully"})
+}
+
+// PurgeItem permanently deletes an item from the trash. A category can only
+// be purged once no expense or income, in the trash or not, uses it.
+func (h *TrashHandler) PurgeItem(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+	if !cc.CanWrite() {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You have read-only access to this workspace"})
+	}
+	itemType, id, msg := trashItemParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": msg})
+	}
+
+	switch itemType {
+	case repository.TrashExpense:
+		e, err := h.trashRepo.GetExpense(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.purge(cc, model.AuditExpense, e.ID, e, func(trashRepo *repository.TrashRepository) error {
+			return trashRepo.PurgeExpense(e)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete expense"})
+		}
+	case repository.TrashIncome:
+		in, err := h.trashRepo.GetIncome(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.purge(cc, model.AuditIncome, in.ID, in, func(trashRepo *repository.TrashRepository) error {
+			return trashRepo.PurgeIncome(in)
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete income"})
+		}
+	default:
+		category, err := h.trashRepo.GetCategory(cc.UserID, cc.WorkspaceID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Item not found in trash"})
+		}
+		err = h.purge(cc, model.AuditCategory, category.ID, category, func(trashRepo *repository.TrashRepository) error {
+			return trashRepo.PurgeCategory(category)
+		})
+		if errors.Is(err, repository.ErrCategoryInUse) {
+			return c.JSON(http.StatusConflict, map[string]string{"message": "Category is still used by expenses or income; delete those permanently first"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete category"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Item deleted permanently"})
+}
+
+// restore runs restore in a transaction together with the audit entries of
+// the item and of the deleted categories restored with it.
+func (h *TrashHandler) restore(cc *model.CustomContext, entity string, id uint, item interface{}, restore func(*repository.TrashRepository) ([]model.M_category, error)) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		restored, err := restore(h.trashRepo.WithTx(tx))
+		if err != nil {
+			return err
+		}
+		auditRepo := h.auditRepo.WithTx(tx)
+		if err := recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditRestore, entity, id, nil, item); err != nil {
+			return err
+		}
+		for i := range restored {
+			if err := recordAudit(auditRepo, cc, cc.WorkspaceID, model.AuditRestore, model.AuditCategory, restored[i].ID, nil, restored[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// purge runs purge in a transaction together with the item's audit entry.
+func (h *TrashHandler) purge(cc *model.CustomContext, entity string, id uint, item interface{}, purge func(*repository.TrashRepository) error) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if err := purge(h.trashRepo.WithTx(tx)); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, cc.WorkspaceID, model.AuditPurge, entity, id, item, nil)
+	})
+}
+
+// trashItemParams reads the :type and :id path params, or returns a message
+// saying which one is invalid.
+func trashItemParams(c echo.Context) (string, uint, string) {
+	itemType := c.Param("type")
+	if !isTrashType(itemType) {
+		return "", 0, "Invalid type"
+	}
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		return "", 0, "Invalid ID"
+	}
+	return itemType, id, ""
+}
+
+func isTrashType(t string) bool {
+	return t == repository.TrashExpense || t == repository.TrashIncome || t == repository.TrashCategory
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
+	"expenses-tracker/src/utils"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time.
+const recoveryCodeCount = 10
+
+type TwoFactorHandler struct {
+	userRepo     *repository.UserRepository
+	recoveryRepo *repository.RecoveryCodeRepository
+	secrets      *security.SecretBox
+	db           *gorm.DB
+}
+
+func NewTwoFactorHandler(userRepo *repository.UserRepository, recoveryRepo *repository.RecoveryCodeRepository, secrets *security.SecretBox, db *gorm.DB) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		userRepo:     userRepo,
+		recoveryRepo: recoveryRepo,
+		secrets:      secrets,
+		db:           db,
+	}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"` // TOTP code or recovery code
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func (h *TwoFactorHandler) GetStatus(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	remaining, err := h.recoveryRepo.CountUnused(user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to fetch two-factor status"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled":                user.TOTPEnabled,
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// Setup generates a new TOTP secret for the user. It only takes effect once
+// Enable confirms a code from it, so calling Setup again just replaces it.
+func (h *TwoFactorHandler) Setup(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if user.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Two-factor authentication is already enabled"})
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Expenses Tracker"
+	}
+	key, err := utils.NewTOTPKey(issuer, user.Email)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate secret"})
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate QR code"})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate QR code"})
+	}
+
+	sealed, err := h.secrets.Seal(user.ID, key.Secret())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save secret"})
+	}
+	user.TOTPSecret = sealed
+	user.TOTPLastStep = 0
+	if err := h.userRepo.Update(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save secret"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"secret":     key.Secret(),
+		"otpauthUri": key.URL(),
+		"qrCode":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
+}
+
+// Enable turns two-factor authentication on after the user proves their
+// authenticator works, and returns the recovery codes. They are shown once.
+func (h *TwoFactorHandler) Enable(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if user.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Two-factor authentication is already enabled"})
+	}
+	if user.TOTPSecret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Start two-factor setup first"})
+	}
+
+	secret, err := h.secrets.Open(user.ID, user.TOTPSecret)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Start two-factor setup first"})
+	}
+	step, ok := utils.MatchTOTP(secret, strings.TrimSpace(req.Code), time.Now())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid code"})
+	}
+
+	codes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate recovery codes"})
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+		return h.recoveryRepo.WithTx(tx).Replace(user.ID, codes)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to enable two-factor authentication"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled":       true,
+		"recoveryCodes": codes,
+	})
+}
+
+// Disable turns two-factor authentication off. It asks for both the password
+// and a current code so a stolen session alone cannot remove it.
+func (h *TwoFactorHandler) Disable(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req DisableTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if !user.TOTPEnabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Two-factor authentication is not enabled"})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Password is incorrect"})
+	}
+	ok, err := verifySecondFactor(h.userRepo, h.recoveryRepo, h.secrets, user, req.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify code"})
+	}
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid code"})
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+		return h.recoveryRepo.WithTx(tx).DeleteAll(user.ID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to disable two-factor authentication"})
+	}
+	return c.JSON(http.StatusOK, map[string]bool{"enabled": false})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+	if !user.TOTPEnabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Two-factor authentication is not enabled"})
+	}
+	if !utils.IsTOTPCode(strings.TrimSpace(req.Code)) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Enter a code from your authenticator app"})
+	}
+	ok, err := verifySecondFactor(h.userRepo, h.recoveryRepo, h.secrets, user, req.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to verify code"})
+	}
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid code"})
+	}
+
+	codes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate recovery codes"})
+	}
+	if err := h.recoveryRepo.Replace(user.ID, codes); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to save recovery codes"})
+	}
+	return c.JSON(http.StatusOK, map[string][]string{"recoveryCodes": codes})
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code of user. Both are single-use. The TOTP secret is decrypted with
+// secrets for the check only.
+func verifySecondFactor(userRepo *repository.UserRepository, recoveryRepo *repository.RecoveryCodeRepository, secrets *security.SecretBox, user *model.M_user, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" || !user.TOTPEnabled {
+		return false, nil
+	}
+	if utils.IsTOTPCode(code) {
+		secret, err := secrets.Open(user.ID, user.TOTPSecret)
+		if err != nil {
+			return false, err
+		}
+		step, ok := utils.MatchTOTP(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return userRepo.ClaimTOTPStep(user.ID, step)
+	}
+	return recoveryRepo.Consume(user.ID, code)
+}
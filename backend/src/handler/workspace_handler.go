@@ -3,9 +3,11 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"expenses-tracker/src/middleware"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 	"expenses-tracker/src/repository"
 
 	"github.com/labstack/echo/v4"
@@ -13,22 +15,25 @@ import (
 )
 
 type WorkspaceHandler struct {
-	repo     *repository.WorkspaceRepository
-	userRepo *repository.UserRepository
-	db       *gorm.DB
+	repo      *repository.WorkspaceRepository
+	userRepo  *repository.UserRepository
+	auditRepo *repository.AuditRepository
+	db        *gorm.DB
 }
 
-func NewWorkspaceHandler(repo *repository.WorkspaceRepository, userRepo *repository.UserRepository, db *gorm.DB) *WorkspaceHandler {
+func NewWorkspaceHandler(repo *repository.WorkspaceRepository, userRepo *repository.UserRepository, auditRepo *repository.AuditRepository, db *gorm.DB) *WorkspaceHandler {
 	return &WorkspaceHandler{
-		repo:     repo,
-		userRepo: userRepo,
-		db:       db,
+		repo:      repo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		db:        db,
 	}
 }
 
 type CreateWorkspaceRequest struct {
-	Name        string `json:"name" validate:"required"`
+	Name        string `json:"name"`
 	Description string `json:"description"`
+	Currency    string `json:"currency"` // base currency, the owner's by default; fixed once created
 }
 
 func (h *WorkspaceHandler) List(c echo.Context) error {
@@ -54,7 +59,7 @@ func (h *WorkspaceHandler) Get(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
 	}
 
-	ws, err := h.repo.GetByID(userID, uint(id))
+	ws, err := h.repo.GetAccessible(userID, uint(id))
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
 	}
@@ -71,13 +76,32 @@ func (h *WorkspaceHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
 
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if currency == "" {
+		own, err := repository.BaseCurrency(h.db, userID, 0)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create workspace"})
+		}
+		currency = own
+	}
+	if !money.IsCurrencyCode(currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid currency"})
+	}
+
 	ws := model.M_workspace{
 		UserID:      userID,
 		Name:        req.Name,
 		Description: req.Description,
+		Currency:    currency,
 	}
 
-	if err := h.repo.Create(&ws); err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.repo.WithTx(tx).Create(&ws); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, ws.ID, model.AuditCreate, model.AuditWorkspace, ws.ID, nil, ws)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create workspace"})
 	}
 
@@ -116,9 +140,21 @@ func (h *WorkspaceHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
 	}
 
+	// Amounts already converted into the base currency would be wrong in another
+	if req.Currency != "" && !strings.EqualFold(strings.TrimSpace(req.Currency), ws.Currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "The base currency of a workspace cannot be changed"})
+	}
+
+	before := auditSnapshot(ws)
 	ws.Name = req.Name
 	ws.Description = req.Description
-	if err := h.repo.Update(ws); err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.repo.WithTx(tx).Update(ws); err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, ws.ID, model.AuditUpdate, model.AuditWorkspace, ws.ID, before, ws)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update workspace"})
 	}
 
@@ -142,10 +178,16 @@ func (h *WorkspaceHandler) Delete(c echo.Context) error {
 
 	// Delete workspace (hard delete - cascade will delete related data)
 	// Use Unscoped() to perform a hard delete instead of soft delete
-	if err := h.db.Unscoped().Delete(ws).Error; err != nil {
+	// The workspace's audit log outlives it
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(ws).Error; err != nil {
+			return err
+		}
+		return recordAudit(h.auditRepo.WithTx(tx), cc, ws.ID, model.AuditDelete, model.AuditWorkspace, ws.ID, ws, nil)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete workspace: " + err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Workspace deleted successfully"})
 }
-
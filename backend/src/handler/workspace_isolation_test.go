@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/testdb"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// call runs h as the given user in the given workspace, with path parameters
+// given as name, value pairs.
+func call(h echo.HandlerFunc, userID, workspaceID uint, method, target, body string, params ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	for i := 0; i+1 < len(params); i += 2 {
+		c.SetParamNames(append(c.ParamNames(), params[i])...)
+		c.SetParamValues(append(c.ParamValues(), params[i+1])...)
+	}
+	cc := &model.CustomContext{
+		Context:       c,
+		UserID:        userID,
+		WorkspaceID:   workspaceID,
+		WorkspaceRole: model.WorkspaceRoleOwner,
+		Currency:      "IDR",
+	}
+	if err := h(cc); err != nil {
+		echo.New().HTTPErrorHandler(err, cc)
+	}
+	return rec
+}
+
+// listedIDs returns the value of key in every object of a JSON array body.
+func listedIDs(t *testing.T, rec *httptest.ResponseRecorder, key string) []uint {
+	t.Helper()
+	var items []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		if v, ok := item[key].(float64); ok {
+			ids = append(ids, uint(v))
+		}
+	}
+	return ids
+}
+
+func hasID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, values ...interface{}) {
+	t.Helper()
+	for _, v := range values {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandlersKeepWorkspacesApart(t *testing.T) {
+	db := testdb.Open(t)
+
+	owner := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	stranger := model.M_user{Name: "Stranger", Email: "stranger@example.com", Password: "x", Currency: "IDR"}
+	mustCreate(t, db, &owner, &stranger)
+	shared := model.M_workspace{UserID: owner.ID, Name: "A", Currency: "IDR"}
+	other := model.M_workspace{UserID: owner.ID, Name: "B", Currency: "IDR"}
+	mustCreate(t, db, &shared, &other)
+
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	cat := model.M_category{Name: "Food", Type: "expense", UserID: owner.ID, WorkspaceID: shared.ID}
+	mustCreate(t, db, &cat)
+	exp := model.T_expense{
+		UserID: owner.ID, WorkspaceID: shared.ID, Date: date, Currency: "IDR",
+		Amount: 10 * money.One, OriginalAmount: 10 * money.One,
+		Splits: []model.T_expense_split{{CategoryID: cat.ID, Amount: 10 * money.One}},
+	}
+	in := model.T_income{UserID: owner.ID, WorkspaceID: shared.ID, Date: date, Currency: "IDR", Amount: 20 * money.One, OriginalAmount: 20 * money.One}
+	budget := model.R_budget{UserID: owner.ID, WorkspaceID: shared.ID, CategoryID: cat.ID, Month: "2024-03", Amount: 50 * money.One}
+	tmpl := model.M_expense_template{UserID: owner.ID, WorkspaceID: shared.ID, Name: "Lunch", Amount: 5 * money.One, IsActive: true}
+	mustCreate(t, db, &exp, &in, &budget, &tmpl)
+
+	expenseRepo := repository.NewExpenseRepository(db)
+	incomeRepo := repository.NewIncomeRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	budgetRepo := repository.NewBudgetRepository(db)
+	templateRepo := repository.NewTemplateRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+	rateRepo := repository.NewExchangeRateRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	dispatcher := notify.NewDispatcher(notify.NewInboxNotifier(notificationRepo), nil, notificationRepo, repository.NewWorkspaceRepository(db))
+	alerter := notify.NewBudgetAlerter(repository.NewBudgetAlertRepository(db), dispatcher)
+
+	expenses := NewExpenseHandler(db, expenseRepo, categoryRepo, accountRepo, rateRepo, alerter, auditRepo)
+	incomes := NewIncomeHandler(db, incomeRepo, categoryRepo, accountRepo, rateRepo, auditRepo)
+	categories := NewCategoryHandler(db, categoryRepo, auditRepo)
+	budgets := NewBudgetHandler(db, budgetRepo, categoryRepo, expenseRepo, repository.NewBudgetAlertRepository(db), auditRepo)
+	templates := NewTemplateHandler(templateRepo, categoryRepo)
+
+	expID, inID, catID, tmplID := itoa(exp.ID), itoa(in.ID), itoa(cat.ID), itoa(tmpl.ID)
+	views := []struct {
+		name        string
+		userID      uint
+		workspaceID uint
+	}{
+		{"owner in workspace B", owner.ID, other.ID},
+		{"owner in personal workspace", owner.ID, 0},
+		{"stranger in personal workspace", stranger.ID, 0},
+	}
+	for _, v := range views {
+		t.Run(v.name, func(t *testing.T) {
+			u, ws := v.userID, v.workspaceID
+
+			rec := call(expenses.GetDateExpenses, u, ws, http.MethodGet, "/", "", "date", "2024-03-15")
+			if hasID(listedIDs(t, rec, "id"), exp.ID) {
+				t.Error("expense listed")
+			}
+			if rec := call(expenses.UpdateExpense, u, ws, http.MethodPut, "/", `{"notes":"x"}`, "id", expID); rec.Code != http.StatusNotFound {
+				t.Errorf("update expense: status %d, want 404", rec.Code)
+			}
+			call(expenses.DeleteExpense, u, ws, http.MethodDelete, "/", "", "id", expID)
+
+			rec = call(incomes.GetDateIncome, u, ws, http.MethodGet, "/", "", "date", "2024-03-15")
+			if hasID(listedIDs(t, rec, "id"), in.ID) {
+				t.Error("income listed")
+			}
+			if rec := call(incomes.UpdateIncome, u, ws, http.MethodPut, "/", `{"notes":"x"}`, "id", inID); rec.Code != http.StatusNotFound {
+				t.Errorf("update income: status %d, want 404", rec.Code)
+			}
+			call(incomes.DeleteIncome, u, ws, http.MethodDelete, "/", "", "id", inID)
+
+			rec = call(categories.GetCategories, u, ws, http.MethodGet, "/", "")
+			if hasID(listedIDs(t, rec, "id"), cat.ID) {
+				t.Error("category listed")
+			}
+			if rec := call(categories.UpdateCategory, u, ws, http.MethodPut, "/", `{"name":"Stolen"}`, "id", catID); rec.Code != http.StatusNotFound {
+				t.Errorf("update category: status %d, want 404", rec.Code)
+			}
+			if rec := call(categories.DeleteCategory, u, ws, http.MethodDelete, "/", "", "id", catID); rec.Code != http.StatusNotFound {
+				t.Errorf("delete category: status %d, want 404", rec.Code)
+			}
+
+			rec = call(budgets.GetBudgets, u, ws, http.MethodGet, "/?month=2024-03", "")
+			if hasID(listedIDs(t, rec, "categoryId"), cat.ID) {
+				t.Error("budget listed")
+			}
+			call(budgets.DeleteBudget, u, ws, http.MethodDelete, "/", "", "categoryId", catID)
+
+			rec = call(templates.GetTemplates, u, ws, http.MethodGet, "/", "")
+			if hasID(listedIDs(t, rec, "id"), tmpl.ID) {
+				t.Error("template listed")
+			}
+			call(templates.DeleteTemplate, u, ws, http.MethodDelete, "/", "", "id", tmplID)
+		})
+	}
+
+	// Nothing was changed or deleted
+	var gotExp model.T_expense
+	if err := db.First(&gotExp, exp.ID).Error; err != nil || gotExp.Notes != "" {
+		t.Errorf("expense changed: %+v, %v", gotExp, err)
+	}
+	var gotIn model.T_income
+	if err := db.First(&gotIn, in.ID).Error; err != nil || gotIn.Notes != "" {
+		t.Errorf("income changed: %+v, %v", gotIn, err)
+	}
+	var gotCat model.M_category
+	if err := db.First(&gotCat, cat.ID).Error; err != nil || gotCat.Name != "Food" {
+		t.Errorf("category changed: %+v, %v", gotCat, err)
+	}
+	if err := db.First(&model.R_budget{}, budget.ID).Error; err != nil {
+		t.Errorf("budget deleted: %v", err)
+	}
+	if err := db.First(&model.M_expense_template{}, tmpl.ID).Error; err != nil {
+		t.Errorf("template deleted: %v", err)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
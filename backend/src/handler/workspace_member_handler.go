@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/mailer"
+	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WorkspaceMemberHandler struct {
+	memberRepo    *repository.WorkspaceMemberRepository
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+	mailer        mailer.Mailer
+}
+
+func NewWorkspaceMemberHandler(memberRepo *repository.WorkspaceMemberRepository, workspaceRepo *repository.WorkspaceRepository, userRepo *repository.UserRepository, m mailer.Mailer) *WorkspaceMemberHandler {
+	return &WorkspaceMemberHandler{
+		memberRepo:    memberRepo,
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		mailer:        m,
+	}
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+func isMemberRole(role string) bool {
+	return role == model.WorkspaceRoleEditor || role == model.WorkspaceRoleViewer
+}
+
+// List returns every membership (including pending invitations) of a workspace.
+func (h *WorkspaceMemberHandler) List(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
+	}
+
+	ws, err := h.workspaceRepo.GetAccessible(cc.UserID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
+	}
+
+	items, err := h.memberRepo.ListByWorkspace(ws.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to list members"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// Invite adds a pending membership addressed to an email and lets the invitee
+// know by mail. Only the owner can invite.
+func (h *WorkspaceMemberHandler) Invite(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
+	}
+
+	var req InviteMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || !isMemberRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	ws, err := h.workspaceRepo.GetByID(cc.UserID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
+	}
+	if strings.EqualFold(req.Email, cc.Email) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "You already own this workspace"})
+	}
+
+	m, err := h.memberRepo.GetByEmail(ws.ID, req.Email)
+	if err == nil {
+		if m.Status != model.MemberStatusDeclined {
+			return c.JSON(http.StatusConflict, map[string]string{"message": "User already invited"})
+		}
+		// Re-invite someone who declined earlier
+		m.Role = req.Role
+		m.Status = model.MemberStatusPending
+		m.InvitedBy = cc.UserID
+		if err := h.memberRepo.Update(m); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to invite member"})
+		}
+		h.sendInvitation(cc, ws, m)
+		return c.JSON(http.StatusCreated, m)
+	}
+
+	m = &model.M_workspace_member{
+		WorkspaceID: ws.ID,
+		Email:       req.Email,
+		Role:        req.Role,
+		Status:      model.MemberStatusPending,
+		InvitedBy:   cc.UserID,
+	}
+	if err := h.memberRepo.Create(m); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to invite member"})
+	}
+	h.sendInvitation(cc, ws, m)
+	return c.JSON(http.StatusCreated, m)
+}
+
+// sendInvitation tells the invitee of m which workspace they were invited to
+// and how to answer.
+func (h *WorkspaceMemberHandler) sendInvitation(cc *model.CustomContext, ws *model.M_workspace, m *model.M_workspace_member) {
+	sendMailAsync(h.mailer, mailer.Email{
+		To:      m.Email,
+		Subject: fmt.Sprintf("%s invited you to %s", cc.UserName, ws.Name),
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to join the workspace %q as %s.\n\nSign in or sign up with %s to accept or decline the invitation:\n\n%s\n",
+			cc.UserName, ws.Name, m.Role, m.Email, strings.TrimRight(config.AppURL(), "/")),
+	})
+}
+
+// UpdateRole changes the role of a member. Only the owner can change roles.
+func (h *WorkspaceMemberHandler) UpdateRole(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
+	}
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid member ID"})
+	}
+
+	var req UpdateMemberRequest
+	if err := c.Bind(&req); err != nil || !isMemberRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	ws, err := h.workspaceRepo.GetByID(cc.UserID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
+	}
+
+	m, err := h.memberRepo.GetByID(ws.ID, uint(memberID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Member not found"})
+	}
+	if m.Role == model.WorkspaceRoleOwner {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "The owner role cannot be changed"})
+	}
+
+	m.Role = req.Role
+	if err := h.memberRepo.Update(m); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update member"})
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// Remove deletes a membership. The owner can remove anyone else; members can
+// remove themselves to leave the workspace.
+func (h *WorkspaceMemberHandler) Remove(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
+	}
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid member ID"})
+	}
+
+	ws, err := h.workspaceRepo.GetAccessible(cc.UserID, uint(id))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Workspace not found"})
+	}
+
+	m, err := h.memberRepo.GetByID(ws.ID, uint(memberID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Member not found"})
+	}
+	if m.Role == model.WorkspaceRoleOwner {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "The owner cannot be removed"})
+	}
+	if ws.UserID != cc.UserID && m.UserID != cc.UserID {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Only the owner can remove members"})
+	}
+
+	if err := h.memberRepo.Delete(m); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to remove member"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListInvitations returns pending invitations addressed to the caller's email.
+func (h *WorkspaceMemberHandler) ListInvitations(c echo.Context) error {
+	cc := middleware.GetCustomContext(c)
+
+	items, err := h.memberRepo.ListPendingByEmail(cc.Email)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to list invitations"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *WorkspaceMemberHandler) Accept(c echo.Context) error {
+	return h.respond(c, model.MemberStatusAccepted)
+}
+
+func (h *WorkspaceMemberHandler) Decline(c echo.Context) error {
+	return h.respond(c, model.MemberStatusDeclined)
+}
+
+// respond answers the caller's pending invitation to the workspace in the path.
+// Invitations go to an address, so only an account that proved it owns the
+// address may answer them.
+func (h *WorkspaceMemberHandler) respond(c echo.Context, status string) error {
+	cc := middleware.GetCustomContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
+	}
+
+	user, err := h.userRepo.GetByID(cc.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update invitation"})
+	}
+	if user.EmailVerifiedAt == nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Verify your email first"})
+	}
+
+	m, err := h.memberRepo.GetByEmail(uint(id), cc.Email)
+	if err != nil || m.Status != model.MemberStatusPending {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Invitation not found"})
+	}
+
+	m.Status = status
+	if status == model.MemberStatusAccepted {
+		m.UserID = cc.UserID
+	}
+	if err := h.memberRepo.Update(m); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update invitation"})
+	}
+	return c.JSON(http.StatusOK, m)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+	"expenses-tracker/src/testdb"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAcceptRequiresVerifiedEmail(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Now()
+	owner := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR", EmailVerifiedAt: &now}
+	invitee := model.M_user{Name: "Invitee", Email: "invitee@example.com", Password: "x", Currency: "IDR"}
+	mustCreate(t, db, &owner, &invitee)
+	shared := model.M_workspace{UserID: owner.ID, Name: "Home", Currency: "IDR"}
+	mustCreate(t, db, &shared)
+	invitation := model.M_workspace_member{WorkspaceID: shared.ID, Email: invitee.Email, Role: model.WorkspaceRoleViewer, Status: model.MemberStatusPending}
+	mustCreate(t, db, &invitation)
+
+	h := NewWorkspaceMemberHandler(repository.NewWorkspaceMemberRepository(db), repository.NewWorkspaceRepository(db), repository.NewUserRepository(db), nil)
+	asInvitee := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.(*model.CustomContext).Email = invitee.Email
+			return next(c)
+		}
+	}
+	id := strconv.FormatUint(uint64(shared.ID), 10)
+
+	for name, respond := range map[string]echo.HandlerFunc{"accept": h.Accept, "decline": h.Decline} {
+		rec := call(asInvitee(respond), invitee.ID, 0, http.MethodPost, "/api/apps/workspaces/"+id+"/members/"+name, "", "id", id)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s with an unverified email: %d %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	var stored model.M_workspace_member
+	db.First(&stored, invitation.ID)
+	if stored.Status != model.MemberStatusPending || stored.UserID != 0 {
+		t.Errorf("invitation after refused answers: %+v", stored)
+	}
+
+	db.Model(&invitee).Update("email_verified_at", now)
+	rec := call(asInvitee(h.Accept), invitee.ID, 0, http.MethodPost, "/api/apps/workspaces/"+id+"/members/accept", "", "id", id)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("accept once verified: %d %s", rec.Code, rec.Body.String())
+	}
+	db.First(&stored, invitation.ID)
+	if stored.Status != model.MemberStatusAccepted || stored.UserID != invitee.ID {
+		t.Errorf("invitation after accepting: %+v", stored)
+	}
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/config"
+)
+
+// Email is a plain-text message to a single address.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// ErrNotConfigured is returned by New when no way of delivering mail is
+// configured. Sign-up, password resets and invitations depend on mail, so
+// the server does not start without it.
+var ErrNotConfigured = errors.New("mail delivery is not configured: set SMTP_HOST, or MAIL_SINK_DIR or MAIL_TO_LOG=true in development")
+
+// New returns the mailer cfg selects. The file and log mailers deliver
+// nothing and must be asked for explicitly; production accepts only SMTP.
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch {
+	case cfg.SMTP.Host != "":
+		return NewSMTPMailer(cfg.SMTP), nil
+	case config.IsProduction():
+		return nil, fmt.Errorf("%w; production requires SMTP_HOST", ErrNotConfigured)
+	case cfg.SinkDir != "":
+		log.Printf("Mailer: SMTP_HOST not set, writing mail to %s", cfg.SinkDir)
+		return NewFileMailer(cfg.SinkDir, cfg.SMTP.From), nil
+	case cfg.ToLog:
+		log.Println("Mailer: SMTP_HOST not set, writing mail to the log")
+		return NewLogMailer(), nil
+	default:
+		return nil, ErrNotConfigured
+	}
+}
+
+// message renders email as an RFC 5322 message.
+func message(from string, email Email) ([]byte, error) {
+	if strings.ContainsAny(email.To, "\r\n") {
+		return nil, fmt.Errorf("invalid email address %q", email.To)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", email.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String()), nil
+}
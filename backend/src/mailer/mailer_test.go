@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+
+	"expenses-tracker/src/config"
+)
+
+func TestNewRequiresExplicitConfiguration(t *testing.T) {
+	if _, err := New(config.MailConfig{}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("New without configuration = %v, want ErrNotConfigured", err)
+	}
+	if m, err := New(config.MailConfig{ToLog: true}); err != nil {
+		t.Errorf("New with ToLog = %v", err)
+	} else if _, ok := m.(*LogMailer); !ok {
+		t.Errorf("New with ToLog = %T, want *LogMailer", m)
+	}
+	if m, err := New(config.MailConfig{SMTP: config.SMTPConfig{Host: "smtp.example.com"}, ToLog: true}); err != nil {
+		t.Errorf("New with SMTP = %v", err)
+	} else if _, ok := m.(*SMTPMailer); !ok {
+		t.Errorf("New with SMTP = %T, want *SMTPMailer", m)
+	}
+
+	t.Setenv("APP_ENV", "production")
+	for _, cfg := range []config.MailConfig{{ToLog: true}, {SinkDir: t.TempDir()}} {
+		if _, err := New(cfg); !errors.Is(err, ErrNotConfigured) {
+			t.Errorf("New(%+v) in production = %v, want ErrNotConfigured", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+
+	"expenses-tracker/src/config"
+)
+
+// SMTPMailer sends mail through an SMTP server. Any server works, including
+// local stand-ins such as MailHog or Mailpit, which accept mail without
+// authentication.
+type SMTPMailer struct {
+	cfg config.SMTPConfig
+}
+
+func NewSMTPMailer(cfg config.SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	msg, err := message(m.cfg.From, email)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+
+	// smtp.SendMail has no context; run it aside so a hung server cannot
+	// outlive the caller's deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.cfg.From, []string{email.To}, msg)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
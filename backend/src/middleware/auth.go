@@ -1,31 +1,44 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"expenses-tracker/src/model"
 	"expenses-tracker/src/repository"
+	"expenses-tracker/src/security"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 )
 
-// CustomContextMiddleware verifies JWT access tokens and transparently handles
-// refresh using a database-backed refresh token store. When an access token is
-// expired but a valid refresh token is provided, it will:
-//   - validate the refresh token from X-Refresh-Token
-//   - enforce a usage limit using UsedCount
-//   - generate a new access and refresh token pair
-//   - persist the new refresh token and increment usage
+// CustomContextMiddleware verifies JWT access tokens against keys and
+// transparently handles refresh using a database-backed refresh token store.
+// When an access token is expired, or signed with a key that has since been
+// removed, but a valid refresh token is provided, it will:
+//   - rotate the refresh token from X-Refresh-Token, revoking its whole
+//     family if it had already been rotated (reuse means it was copied);
+//     a token rotated moments ago by a parallel request is refused without
+//     revoking anything, and the client retries with its successor
+//   - generate a new access token for the same session
 //   - send new tokens via X-Token and X-Refresh-Token headers
 //   - continue to the next handler without requiring the client to retry
+//
+// Personal access tokens are accepted in place of a JWT; see
+// authenticatePersonalAccessToken.
+//
+// The active workspace is taken from X-Workspace-Id and the caller's role in it
+// is resolved into the context. Requests naming a workspace the user neither
+// owns nor has joined are rejected before reaching a handler.
 func CustomContextMiddleware(
 	userRepo *repository.UserRepository,
 	refreshRepo *repository.RefreshTokenRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	patRepo *repository.PersonalAccessTokenRepository,
+	keys *security.KeySet,
 ) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -38,47 +51,51 @@ func CustomContextMiddleware(
 			}
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			secret := os.Getenv("JWT_SECRET")
-			if secret == "" {
-				secret = "your-secret-key-change-in-production"
+			if strings.HasPrefix(tokenString, model.PersonalAccessTokenPrefix) {
+				return authenticatePersonalAccessToken(c, next, tokenString, userRepo, workspaceRepo, patRepo)
 			}
 
 			// Helper to build custom context and continue request
-			setUserContextAndNext := func(user *model.M_user) error {
+			setUserContextAndNext := func(user *model.M_user, sessionID string) error {
 				// Read workspace ID from header
 				workspaceIDStr := c.Request().Header.Get("X-Workspace-Id")
 				var workspaceID uint = 0
 				if workspaceIDStr != "" {
-					if id, err := strconv.ParseUint(workspaceIDStr, 10, 64); err == nil {
-						workspaceID = uint(id)
+					id, err := strconv.ParseUint(workspaceIDStr, 10, 64)
+					if err != nil {
+						return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid workspace ID"})
 					}
+					workspaceID = uint(id)
 				}
-				
+
+				// The personal workspace (ID 0) always belongs to the caller
+				// and keeps its amounts in the caller's currency
+				role, currency := model.WorkspaceRoleOwner, user.Currency
+				if workspaceID != 0 {
+					ws, r, err := workspaceRepo.GetAccess(user.ID, workspaceID)
+					if err != nil {
+						return c.JSON(http.StatusForbidden, map[string]string{"message": "Workspace not found or access denied"})
+					}
+					role, currency = r, ws.Currency
+				}
+
 				cc := &model.CustomContext{
-					Context:     c,
-					UserID:      user.ID,
-					Email:       user.Email,
-					UserName:    user.Name,
-					WorkspaceID: workspaceID,
+					Context:       c,
+					UserID:        user.ID,
+					Email:         user.Email,
+					UserName:      user.Name,
+					WorkspaceID:   workspaceID,
+					WorkspaceRole: role,
+					SessionID:     sessionID,
+					Currency:      currency,
 				}
 				return next(cc)
 			}
 
 			// Parse access token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, echo.ErrUnauthorized
-				}
-				return []byte(secret), nil
-			})
-
-			if err == nil && token.Valid {
+			claims, err := keys.Parse(tokenString)
+			if err == nil {
 				// Valid access token
-				claims, ok := token.Claims.(jwt.MapClaims)
-				if !ok {
-					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid token claims"})
-				}
-
 				if tokenType, ok := claims["type"].(string); !ok || tokenType != "access" {
 					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid token type"})
 				}
@@ -94,29 +111,32 @@ func CustomContextMiddleware(
 					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "User not found"})
 				}
 
-				return setUserContextAndNext(user)
+				// Tokens issued before sessions were tracked carry no sid
+				sessionID, _ := claims["sid"].(string)
+				return setUserContextAndNext(user, sessionID)
 			}
 
 			// Check if token is expired and try refresh flow
-			if ve, ok := err.(*jwt.ValidationError); ok && (ve.Errors&jwt.ValidationErrorExpired) != 0 {
-				// Access token expired
+			ve, ok := err.(*jwt.ValidationError)
+			if errors.Is(err, security.ErrUnknownKey) || ok && (ve.Errors&jwt.ValidationErrorExpired) != 0 {
+				// Access token expired or its key retired
 				if refreshHeader == "" {
 					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "authorization token expired and refresh token missing"})
 				}
 
-				// Look up refresh token in DB
-				rt, err := refreshRepo.GetByToken(refreshHeader)
-				if err != nil {
-					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "invalid or expired refresh token"})
-				}
-
-				if time.Now().After(rt.ExpiresAt) {
+				// Exchange the refresh token for its successor
+				newRefreshToken, rt, err := refreshRepo.Rotate(refreshHeader, deviceID)
+				if errors.Is(err, repository.ErrRefreshTokenExpired) {
 					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "refresh token expired"})
 				}
-
-				// Enforce usage limit (e.g., 30 times)
-				if rt.UsedCount >= 30 {
-					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "refresh token exceeded usage limit"})
+				if errors.Is(err, repository.ErrRefreshTokenReused) {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "refresh token reuse detected, session signed out"})
+				}
+				if errors.Is(err, repository.ErrRefreshTokenRotated) {
+					return c.JSON(http.StatusConflict, map[string]string{"message": "refresh token was just rotated, retry with the newer one"})
+				}
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "invalid or expired refresh token"})
 				}
 
 				// Load user
@@ -131,29 +151,19 @@ func CustomContextMiddleware(
 					"email":  user.Email,
 					"exp":    time.Now().Add(time.Minute * 3).Unix(),
 					"type":   "access",
+					"sid":    rt.FamilyID,
 				}
-				accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-				newAccessToken, err := accessToken.SignedString([]byte(secret))
+				newAccessToken, err := keys.Sign(claims)
 				if err != nil {
 					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "failed to generate new access token"})
 				}
 
-				// Optionally rotate refresh token: here we keep the same token string,
-				// but increment usage counter and update device ID if provided.
-				rt.UsedCount++
-				if deviceID != "" {
-					rt.DeviceID = deviceID
-				}
-				if err := refreshRepo.Save(rt); err != nil {
-					return c.JSON(http.StatusUnauthorized, map[string]string{"message": "failed to update refresh token usage"})
-				}
-
 				// Send new tokens via headers (no need for client to retry request)
 				c.Response().Header().Set("X-Token", newAccessToken)
-				c.Response().Header().Set("X-Refresh-Token", rt.Token)
+				c.Response().Header().Set("X-Refresh-Token", newRefreshToken)
 
 				// Continue with request using the user from refresh token
-				return setUserContextAndNext(user)
+				return setUserContextAndNext(user, rt.FamilyID)
 			}
 
 			// Other invalid cases
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiPrefix is where every API route is mounted.
+const apiPrefix = "/api/apps"
+
+// authenticatePersonalAccessToken builds the request context from a personal
+// access token. The token decides the workspace; an X-Workspace-Id header
+// naming any other workspace is refused. The caller's role there is capped
+// by the token's scope.
+func authenticatePersonalAccessToken(
+	c echo.Context,
+	next echo.HandlerFunc,
+	token string,
+	userRepo *repository.UserRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	patRepo *repository.PersonalAccessTokenRepository,
+) error {
+	pat, err := patRepo.GetActive(token)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "invalid or expired access token"})
+	}
+	user, err := userRepo.GetByID(pat.UserID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "user not found for access token"})
+	}
+
+	if header := c.Request().Header.Get("X-Workspace-Id"); header != "" && header != strconv.FormatUint(uint64(pat.WorkspaceID), 10) {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "This access token is bound to another workspace"})
+	}
+	role, currency := model.WorkspaceRoleOwner, user.Currency
+	if pat.WorkspaceID != 0 {
+		ws, r, err := workspaceRepo.GetAccess(user.ID, pat.WorkspaceID)
+		if err != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{"message": "Workspace not found or access denied"})
+		}
+		role, currency = r, ws.Currency
+	}
+
+	if !tokenScopeAllows(pat.Scope, c.Request().Method, c.Path()) {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "This access token's scope does not allow this request"})
+	}
+	// Workspace routes address their workspace by path rather than header
+	if strings.HasPrefix(c.Path(), apiPrefix+"/workspaces/:id") && c.Param("id") != strconv.FormatUint(uint64(pat.WorkspaceID), 10) {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "This access token is bound to another workspace"})
+	}
+
+	if err := patRepo.Touch(pat.ID, c.RealIP(), time.Now()); err != nil {
+		log.Printf("Access token %d: record use: %v", pat.ID, err)
+	}
+
+	cc := &model.CustomContext{
+		Context:       c,
+		UserID:        user.ID,
+		Email:         user.Email,
+		UserName:      user.Name,
+		WorkspaceID:   pat.WorkspaceID,
+		WorkspaceRole: capRole(role, pat.Scope),
+		TokenScope:    pat.Scope,
+		Currency:      currency,
+	}
+	return next(cc)
+}
+
+// tokenScopeAllows reports whether a token of scope may call the route path
+// with method. Routes that manage sign-in, sessions or tokens stay reserved
+// to interactive sessions whatever the scope.
+func tokenScopeAllows(scope, method, path string) bool {
+	route := strings.TrimPrefix(path, apiPrefix)
+	safe := method == http.MethodGet || method == http.MethodHead
+
+	if strings.HasPrefix(route, "/auth/") || strings.HasPrefix(route, "/tokens") {
+		return route == "/auth/profile" && safe
+	}
+	if route == "/workspaces" || route == "/workspaces/invitations" {
+		// Both reach beyond the token's workspace: listing or creating
+		// workspaces, and invitations to others
+		return false
+	}
+	switch scope {
+	case model.TokenScopeRead:
+		return safe
+	case model.TokenScopeWriteExpenses:
+		return safe || route == "/expenses" || strings.HasPrefix(route, "/expenses/")
+	case model.TokenScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// capRole lowers role to what scope permits.
+func capRole(role, scope string) string {
+	switch scope {
+	case model.TokenScopeRead:
+		return model.WorkspaceRoleViewer
+	case model.TokenScopeWriteExpenses:
+		if role == model.WorkspaceRoleOwner {
+			return model.WorkspaceRoleEditor
+		}
+	}
+	return role
+}
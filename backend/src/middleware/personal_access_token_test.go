@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"expenses-tracker/src/model"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope, method, path string
+		want                bool
+	}{
+		{model.TokenScopeRead, http.MethodGet, apiPrefix + "/expenses", true},
+		{model.TokenScopeRead, http.MethodPost, apiPrefix + "/expenses", false},
+		{model.TokenScopeWriteExpenses, http.MethodPost, apiPrefix + "/expenses", true},
+		{model.TokenScopeWriteExpenses, http.MethodPost, apiPrefix + "/incomes", false},
+		{model.TokenScopeAdmin, http.MethodGet, apiPrefix + "/auth/profile", true},
+		{model.TokenScopeAdmin, http.MethodPut, apiPrefix + "/auth/profile", false},
+		{model.TokenScopeAdmin, http.MethodGet, apiPrefix + "/tokens", false},
+
+		// Other workspaces stay out of reach whatever the scope
+		{model.TokenScopeAdmin, http.MethodGet, apiPrefix + "/workspaces", false},
+		{model.TokenScopeAdmin, http.MethodPost, apiPrefix + "/workspaces", false},
+		{model.TokenScopeAdmin, http.MethodGet, apiPrefix + "/workspaces/invitations", false},
+		{model.TokenScopeRead, http.MethodGet, apiPrefix + "/workspaces/:id", true},
+	}
+	for _, tt := range tests {
+		if got := tokenScopeAllows(tt.scope, tt.method, tt.path); got != tt.want {
+			t.Errorf("tokenScopeAllows(%s, %s %s) = %v, want %v", tt.scope, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
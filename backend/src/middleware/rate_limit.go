@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimit allows each client address a burst of limit requests, refilled
+// evenly over window, across the routes the returned middleware wraps. Extra
+// requests get 429 with a Retry-After header. Counts are kept in memory, so
+// every server instance limits on its own.
+func RateLimit(limit int, window time.Duration) echo.MiddlewareFunc {
+	l := &rateLimiter{
+		limit:   float64(limit),
+		rate:    float64(limit) / window.Seconds(),
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			wait := l.take(c.RealIP(), time.Now())
+			if wait > 0 {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"message": "Too many requests, please slow down"})
+			}
+			return next(c)
+		}
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     float64
+	rate      float64 // tokens per second
+	window    time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// take spends a token of key and returns zero, or how long until one is available.
+func (l *rateLimiter) take(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A bucket idle for a whole window is full again and can be dropped
+	if now.Sub(l.lastSweep) > l.window {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > l.window {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.limit {
+		b.tokens = l.limit
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
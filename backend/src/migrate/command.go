@@ -0,0 +1,151 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"expenses-tracker/src/config"
+)
+
+const usage = `usage: migrate <command>
+
+  up [N]         apply all pending migrations, or the next N
+  down [N]       revert the last applied migration, or the last N
+  status         list migrations and when they were applied
+  create [-dir DIR] NAME
+                 add empty up and down scripts to DIR, by default ` + Dir + `
+                 in the backend module around the working directory`
+
+// Command runs the migrate subcommand with args, writing progress to out.
+func Command(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	if args[0] == "create" {
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		fs.SetOutput(out)
+		dir := fs.String("dir", "", "directory to write the scripts to")
+		if err := fs.Parse(args[1:]); errors.Is(err, flag.ErrHelp) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New(usage)
+		}
+		if *dir == "" {
+			root, err := moduleRoot()
+			if err != nil {
+				return err
+			}
+			*dir = filepath.Join(root, Dir)
+		}
+		up, down, err := Create(*dir, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\nCreated %s\n", up, down)
+		return nil
+	}
+
+	steps := 0
+	switch args[0] {
+	case "up", "down":
+		if args[0] == "down" {
+			steps = 1
+		}
+		if len(args) > 2 {
+			return errors.New(usage)
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations: %s", args[1])
+			}
+			steps = n
+		}
+	case "status":
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+	default:
+		return errors.New(usage)
+	}
+
+	db, err := config.ConnectPostgreSQL()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	m, err := New(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx, steps)
+		for _, mig := range done {
+			fmt.Fprintf(out, "Applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(out, "Nothing to apply")
+		}
+		return err
+	case "down":
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			fmt.Fprintf(out, "Reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(out, "Nothing to revert")
+		}
+		return err
+	default:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+}
+
+// moduleRoot finds the backend module the working directory is in, so create
+// writes to the source tree wherever it is run from.
+func moduleRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil && strings.HasPrefix(strings.TrimSpace(string(b)), "module expenses-tracker") {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("not inside the backend module; pass -dir")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,303 @@
+// Package migrate applies the versioned SQL migrations that define the
+// database schema. Each migration is a pair of scripts in sql/, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, that are compiled
+// into the binary. Applied versions are recorded in schema_migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// Dir is where the migrations live, relative to the backend module root.
+const Dir = "src/migrate/sql"
+
+// lockID serialises migration runs across processes with an advisory lock.
+const lockID = 72707369
+
+var (
+	fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nonWord  = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ErrPending is returned by Check when migrations have not been applied yet.
+var ErrPending = errors.New("database schema is not up to date")
+
+// Migration is one schema change and the script that reverts it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status pairs a known migration with when it was applied, if it was.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator runs migrations against one database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations compiled into the binary.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(embedded)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the migrations in the sql directory of fsys, oldest first. Every
+// version needs both scripts and a single name.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", e.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := fs.ReadFile(fsys, path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureTable creates schema_migrations on first use.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+// applied returns when each applied version was applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration, oldest first.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Check fails with ErrPending unless every known migration has been applied.
+// Versions applied by a newer binary are fine.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s pending", ErrPending, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up applies up to steps pending migrations, oldest first, or all of them
+// when steps is 0. It returns the migrations it applied.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		ran, err := m.run(ctx, mig, true)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the steps most recently applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		ran, err := m.run(ctx, mig, false)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// run applies or reverts mig in a transaction together with its
+// schema_migrations row. Another process may have got there first while this
+// one waited for the lock, in which case it reports false.
+func (m *Migrator) run(ctx context.Context, mig Migration, up bool) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockID); err != nil {
+		return false, err
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = $1", mig.Version).Scan(&count); err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	script := mig.Down
+	if up {
+		script = mig.Up
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Create writes empty up and down scripts for a new migration to dir,
+// numbered after the newest one there, and returns their paths.
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = nonWord.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	var latest int64
+	for _, e := range entries {
+		if m := fileName.FindStringSubmatch(e.Name()); m != nil {
+			if v, _ := strconv.ParseInt(m[1], 10, 64); v > latest {
+				latest = v
+			}
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", latest+1, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- Write the schema change here.\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Write the statements that undo the up script here.\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
@@ -0,0 +1,261 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/migrate"
+	"expenses-tracker/src/testdb"
+
+	"gorm.io/gorm"
+)
+
+// The models as they were before versioned migrations, which AutoMigrate
+// turned into the schema of existing installs.
+type M_user struct {
+	ID                   uint   `gorm:"primaryKey"`
+	Name                 string `gorm:"not null"`
+	Email                string `gorm:"uniqueIndex;not null"`
+	Password             string `gorm:"not null"`
+	Currency             string `gorm:"default:'IDR'"`
+	FirstSigninCompleted bool   `gorm:"default:false"`
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	DeletedAt            gorm.DeletedAt `gorm:"index"`
+}
+
+type M_workspace struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index;not null"`
+	Name        string `gorm:"not null"`
+	Description string `gorm:"type:text"`
+	Slug        string `gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type M_category struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null"`
+	Slug        string `gorm:"default:null;index:idx_category_slug_user"`
+	Type        string `gorm:"not null;default:'expense';check:type IN ('income','expense')"`
+	IsActive    bool   `gorm:"default:true"`
+	Sequence    int    `gorm:"default:0;index:idx_category_sequence"`
+	UserID      uint   `gorm:"default:null;index:idx_category_user_id;index:idx_category_slug_user"`
+	WorkspaceID uint   `gorm:"index;not null;default:0"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type T_expense struct {
+	ID          uint         `gorm:"primaryKey"`
+	UserID      uint         `gorm:"index"`
+	WorkspaceID uint         `gorm:"index;not null;default:0"`
+	Categories  []M_category `gorm:"many2many:t_expense_categories;constraint:OnDelete:CASCADE"`
+	Date        time.Time    `gorm:"type:date;index"`
+	Notes       string       `gorm:"type:text"`
+	Amount      float64      `gorm:"type:decimal(15,2)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type T_income struct {
+	ID          uint         `gorm:"primaryKey"`
+	UserID      uint         `gorm:"index"`
+	WorkspaceID uint         `gorm:"index;not null;default:0"`
+	Categories  []M_category `gorm:"many2many:t_income_categories;constraint:OnDelete:CASCADE"`
+	Date        time.Time    `gorm:"type:date"`
+	Amount      float64      `gorm:"type:decimal(15,2)"`
+	Notes       string       `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type R_balance struct {
+	ID          uint    `gorm:"primaryKey"`
+	UserID      uint    `gorm:"index"`
+	WorkspaceID uint    `gorm:"index;not null;default:0"`
+	Amount      float64 `gorm:"type:decimal(15,2);default:0"`
+	Notes       string  `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type R_budget struct {
+	ID          uint       `gorm:"primaryKey"`
+	UserID      uint       `gorm:"index"`
+	WorkspaceID uint       `gorm:"index;not null;default:0"`
+	CategoryID  uint       `gorm:"index"`
+	Category    M_category `gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE"`
+	Month       string     `gorm:"type:varchar(7);index"`
+	Amount      float64    `gorm:"type:decimal(15,2)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type M_expense_template struct {
+	ID         uint         `gorm:"primaryKey"`
+	UserID     uint         `gorm:"index"`
+	Name       string       `gorm:"not null"`
+	Categories []M_category `gorm:"many2many:m_expense_template_categories;constraint:OnDelete:CASCADE"`
+	Amount     float64      `gorm:"type:decimal(15,2)"`
+	Notes      string       `gorm:"type:text"`
+	IsActive   bool         `gorm:"default:true"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+type M_quick_amount struct {
+	ID          uint `gorm:"primaryKey"`
+	UserID      uint `gorm:"index"`
+	WorkspaceID uint `gorm:"index;not null;default:0"`
+	Value       float64
+}
+
+type M_refresh_token struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Token     string    `gorm:"uniqueIndex;not null"`
+	DeviceID  string    `gorm:"size:255"`
+	UsedCount int       `gorm:"default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// columns lists the tables of db's schema with their columns.
+func columns(t *testing.T, db *gorm.DB) map[string]map[string]bool {
+	t.Helper()
+	var rows []struct{ TableName, ColumnName string }
+	if err := db.Raw(`SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema()`).
+		Scan(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]map[string]bool{}
+	for _, r := range rows {
+		if tables[r.TableName] == nil {
+			tables[r.TableName] = map[string]bool{}
+		}
+		tables[r.TableName][r.ColumnName] = true
+	}
+	return tables
+}
+
+func TestUpFromAutoMigrateBaseline(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+	if err := db.AutoMigrate(&M_user{}, &T_expense{}, &M_category{}, &M_expense_template{}, &T_income{},
+		&R_balance{}, &R_budget{}, &M_refresh_token{}, &M_quick_amount{}, &M_workspace{}); err != nil {
+		t.Fatal(err)
+	}
+
+	user := M_user{Name: "Owner", Email: "owner@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	food := M_category{Name: "Food", UserID: user.ID}
+	if err := db.Create(&food).Error; err != nil {
+		t.Fatal(err)
+	}
+	expense := T_expense{UserID: user.ID, Date: time.Now(), Amount: 12.5, Categories: []M_category{food}}
+	if err := db.Create(&expense).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range []float64{10, 20} {
+		if err := db.Create(&R_balance{UserID: user.ID, Amount: amount}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&M_refresh_token{UserID: user.ID, Token: "legacy", ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("migrate up from the baseline: %v", err)
+	}
+
+	// The upgraded schema has every column of a fresh one
+	fresh := columns(t, testdb.Open(t))
+	upgraded := columns(t, db)
+	for table, cols := range fresh {
+		for col := range cols {
+			if !upgraded[table][col] {
+				t.Errorf("upgraded schema lacks %s.%s", table, col)
+			}
+		}
+	}
+
+	var balances []struct{ Amount float64 }
+	db.Raw(`SELECT amount FROM r_balances WHERE user_id = ?`, user.ID).Scan(&balances)
+	if len(balances) != 1 || balances[0].Amount != 20 {
+		t.Errorf("balances after migrating: %+v, want the newer one", balances)
+	}
+	var splits int64
+	db.Table("t_expense_splits").Where("expense_id = ? AND category_id = ?", expense.ID, food.ID).Count(&splits)
+	if splits != 1 {
+		t.Errorf("%d splits for the legacy expense, want 1", splits)
+	}
+	var hashed int64
+	db.Table("m_refresh_tokens").Where("token_hash IS NOT NULL AND family_id IS NOT NULL").Count(&hashed)
+	if hashed != 1 {
+		t.Errorf("%d legacy refresh tokens hashed, want 1", hashed)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	if err := migrate.Command([]string{"create", "-dir", dir, "Add notes"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0001_add_notes.up.sql", "0001_add_notes.down.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Without -dir the scripts go to the module the command runs in
+	root := t.TempDir()
+	sqlDir := filepath.Join(root, migrate.Dir)
+	if err := os.MkdirAll(sqlDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module expenses-tracker\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(root, "src")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	out.Reset()
+	if err := migrate.Command([]string{"create", "add_more"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), filepath.Join(sqlDir, "0001_add_more.up.sql")) {
+		t.Errorf("create from a subdirectory: %s", out.String())
+	}
+}
@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS "m_personal_access_tokens";
+DROP TABLE IF EXISTS "t_audit_logs";
+DROP TABLE IF EXISTS "m_login_throttles";
+DROP TABLE IF EXISTS "m_user_identities";
+DROP TABLE IF EXISTS "m_user_tokens";
+DROP TABLE IF EXISTS "m_recovery_codes";
+DROP TABLE IF EXISTS "m_refresh_tokens";
+DROP TABLE IF EXISTS "m_notification_channels";
+DROP TABLE IF EXISTS "t_notifications";
+DROP TABLE IF EXISTS "t_budget_alerts";
+DROP TABLE IF EXISTS "m_budget_thresholds";
+DROP TABLE IF EXISTS "t_transfers";
+DROP TABLE IF EXISTS "m_recurring_skips";
+DROP TABLE IF EXISTS "m_recurring_rule_categories";
+DROP TABLE IF EXISTS "m_recurring_rules";
+DROP TABLE IF EXISTS "m_quick_amounts";
+DROP TABLE IF EXISTS "m_expense_template_categories";
+DROP TABLE IF EXISTS "m_expense_templates";
+DROP TABLE IF EXISTS "r_budgets";
+DROP TABLE IF EXISTS "r_balances";
+DROP TABLE IF EXISTS "t_income_categories";
+DROP TABLE IF EXISTS "t_incomes";
+DROP TABLE IF EXISTS "t_expense_splits";
+DROP TABLE IF EXISTS "t_expenses";
+DROP TABLE IF EXISTS "m_accounts";
+DROP TABLE IF EXISTS "m_categories";
+DROP TABLE IF EXISTS "m_workspace_members";
+DROP TABLE IF EXISTS "m_workspaces";
+DROP TABLE IF EXISTS "m_users";
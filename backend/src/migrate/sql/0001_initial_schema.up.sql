@@ -0,0 +1,513 @@
+-- Baseline: the schema as AutoMigrate left it. Existing installs adopt
+-- versioned migrations by applying this like a fresh one: tables created by
+-- an older AutoMigrate get the columns added since, before the indexes on
+-- them, and every other statement is a no-op there.
+
+CREATE TABLE IF NOT EXISTS "m_users" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "email" text NOT NULL,
+    "email_verified_at" timestamptz,
+    "pending_email" text,
+    "password" text NOT NULL,
+    "currency" text DEFAULT 'IDR',
+    "first_signin_completed" boolean DEFAULT false,
+    "totp_secret" text,
+    "totp_enabled" boolean DEFAULT false,
+    "totp_last_step" bigint DEFAULT 0,
+    "deletion_scheduled_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz;
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "pending_email" text;
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "totp_enabled" boolean DEFAULT false;
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint DEFAULT 0;
+ALTER TABLE "m_users" ADD COLUMN IF NOT EXISTS "deletion_scheduled_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_m_users_deleted_at" ON "m_users" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_m_users_email" ON "m_users" ("email");
+
+CREATE TABLE IF NOT EXISTS "m_workspaces" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "description" text,
+    "slug" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_m_workspaces_deleted_at" ON "m_workspaces" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_workspaces_slug" ON "m_workspaces" ("slug");
+CREATE INDEX IF NOT EXISTS "idx_m_workspaces_user_id" ON "m_workspaces" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "m_workspace_members" (
+    "id" bigserial,
+    "workspace_id" bigint NOT NULL,
+    "user_id" bigint,
+    "email" text NOT NULL,
+    "role" text NOT NULL DEFAULT 'viewer',
+    "status" text NOT NULL DEFAULT 'pending',
+    "invited_by" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_m_workspace_members_workspace" FOREIGN KEY ("workspace_id") REFERENCES "m_workspaces"("id") ON DELETE CASCADE,
+    CONSTRAINT "chk_m_workspace_members_role" CHECK (role IN ('owner','editor','viewer')),
+    CONSTRAINT "chk_m_workspace_members_status" CHECK (status IN ('pending','accepted','declined'))
+);
+CREATE INDEX IF NOT EXISTS "idx_m_workspace_members_deleted_at" ON "m_workspace_members" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_workspace_members_user_id" ON "m_workspace_members" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_workspace_member_email" ON "m_workspace_members" ("workspace_id","email");
+
+CREATE TABLE IF NOT EXISTS "m_categories" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "slug" text DEFAULT null,
+    "type" text NOT NULL DEFAULT 'expense',
+    "is_active" boolean DEFAULT true,
+    "sequence" bigint DEFAULT 0,
+    "user_id" bigint DEFAULT null,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "rollover_since" varchar(7),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_categories_type" CHECK (type IN ('income','expense'))
+);
+ALTER TABLE "m_categories" ADD COLUMN IF NOT EXISTS "rollover_since" varchar(7);
+CREATE INDEX IF NOT EXISTS "idx_category_sequence" ON "m_categories" ("sequence");
+CREATE INDEX IF NOT EXISTS "idx_category_slug_user" ON "m_categories" ("slug","user_id");
+CREATE INDEX IF NOT EXISTS "idx_category_user_id" ON "m_categories" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_categories_deleted_at" ON "m_categories" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_categories_workspace_id" ON "m_categories" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_accounts" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "name" text NOT NULL,
+    "type" text NOT NULL DEFAULT 'cash',
+    "opening_balance" decimal(15,2) DEFAULT 0,
+    "is_archived" boolean DEFAULT false,
+    "sequence" bigint DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_accounts_type" CHECK (type IN ('cash','bank','credit_card','e_wallet'))
+);
+CREATE INDEX IF NOT EXISTS "idx_m_accounts_deleted_at" ON "m_accounts" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_accounts_user_id" ON "m_accounts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_accounts_workspace_id" ON "m_accounts" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "t_expenses" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "date" date,
+    "notes" text,
+    "amount" decimal(15,2),
+    "account_id" bigint,
+    "recurring_rule_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "t_expenses" ADD COLUMN IF NOT EXISTS "account_id" bigint;
+ALTER TABLE "t_expenses" ADD COLUMN IF NOT EXISTS "recurring_rule_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_account_id" ON "t_expenses" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_date" ON "t_expenses" ("date");
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_deleted_at" ON "t_expenses" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_recurring_rule_id" ON "t_expenses" ("recurring_rule_id");
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_user_id" ON "t_expenses" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_expenses_workspace_id" ON "t_expenses" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "t_expense_splits" (
+    "id" bigserial,
+    "expense_id" bigint NOT NULL,
+    "category_id" bigint NOT NULL,
+    "amount" decimal(15,2),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_t_expense_splits_category" FOREIGN KEY ("category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_t_expenses_splits" FOREIGN KEY ("expense_id") REFERENCES "t_expenses"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_t_expense_splits_category_id" ON "t_expense_splits" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_t_expense_splits_expense_id" ON "t_expense_splits" ("expense_id");
+
+CREATE TABLE IF NOT EXISTS "t_incomes" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "date" date,
+    "amount" decimal(15,2),
+    "notes" text,
+    "account_id" bigint,
+    "recurring_rule_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "t_incomes" ADD COLUMN IF NOT EXISTS "account_id" bigint;
+ALTER TABLE "t_incomes" ADD COLUMN IF NOT EXISTS "recurring_rule_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_t_incomes_account_id" ON "t_incomes" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_t_incomes_deleted_at" ON "t_incomes" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_t_incomes_recurring_rule_id" ON "t_incomes" ("recurring_rule_id");
+CREATE INDEX IF NOT EXISTS "idx_t_incomes_user_id" ON "t_incomes" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_incomes_workspace_id" ON "t_incomes" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "t_income_categories" (
+    "t_income_id" bigint,
+    "m_category_id" bigint,
+    PRIMARY KEY ("t_income_id","m_category_id"),
+    CONSTRAINT "fk_t_income_categories_t_income" FOREIGN KEY ("t_income_id") REFERENCES "t_incomes"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_t_income_categories_m_category" FOREIGN KEY ("m_category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "r_balances" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "amount" decimal(15,2) DEFAULT 0,
+    "notes" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+-- Balances were not unique per user and workspace before this index. Keep the
+-- live, most recently updated row of each; the others are stale copies.
+DELETE FROM "r_balances" WHERE "id" IN (
+    SELECT "id" FROM (
+        SELECT "id", ROW_NUMBER() OVER (
+            PARTITION BY "user_id", "workspace_id"
+            ORDER BY "deleted_at" IS NULL DESC, "updated_at" DESC NULLS LAST, "id" DESC
+        ) AS "position"
+        FROM "r_balances"
+    ) ranked
+    WHERE "position" > 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_balance_user_workspace" ON "r_balances" ("user_id","workspace_id");
+CREATE INDEX IF NOT EXISTS "idx_r_balances_deleted_at" ON "r_balances" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_r_balances_user_id" ON "r_balances" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_r_balances_workspace_id" ON "r_balances" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "r_budgets" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "category_id" bigint,
+    "month" varchar(7),
+    "amount" decimal(15,2),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_r_budgets_category" FOREIGN KEY ("category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_r_budgets_category_id" ON "r_budgets" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_r_budgets_deleted_at" ON "r_budgets" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_r_budgets_month" ON "r_budgets" ("month");
+CREATE INDEX IF NOT EXISTS "idx_r_budgets_user_id" ON "r_budgets" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_r_budgets_workspace_id" ON "r_budgets" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_expense_templates" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "name" text NOT NULL,
+    "amount" decimal(15,2),
+    "notes" text,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "m_expense_templates" ADD COLUMN IF NOT EXISTS "workspace_id" bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS "idx_m_expense_templates_deleted_at" ON "m_expense_templates" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_expense_templates_user_id" ON "m_expense_templates" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_expense_templates_workspace_id" ON "m_expense_templates" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_expense_template_categories" (
+    "m_expense_template_id" bigint,
+    "m_category_id" bigint,
+    PRIMARY KEY ("m_expense_template_id","m_category_id"),
+    CONSTRAINT "fk_m_expense_template_categories_m_expense_template" FOREIGN KEY ("m_expense_template_id") REFERENCES "m_expense_templates"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_m_expense_template_categories_m_category" FOREIGN KEY ("m_category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "m_quick_amounts" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "value" decimal,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_m_quick_amounts_user_id" ON "m_quick_amounts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_quick_amounts_workspace_id" ON "m_quick_amounts" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_recurring_rules" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "type" text NOT NULL DEFAULT 'expense',
+    "account_id" bigint,
+    "amount" decimal(15,2),
+    "notes" text,
+    "frequency" text NOT NULL,
+    "interval" bigint NOT NULL DEFAULT 1,
+    "anchor" varchar(32),
+    "start_date" date NOT NULL,
+    "end_date" date,
+    "max_occurrences" bigint,
+    "occurrence_count" bigint DEFAULT 0,
+    "next_date" date,
+    "is_paused" boolean DEFAULT false,
+    "is_finished" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_recurring_rules_type" CHECK (type IN ('income','expense')),
+    CONSTRAINT "chk_m_recurring_rules_frequency" CHECK (frequency IN ('daily','weekly','monthly','yearly'))
+);
+ALTER TABLE "m_recurring_rules" ADD COLUMN IF NOT EXISTS "account_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_rules_deleted_at" ON "m_recurring_rules" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_rules_is_finished" ON "m_recurring_rules" ("is_finished");
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_rules_next_date" ON "m_recurring_rules" ("next_date");
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_rules_user_id" ON "m_recurring_rules" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_rules_workspace_id" ON "m_recurring_rules" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_recurring_rule_categories" (
+    "m_recurring_rule_id" bigint,
+    "m_category_id" bigint,
+    PRIMARY KEY ("m_recurring_rule_id","m_category_id"),
+    CONSTRAINT "fk_m_recurring_rule_categories_m_recurring_rule" FOREIGN KEY ("m_recurring_rule_id") REFERENCES "m_recurring_rules"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_m_recurring_rule_categories_m_category" FOREIGN KEY ("m_category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "m_recurring_skips" (
+    "id" bigserial,
+    "recurring_rule_id" bigint NOT NULL,
+    "date" date NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_m_recurring_rules_skips" FOREIGN KEY ("recurring_rule_id") REFERENCES "m_recurring_rules"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_m_recurring_skips_recurring_rule_id" ON "m_recurring_skips" ("recurring_rule_id");
+
+CREATE TABLE IF NOT EXISTS "t_transfers" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "date" date,
+    "amount" decimal(15,2),
+    "notes" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_t_transfers_from_account" FOREIGN KEY ("from_account_id") REFERENCES "m_accounts"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_t_transfers_to_account" FOREIGN KEY ("to_account_id") REFERENCES "m_accounts"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_date" ON "t_transfers" ("date");
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_deleted_at" ON "t_transfers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_from_account_id" ON "t_transfers" ("from_account_id");
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_to_account_id" ON "t_transfers" ("to_account_id");
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_user_id" ON "t_transfers" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_transfers_workspace_id" ON "t_transfers" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_budget_thresholds" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "category_id" bigint NOT NULL,
+    "percent" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_m_budget_thresholds_category" FOREIGN KEY ("category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE,
+    CONSTRAINT "chk_m_budget_thresholds_percent" CHECK (percent > 0)
+);
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_category_id" ON "m_budget_thresholds" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_deleted_at" ON "m_budget_thresholds" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_user_id" ON "m_budget_thresholds" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_workspace_id" ON "m_budget_thresholds" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "t_budget_alerts" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "threshold_id" bigint NOT NULL,
+    "category_id" bigint NOT NULL,
+    "month" varchar(7) NOT NULL,
+    "percent" bigint,
+    "available" decimal(15,2),
+    "spent" decimal(15,2),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_budget_alert_threshold_month" ON "t_budget_alerts" ("threshold_id","month");
+CREATE INDEX IF NOT EXISTS "idx_t_budget_alerts_category_id" ON "t_budget_alerts" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_t_budget_alerts_user_id" ON "t_budget_alerts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_budget_alerts_workspace_id" ON "t_budget_alerts" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "t_notifications" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "kind" varchar(64) NOT NULL,
+    "subject" text NOT NULL,
+    "body" text,
+    "data" jsonb,
+    "read_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_t_notifications_created_at" ON "t_notifications" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_t_notifications_user_id" ON "t_notifications" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_notifications_workspace_id" ON "t_notifications" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_notification_channels" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "type" text NOT NULL,
+    "target" text NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_notification_channels_type" CHECK (type IN ('webhook','email'))
+);
+CREATE INDEX IF NOT EXISTS "idx_m_notification_channels_deleted_at" ON "m_notification_channels" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_notification_channels_user_id" ON "m_notification_channels" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_notification_channels_workspace_id" ON "m_notification_channels" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_refresh_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" varchar(64),
+    "family_id" varchar(64),
+    "device_id" varchar(255),
+    "user_agent" varchar(512),
+    "used_count" bigint DEFAULT 0,
+    "started_at" timestamptz,
+    "last_used_at" timestamptz,
+    "rotated_at" timestamptz,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "token_hash" varchar(64);
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "family_id" varchar(64);
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "user_agent" varchar(512);
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "started_at" timestamptz;
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "last_used_at" timestamptz;
+ALTER TABLE "m_refresh_tokens" ADD COLUMN IF NOT EXISTS "rotated_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_m_refresh_tokens_deleted_at" ON "m_refresh_tokens" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_m_refresh_tokens_family_id" ON "m_refresh_tokens" ("family_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_m_refresh_tokens_token_hash" ON "m_refresh_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_m_refresh_tokens_user_id" ON "m_refresh_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "m_recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_m_recovery_codes_user_id" ON "m_recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "m_user_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "purpose" varchar(32) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "email" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_user_tokens_purpose" CHECK (purpose IN ('email_verification','password_reset','oidc_login'))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_m_user_tokens_token_hash" ON "m_user_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_m_user_tokens_user_id" ON "m_user_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "m_user_identities" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "issuer" varchar(255) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "email" text,
+    "last_login_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_m_user_identities_user_id" ON "m_user_identities" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_identity_subject" ON "m_user_identities" ("issuer","subject");
+
+CREATE TABLE IF NOT EXISTS "m_login_throttles" (
+    "key" varchar(320),
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failure_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "t_audit_logs" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "action" varchar(32) NOT NULL,
+    "entity" varchar(32) NOT NULL,
+    "entity_id" bigint,
+    "before" jsonb,
+    "after" jsonb,
+    "ip" varchar(64),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_t_audit_logs_created_at" ON "t_audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_t_audit_logs_user_id" ON "t_audit_logs" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_t_audit_logs_workspace_id" ON "t_audit_logs" ("workspace_id");
+
+CREATE TABLE IF NOT EXISTS "m_personal_access_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "name" varchar(100) NOT NULL,
+    "scope" varchar(32) NOT NULL,
+    "prefix" varchar(16) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "last_used_ip" varchar(64),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_m_personal_access_tokens_scope" CHECK (scope IN ('read','write_expenses','admin'))
+);
+CREATE INDEX IF NOT EXISTS "idx_m_personal_access_tokens_deleted_at" ON "m_personal_access_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_m_personal_access_tokens_token_hash" ON "m_personal_access_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_m_personal_access_tokens_user_id" ON "m_personal_access_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_personal_access_tokens_workspace_id" ON "m_personal_access_tokens" ("workspace_id");
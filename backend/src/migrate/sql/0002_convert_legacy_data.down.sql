@@ -0,0 +1,2 @@
+-- The conversions cannot be undone: plaintext refresh tokens are not kept and
+-- splits already are the only record of expense categories.
@@ -0,0 +1,42 @@
+-- Data conversions that used to run on every start. Each does nothing on a
+-- database that never held the legacy data.
+
+-- Refresh tokens saved in plaintext before hashing was introduced: store the
+-- hash, make each its own session family and drop the plaintext column
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'm_refresh_tokens' AND column_name = 'token') THEN
+        UPDATE m_refresh_tokens
+        SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+            family_id = 'legacy-' || id,
+            started_at = created_at
+        WHERE token_hash IS NULL OR token_hash = '';
+        ALTER TABLE m_refresh_tokens DROP COLUMN token;
+    END IF;
+END $$;
+
+-- Expenses tagged through the old many2many join become category splits. An
+-- expense with several categories is divided evenly, with any rounding
+-- remainder going to the first category. The join table is dropped after.
+DO $$
+BEGIN
+    IF to_regclass('t_expense_categories') IS NOT NULL THEN
+        INSERT INTO t_expense_splits (expense_id, category_id, amount, created_at, updated_at)
+        SELECT expense_id, category_id,
+            share + CASE WHEN position = 1 THEN total - share * parts ELSE 0 END,
+            NOW(), NOW()
+        FROM (
+            SELECT ec.t_expense_id AS expense_id,
+                ec.m_category_id AS category_id,
+                e.amount AS total,
+                ROUND(e.amount / COUNT(*) OVER (PARTITION BY ec.t_expense_id), 2) AS share,
+                COUNT(*) OVER (PARTITION BY ec.t_expense_id) AS parts,
+                ROW_NUMBER() OVER (PARTITION BY ec.t_expense_id ORDER BY ec.m_category_id) AS position
+            FROM t_expense_categories ec
+            JOIN t_expenses e ON e.id = ec.t_expense_id
+            WHERE NOT EXISTS (SELECT 1 FROM t_expense_splits s WHERE s.expense_id = ec.t_expense_id)
+        ) legacy;
+        DROP TABLE t_expense_categories;
+    END IF;
+END $$;
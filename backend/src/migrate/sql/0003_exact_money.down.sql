@@ -0,0 +1,14 @@
+-- Rounds amounts back to cents; digits beyond the second decimal place are lost.
+
+ALTER TABLE m_accounts ALTER COLUMN opening_balance TYPE decimal(15,2);
+ALTER TABLE t_expenses ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE t_expense_splits ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE t_incomes ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE r_balances ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE r_budgets ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE m_expense_templates ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE m_quick_amounts ALTER COLUMN value TYPE decimal;
+ALTER TABLE m_recurring_rules ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE t_transfers ALTER COLUMN amount TYPE decimal(15,2);
+ALTER TABLE t_budget_alerts ALTER COLUMN available TYPE decimal(15,2);
+ALTER TABLE t_budget_alerts ALTER COLUMN spent TYPE decimal(15,2);
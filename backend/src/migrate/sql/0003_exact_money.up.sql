@@ -0,0 +1,15 @@
+-- Amounts are exact decimals with four places, enough for the minor unit of
+-- any currency. Quick amounts were an unbounded decimal and get the same type.
+
+ALTER TABLE m_accounts ALTER COLUMN opening_balance TYPE numeric(18,4);
+ALTER TABLE t_expenses ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE t_expense_splits ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE t_incomes ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE r_balances ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE r_budgets ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE m_expense_templates ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE m_quick_amounts ALTER COLUMN value TYPE numeric(18,4);
+ALTER TABLE m_recurring_rules ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE t_transfers ALTER COLUMN amount TYPE numeric(18,4);
+ALTER TABLE t_budget_alerts ALTER COLUMN available TYPE numeric(18,4);
+ALTER TABLE t_budget_alerts ALTER COLUMN spent TYPE numeric(18,4);
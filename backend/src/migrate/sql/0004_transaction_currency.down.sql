@@ -0,0 +1,7 @@
+-- Drops the original currencies and amounts; amounts stay converted into the
+-- base currency.
+
+DROP TABLE IF EXISTS "m_exchange_rates";
+ALTER TABLE t_incomes DROP COLUMN currency, DROP COLUMN original_amount;
+ALTER TABLE t_expenses DROP COLUMN currency, DROP COLUMN original_amount;
+ALTER TABLE m_workspaces DROP COLUMN currency;
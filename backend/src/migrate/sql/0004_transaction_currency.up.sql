@@ -0,0 +1,41 @@
+-- Expenses and income keep the currency they were paid in and the amount in
+-- that currency; amount stays the value in the workspace's base currency,
+-- which reports and balances add up. Shared workspaces get a base currency of
+-- their own, their owner's; the personal workspace uses the user's currency.
+-- Existing transactions are in their workspace's base currency.
+
+ALTER TABLE m_workspaces ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'IDR';
+UPDATE m_workspaces w SET currency = u.currency
+FROM m_users u
+WHERE u.id = w.user_id AND u.currency IS NOT NULL AND u.currency <> '';
+
+ALTER TABLE t_expenses ADD COLUMN currency varchar(3), ADD COLUMN original_amount numeric(18,4);
+UPDATE t_expenses e SET original_amount = e.amount,
+    currency = COALESCE(
+        (SELECT w.currency FROM m_workspaces w WHERE w.id = e.workspace_id),
+        (SELECT NULLIF(u.currency, '') FROM m_users u WHERE u.id = e.user_id),
+        'IDR');
+ALTER TABLE t_expenses ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE t_incomes ADD COLUMN currency varchar(3), ADD COLUMN original_amount numeric(18,4);
+UPDATE t_incomes i SET original_amount = i.amount,
+    currency = COALESCE(
+        (SELECT w.currency FROM m_workspaces w WHERE w.id = i.workspace_id),
+        (SELECT NULLIF(u.currency, '') FROM m_users u WHERE u.id = i.user_id),
+        'IDR');
+ALTER TABLE t_incomes ALTER COLUMN currency SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS "m_exchange_rates" (
+    "id" bigserial,
+    "user_id" bigint,
+    "workspace_id" bigint NOT NULL DEFAULT 0,
+    "currency" varchar(3) NOT NULL,
+    "date" date NOT NULL,
+    "rate" numeric(18,10) NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_m_exchange_rates_user_id" ON "m_exchange_rates" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_m_exchange_rates_workspace_id" ON "m_exchange_rates" ("workspace_id");
+CREATE INDEX IF NOT EXISTS "idx_exchange_rate_lookup" ON "m_exchange_rates" ("currency","date");
@@ -0,0 +1 @@
+-- Backfilled owner memberships look like any other and are kept.
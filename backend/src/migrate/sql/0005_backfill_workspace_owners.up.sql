@@ -0,0 +1,11 @@
+-- Workspaces created before memberships existed get the owner membership that
+-- new workspaces are created with.
+
+INSERT INTO m_workspace_members (workspace_id, user_id, email, role, status, invited_by, created_at, updated_at)
+SELECT w.id, w.user_id, u.email, 'owner', 'accepted', w.user_id, NOW(), NOW()
+FROM m_workspaces w
+JOIN m_users u ON u.id = w.user_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM m_workspace_members m
+    WHERE m.workspace_id = w.id AND m.role = 'owner' AND m.deleted_at IS NULL
+);
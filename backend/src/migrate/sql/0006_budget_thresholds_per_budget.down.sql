@@ -0,0 +1,17 @@
+-- Thresholds go back to their budget's category, one per category and
+-- percentage. Alerts keep the threshold they fired for.
+
+ALTER TABLE m_budget_thresholds ADD COLUMN category_id bigint;
+UPDATE m_budget_thresholds t SET category_id = b.category_id
+FROM r_budgets b
+WHERE b.id = t.budget_id;
+
+DELETE FROM m_budget_thresholds t
+USING m_budget_thresholds k
+WHERE k.category_id = t.category_id AND k.percent = t.percent AND k.id < t.id;
+
+ALTER TABLE m_budget_thresholds
+    DROP COLUMN budget_id,
+    ALTER COLUMN category_id SET NOT NULL,
+    ADD CONSTRAINT "fk_m_budget_thresholds_category" FOREIGN KEY ("category_id") REFERENCES "m_categories"("id") ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_category_id" ON "m_budget_thresholds" ("category_id");
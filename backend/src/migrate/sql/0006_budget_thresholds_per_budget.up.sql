@@ -0,0 +1,26 @@
+-- Alert thresholds belong to one month's budget instead of to a category, so
+-- each budget can be watched on its own. Existing category thresholds are
+-- copied onto every budget of their category, and the alerts they fired move
+-- to the copy on the budget of the alert's month so they do not fire again.
+
+ALTER TABLE m_budget_thresholds ADD COLUMN budget_id bigint;
+
+INSERT INTO m_budget_thresholds (user_id, workspace_id, category_id, budget_id, percent, created_at, updated_at)
+SELECT t.user_id, t.workspace_id, t.category_id, b.id, t.percent, t.created_at, NOW()
+FROM m_budget_thresholds t
+JOIN r_budgets b ON b.category_id = t.category_id AND b.deleted_at IS NULL
+WHERE t.budget_id IS NULL AND t.deleted_at IS NULL;
+
+UPDATE t_budget_alerts a SET threshold_id = n.id
+FROM m_budget_thresholds o, m_budget_thresholds n, r_budgets b
+WHERE a.threshold_id = o.id AND o.budget_id IS NULL
+    AND n.budget_id = b.id AND n.category_id = o.category_id AND n.percent = o.percent
+    AND b.month = a.month;
+
+DELETE FROM m_budget_thresholds WHERE budget_id IS NULL;
+
+ALTER TABLE m_budget_thresholds
+    DROP COLUMN category_id,
+    ALTER COLUMN budget_id SET NOT NULL,
+    ADD CONSTRAINT "fk_m_budget_thresholds_budget" FOREIGN KEY ("budget_id") REFERENCES "r_budgets"("id") ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS "idx_m_budget_thresholds_budget_id" ON "m_budget_thresholds" ("budget_id");
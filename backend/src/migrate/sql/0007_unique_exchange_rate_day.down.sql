@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_exchange_rate_workspace_day";
+DROP INDEX IF EXISTS "idx_exchange_rate_personal_day";
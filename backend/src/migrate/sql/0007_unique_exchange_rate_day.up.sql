@@ -0,0 +1,17 @@
+-- One rate per currency and day in each workspace. Personal workspaces all
+-- have workspace_id 0, so their rates are told apart by user; rates of a
+-- shared workspace belong to it whichever member saved them. Rates saved
+-- twice for the same day keep the one saved last.
+
+DELETE FROM "m_exchange_rates" WHERE "id" IN (
+    SELECT "id" FROM (
+        SELECT "id", ROW_NUMBER() OVER (
+            PARTITION BY CASE WHEN "workspace_id" = 0 THEN "user_id" END, "workspace_id", "currency", "date"
+            ORDER BY "updated_at" DESC NULLS LAST, "id" DESC
+        ) AS "position"
+        FROM "m_exchange_rates"
+    ) ranked
+    WHERE "position" > 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_exchange_rate_personal_day" ON "m_exchange_rates" ("user_id","currency","date") WHERE "workspace_id" = 0;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_exchange_rate_workspace_day" ON "m_exchange_rates" ("workspace_id","currency","date") WHERE "workspace_id" <> 0;
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// Account types
+const (
+	AccountTypeCash       = "cash"
+	AccountTypeBank       = "bank"
+	AccountTypeCreditCard = "credit_card"
+	AccountTypeEWallet    = "e_wallet"
+)
+
+// M_account is a place money is held: cash, a bank account, a credit card or
+// an e-wallet. Its balance is the opening balance plus the income, expenses
+// and transfers attributed to it.
+type M_account struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	UserID         uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID    uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	Name           string         `json:"name" gorm:"not null"`
+	Type           string         `json:"type" gorm:"not null;default:'cash';check:type IN ('cash','bank','credit_card','e_wallet')"`
+	OpeningBalance money.Amount   `json:"openingBalance" gorm:"type:numeric(18,4);default:0"`
+	IsArchived     bool           `json:"isArchived" gorm:"default:false"`
+	Sequence       int            `json:"sequence" gorm:"default:0"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// IsValidAccountType checks if t is one of the supported account types
+func IsValidAccountType(t string) bool {
+	switch t {
+	case AccountTypeCash, AccountTypeBank, AccountTypeCreditCard, AccountTypeEWallet:
+		return true
+	}
+	return false
+}
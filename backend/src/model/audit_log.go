@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditImport  = "import"
+	AuditRestore = "restore" // taken back out of the trash
+	AuditPurge   = "purge"   // permanently deleted from the trash
+	AuditLockout = "lockout" // sign-ins refused after failed attempts
+)
+
+// Audited entities
+const (
+	AuditExpense   = "expense"
+	AuditIncome    = "income"
+	AuditBudget    = "budget"
+	AuditCategory  = "category"
+	AuditWorkspace = "workspace"
+	AuditCSV       = "csv" // a CSV import as a whole
+	AuditUser      = "user"
+	AuditIP        = "ip"
+)
+
+// T_audit_log is an append-only record of something that happened to an
+// entity. UserID is who the entry concerns; it is nil for events that cannot
+// be tied to an account, such as lockouts of an IP address. For changes to
+// data it is the user who made them, and Before and After hold only the
+// fields that changed.
+type T_audit_log struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	UserID      *uint           `json:"userId" gorm:"index"`
+	WorkspaceID uint            `json:"workspaceId" gorm:"index;not null;default:0"`
+	Action      string          `json:"action" gorm:"size:32;not null"`
+	Entity      string          `json:"entity" gorm:"size:32;not null"`
+	EntityID    *uint           `json:"entityId"`
+	Before      json.RawMessage `json:"before,omitempty" gorm:"type:jsonb"`
+	After       json.RawMessage `json:"after,omitempty" gorm:"type:jsonb"`
+	IP          string          `json:"ip" gorm:"size:64"`
+	CreatedAt   time.Time       `json:"createdAt" gorm:"index"`
+}
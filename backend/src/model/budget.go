@@ -3,19 +3,21 @@ package model
 import (
 	"time"
 
+	"expenses-tracker/src/money"
+
 	"gorm.io/gorm"
 )
 
 // R_budget represents a per-category monthly budget
 type R_budget struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	UserID     uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
-	WorkspaceID uint          `json:"workspaceId" gorm:"index;not null;default:0"`
-	CategoryID uint           `json:"categoryId" gorm:"index;constraint:OnDelete:CASCADE"`
-	Category   M_category     `json:"category" gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE"`
-	Month      string         `json:"month" gorm:"type:varchar(7);index"` // YYYY-MM
-	Amount     float64        `json:"amount" gorm:"type:decimal(15,2)"`
-	CreatedAt  time.Time      `json:"createdAt"`
-	UpdatedAt  time.Time      `json:"updatedAt"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	CategoryID  uint           `json:"categoryId" gorm:"index;constraint:OnDelete:CASCADE"`
+	Category    M_category     `json:"category" gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE"`
+	Month       string         `json:"month" gorm:"type:varchar(7);index"` // YYYY-MM
+	Amount      money.Amount   `json:"amount" gorm:"type:numeric(18,4)"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
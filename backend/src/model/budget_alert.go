@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// M_budget_threshold asks to be alerted when spending against one month's
+// budget reaches Percent of it.
+type M_budget_threshold struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	BudgetID    uint           `json:"budgetId" gorm:"not null;index"`
+	Budget      R_budget       `json:"budget" gorm:"foreignKey:BudgetID;constraint:OnDelete:CASCADE"`
+	Percent     int            `json:"percent" gorm:"not null;check:percent > 0"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// T_budget_alert records that a threshold fired for a month, so the same
+// alert is never delivered twice.
+type T_budget_alert struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	UserID      uint         `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint         `json:"workspaceId" gorm:"index;not null;default:0"`
+	ThresholdID uint         `json:"thresholdId" gorm:"not null;uniqueIndex:idx_budget_alert_threshold_month"`
+	CategoryID  uint         `json:"categoryId" gorm:"not null;index"`
+	Category    string       `json:"category" gorm:"-"`
+	Month       string       `json:"month" gorm:"type:varchar(7);not null;uniqueIndex:idx_budget_alert_threshold_month"` // YYYY-MM
+	Percent     int          `json:"percent"`
+	Available   money.Amount `json:"available" gorm:"type:numeric(18,4)"`
+	Spent       money.Amount `json:"spent" gorm:"type:numeric(18,4)"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
@@ -7,15 +7,16 @@ import (
 )
 
 type M_category struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"not null"`
-	Slug      string         `json:"slug" gorm:"default:null;index:idx_category_slug_user"`
-	Type      string         `json:"type" gorm:"not null;default:'expense';check:type IN ('income','expense')"` // income or expense
-	IsActive  bool           `json:"isActive" gorm:"default:true"`
-	Sequence  int            `json:"sequence" gorm:"default:0;index:idx_category_sequence"`
-	UserID    uint           `json:"userId" gorm:"default:null;index:idx_category_user_id;index:idx_category_slug_user;constraint:OnDelete:CASCADE"`
-	WorkspaceID uint         `json:"workspaceId" gorm:"index;not null;default:0"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Name          string         `json:"name" gorm:"not null"`
+	Slug          string         `json:"slug" gorm:"default:null;index:idx_category_slug_user"`
+	Type          string         `json:"type" gorm:"not null;default:'expense';check:type IN ('income','expense')"` // income or expense
+	IsActive      bool           `json:"isActive" gorm:"default:true"`
+	Sequence      int            `json:"sequence" gorm:"default:0;index:idx_category_sequence"`
+	UserID        uint           `json:"userId" gorm:"default:null;index:idx_category_user_id;index:idx_category_slug_user;constraint:OnDelete:CASCADE"`
+	WorkspaceID   uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	RolloverSince *string        `json:"rolloverSince" gorm:"type:varchar(7)"` // YYYY-MM; when set, budget leftovers carry over from this month on
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
 }
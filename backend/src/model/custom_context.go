@@ -4,10 +4,17 @@ import "github.com/labstack/echo/v4"
 
 type CustomContext struct {
 	echo.Context
-	UserID      uint
-	Email       string
-	UserName    string
-	WorkspaceID uint
+	UserID        uint
+	Email         string
+	UserName      string
+	WorkspaceID   uint
+	WorkspaceRole string
+	SessionID     string // refresh token family the access token was issued for; empty for older tokens
+	TokenScope    string // scope of the personal access token used; empty for sessions
+	Currency      string // base currency of the active workspace, which amounts are reported in
 }
 
-
+// CanWrite reports whether the caller may modify data in the active workspace.
+func (cc *CustomContext) CanWrite() bool {
+	return cc.WorkspaceRole == WorkspaceRoleOwner || cc.WorkspaceRole == WorkspaceRoleEditor
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"expenses-tracker/src/money"
+)
+
+// M_exchange_rate says what one unit of Currency was worth in the workspace's
+// base currency from Date on. A transaction in Currency is converted with the
+// newest rate dated on or before the transaction. A workspace has one rate
+// per currency and day; personal workspaces, all numbered 0, one per user.
+type M_exchange_rate struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"userId" gorm:"index;uniqueIndex:idx_exchange_rate_personal_day,where:workspace_id = 0;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint       `json:"workspaceId" gorm:"index;not null;default:0;uniqueIndex:idx_exchange_rate_workspace_day,where:workspace_id <> 0"`
+	Currency    string     `json:"currency" gorm:"type:varchar(3);not null;index:idx_exchange_rate_lookup;uniqueIndex:idx_exchange_rate_personal_day;uniqueIndex:idx_exchange_rate_workspace_day"`
+	Date        time.Time  `json:"date" gorm:"type:date;not null;index:idx_exchange_rate_lookup;uniqueIndex:idx_exchange_rate_personal_day;uniqueIndex:idx_exchange_rate_workspace_day"`
+	Rate        money.Rate `json:"rate" gorm:"type:numeric(18,10);not null"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
@@ -3,18 +3,36 @@ package model
 import (
 	"time"
 
+	"expenses-tracker/src/money"
+
 	"gorm.io/gorm"
 )
 
 type T_expense struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	UserID     uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
-	WorkspaceID uint          `json:"workspaceId" gorm:"index;not null;default:0"`
-	Categories []M_category   `json:"categories" gorm:"many2many:t_expense_categories;constraint:OnDelete:CASCADE"`
-	Date       time.Time      `json:"date" gorm:"type:date;index"`
-	Notes      string         `json:"notes" gorm:"type:text"`
-	Amount     float64        `json:"amount" gorm:"type:decimal(15,2)"`
-	CreatedAt  time.Time      `json:"createdAt"`
-	UpdatedAt  time.Time      `json:"updatedAt"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint              `json:"id" gorm:"primaryKey"`
+	UserID          uint              `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID     uint              `json:"workspaceId" gorm:"index;not null;default:0"`
+	Splits          []T_expense_split `json:"splits" gorm:"foreignKey:ExpenseID;constraint:OnDelete:CASCADE"`
+	Date            time.Time         `json:"date" gorm:"type:date;index"`
+	Notes           string            `json:"notes" gorm:"type:text"`
+	Amount          money.Amount      `json:"amount" gorm:"type:numeric(18,4)"`         // in the workspace's base currency
+	Currency        string            `json:"currency" gorm:"type:varchar(3);not null"` // ISO 4217 code of the currency the expense was paid in
+	OriginalAmount  money.Amount      `json:"originalAmount" gorm:"type:numeric(18,4)"` // the amount in Currency
+	AccountID       *uint             `json:"accountId" gorm:"index"`                   // account the money moved through, if any
+	RecurringRuleID *uint             `json:"recurringRuleId,omitempty" gorm:"index"`   // set when created by a recurring rule
+	CreatedAt       time.Time         `json:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// T_expense_split assigns part of an expense to one category. The splits of an
+// expense always sum to its Amount.
+type T_expense_split struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	ExpenseID  uint         `json:"expenseId" gorm:"not null;index"`
+	CategoryID uint         `json:"categoryId" gorm:"not null;index"`
+	Category   M_category   `json:"category" gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE"`
+	Amount     money.Amount `json:"amount" gorm:"type:numeric(18,4)"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
 }
@@ -3,29 +3,35 @@ package model
 import (
 	"time"
 
+	"expenses-tracker/src/money"
+
 	"gorm.io/gorm"
 )
 
 type T_income struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	UserID     uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
-	WorkspaceID uint          `json:"workspaceId" gorm:"index;not null;default:0"`
-	Categories []M_category   `json:"categories" gorm:"many2many:t_income_categories;constraint:OnDelete:CASCADE"`
-	Date       time.Time      `json:"date" gorm:"type:date"`
-	Amount     float64        `json:"amount" gorm:"type:decimal(15,2)"`
-	Notes      string         `json:"notes" gorm:"type:text"`
-	CreatedAt  time.Time      `json:"createdAt"`
-	UpdatedAt  time.Time      `json:"updatedAt"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	UserID          uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID     uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	Categories      []M_category   `json:"categories" gorm:"many2many:t_income_categories;constraint:OnDelete:CASCADE"`
+	Date            time.Time      `json:"date" gorm:"type:date"`
+	Amount          money.Amount   `json:"amount" gorm:"type:numeric(18,4)"`         // in the workspace's base currency
+	Currency        string         `json:"currency" gorm:"type:varchar(3);not null"` // ISO 4217 code of the currency the income was received in
+	OriginalAmount  money.Amount   `json:"originalAmount" gorm:"type:numeric(18,4)"` // the amount in Currency
+	Notes           string         `json:"notes" gorm:"type:text"`
+	AccountID       *uint          `json:"accountId" gorm:"index"`                 // account the money moved through, if any
+	RecurringRuleID *uint          `json:"recurringRuleId,omitempty" gorm:"index"` // set when created by a recurring rule
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type R_balance struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	UserID    uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
-	WorkspaceID uint         `json:"workspaceId" gorm:"index;not null;default:0"`
-	Amount    float64        `json:"amount" gorm:"type:decimal(15,2);default:0"`
-	Notes     string         `json:"notes" gorm:"type:text"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"userId" gorm:"index;uniqueIndex:idx_balance_user_workspace;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"index;uniqueIndex:idx_balance_user_workspace;not null;default:0"`
+	Amount      money.Amount   `json:"amount" gorm:"type:numeric(18,4);default:0"`
+	Notes       string         `json:"notes" gorm:"type:text"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// M_login_throttle counts recent failed sign-in attempts for one key, an
+// account ("account:<email>") or a client address ("ip:<addr>").
+type M_login_throttle struct {
+	Key           string    `json:"key" gorm:"primaryKey;size:320"`
+	Failures      int       `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time `json:"lastFailureAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification channel types. The in-app inbox is always on and needs no channel.
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// T_notification is an entry in a user's in-app inbox for a workspace.
+type T_notification struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	UserID      uint            `json:"userId" gorm:"index;not null;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint            `json:"workspaceId" gorm:"index;not null;default:0"`
+	Kind        string          `json:"kind" gorm:"size:64;not null"`
+	Subject     string          `json:"subject" gorm:"not null"`
+	Body        string          `json:"body" gorm:"type:text"`
+	Data        json.RawMessage `json:"data" gorm:"type:jsonb"`
+	ReadAt      *time.Time      `json:"readAt"`
+	CreatedAt   time.Time       `json:"createdAt" gorm:"index"`
+}
+
+// M_notification_channel is a user's extra delivery target for the
+// notifications of a workspace: a webhook URL or an email address.
+type M_notification_channel struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"userId" gorm:"index;not null;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	Type        string         `json:"type" gorm:"not null;check:type IN ('webhook','email')"`
+	Target      string         `json:"target" gorm:"not null"`
+	IsActive    bool           `json:"isActive" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells
+// them apart from JWTs in the Authorization header.
+const PersonalAccessTokenPrefix = "etp_"
+
+// Personal access token scopes
+const (
+	TokenScopeRead          = "read"           // GET requests only
+	TokenScopeWriteExpenses = "write_expenses" // read, plus changes to expenses
+	TokenScopeAdmin         = "admin"          // everything the user may do, except account security
+)
+
+// IsValidTokenScope reports whether s is a known personal access token scope.
+func IsValidTokenScope(s string) bool {
+	switch s {
+	case TokenScopeRead, TokenScopeWriteExpenses, TokenScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// M_personal_access_token is a long-lived token for scripts, bound to one
+// workspace of its user. Only the token's hash is stored.
+type M_personal_access_token struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"userId" gorm:"index;not null;constraint:OnDelete:CASCADE"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	Scope       string         `json:"scope" gorm:"size:32;not null;check:scope IN ('read','write_expenses','admin')"`
+	Prefix      string         `json:"prefix" gorm:"size:16;not null"` // start of the token, to recognise it in lists
+	TokenHash   string         `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt   *time.Time     `json:"expiresAt"` // nil never expires
+	LastUsedAt  *time.Time     `json:"lastUsedAt"`
+	LastUsedIP  string         `json:"lastUsedIp" gorm:"size:64"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// M_recovery_code is a single-use code that stands in for a TOTP code when
+// the user has lost their authenticator.
+type M_recovery_code struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// M_recurring_rule repeatedly materializes an expense or income row.
+// NextDate is the next occurrence still to be created and OccurrenceCount the
+// number of occurrences already created or skipped.
+type M_recurring_rule struct {
+	ID              uint               `json:"id" gorm:"primaryKey"`
+	UserID          uint               `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID     uint               `json:"workspaceId" gorm:"index;not null;default:0"`
+	Type            string             `json:"type" gorm:"not null;default:'expense';check:type IN ('income','expense')"` // income or expense
+	Categories      []M_category       `json:"categories" gorm:"many2many:m_recurring_rule_categories;constraint:OnDelete:CASCADE"`
+	AccountID       *uint              `json:"accountId"` // copied onto every created row
+	Amount          money.Amount       `json:"amount" gorm:"type:numeric(18,4)"`
+	Notes           string             `json:"notes" gorm:"type:text"`
+	Frequency       string             `json:"frequency" gorm:"not null;check:frequency IN ('daily','weekly','monthly','yearly')"`
+	Interval        int                `json:"interval" gorm:"not null;default:1"`
+	Anchor          string             `json:"anchor" gorm:"size:32"` // "", last_day, first_business_day, last_business_day
+	StartDate       time.Time          `json:"startDate" gorm:"type:date;not null"`
+	EndDate         *time.Time         `json:"endDate" gorm:"type:date"`
+	MaxOccurrences  *int               `json:"maxOccurrences"`
+	OccurrenceCount int                `json:"occurrenceCount" gorm:"default:0"`
+	NextDate        time.Time          `json:"nextDate" gorm:"type:date;index"`
+	IsPaused        bool               `json:"isPaused" gorm:"default:false"`
+	IsFinished      bool               `json:"isFinished" gorm:"default:false;index"`
+	Skips           []M_recurring_skip `json:"skips" gorm:"foreignKey:RecurringRuleID;constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time          `json:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// M_recurring_skip marks a single future occurrence of a rule that must not be created.
+type M_recurring_skip struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	RecurringRuleID uint      `json:"recurringRuleId" gorm:"not null;index"`
+	Date            time.Time `json:"date" gorm:"type:date;not null"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
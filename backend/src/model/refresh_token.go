@@ -6,14 +6,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// M_refresh_token is one link in a session's chain of refresh tokens. Every
+// refresh replaces the token with a successor in the same family; the
+// replaced row is kept (RotatedAt set) so that presenting it again can be
+// recognised as reuse.
 type M_refresh_token struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	UserID    uint           `json:"userId" gorm:"not null;index;constraint:OnDelete:CASCADE"`
-	Token     string         `json:"token" gorm:"uniqueIndex;not null"` // refresh token string (can be hashed or raw)
-	DeviceID  string         `json:"deviceId" gorm:"size:255"`
-	UsedCount int            `json:"usedCount" gorm:"default:0"` // how many times this refresh token has been used
-	ExpiresAt time.Time      `json:"expiresAt" gorm:"not null"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"userId" gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	TokenHash  string         `json:"-" gorm:"size:64;uniqueIndex"` // hex SHA-256 of the token; the token itself is never stored
+	FamilyID   string         `json:"familyId" gorm:"size:64;index"`
+	DeviceID   string         `json:"deviceId" gorm:"size:255"`
+	UserAgent  string         `json:"userAgent" gorm:"size:512"`
+	UsedCount  int            `json:"usedCount" gorm:"default:0"` // how many refreshes the family has gone through
+	StartedAt  time.Time      `json:"startedAt"`                  // when the family (sign-in) began
+	LastUsedAt *time.Time     `json:"lastUsedAt"`
+	RotatedAt  *time.Time     `json:"rotatedAt"`
+	ExpiresAt  time.Time      `json:"expiresAt" gorm:"not null"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// T_transfer moves money from one account to another within a workspace. It
+// changes both account balances but is neither income nor expense.
+type T_transfer struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	UserID        uint           `json:"userId" gorm:"index;constraint:OnDelete:CASCADE"`
+	WorkspaceID   uint           `json:"workspaceId" gorm:"index;not null;default:0"`
+	FromAccountID uint           `json:"fromAccountId" gorm:"not null;index"`
+	FromAccount   *M_account     `json:"fromAccount,omitempty" gorm:"foreignKey:FromAccountID;constraint:OnDelete:CASCADE"`
+	ToAccountID   uint           `json:"toAccountId" gorm:"not null;index"`
+	ToAccount     *M_account     `json:"toAccount,omitempty" gorm:"foreignKey:ToAccountID;constraint:OnDelete:CASCADE"`
+	Date          time.Time      `json:"date" gorm:"type:date;index"`
+	Amount        money.Amount   `json:"amount" gorm:"type:numeric(18,4)"`
+	Notes         string         `json:"notes" gorm:"type:text"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
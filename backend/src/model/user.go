@@ -7,13 +7,19 @@ import (
 )
 
 type M_user struct {
-	ID                    uint           `json:"id" gorm:"primaryKey"`
-	Name                  string         `json:"name" gorm:"not null"`
-	Email                 string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password              string         `json:"-" gorm:"not null"`
-	Currency              string         `json:"currency" gorm:"default:'IDR'"` // IDR, USD, EUR, JPY
-	FirstSigninCompleted  bool           `json:"firstSigninCompleted" gorm:"default:false"`
-	CreatedAt             time.Time      `json:"createdAt"`
-	UpdatedAt             time.Time      `json:"updatedAt"`
-	DeletedAt             gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                   uint           `json:"id" gorm:"primaryKey"`
+	Name                 string         `json:"name" gorm:"not null"`
+	Email                string         `json:"email" gorm:"uniqueIndex;not null"`
+	EmailVerifiedAt      *time.Time     `json:"emailVerifiedAt"`
+	PendingEmail         string         `json:"pendingEmail"` // requested new address, applied once verified
+	Password             string         `json:"-" gorm:"not null"`
+	Currency             string         `json:"currency" gorm:"default:'IDR'"` // IDR, USD, EUR, JPY
+	FirstSigninCompleted bool           `json:"firstSigninCompleted" gorm:"default:false"`
+	TOTPSecret           string         `json:"-"` // sealed by security.SecretBox on enrollment, in use once TOTPEnabled
+	TOTPEnabled          bool           `json:"totpEnabled" gorm:"default:false"`
+	TOTPLastStep         int64          `json:"-" gorm:"default:0"`  // newest TOTP time step accepted, so a code works only once
+	DeletionScheduledAt  *time.Time     `json:"deletionScheduledAt"` // set while a requested account deletion is in its grace period
+	CreatedAt            time.Time      `json:"createdAt"`
+	UpdatedAt            time.Time      `json:"updatedAt"`
+	DeletedAt            gorm.DeletedAt `json:"-" gorm:"index"`
 }
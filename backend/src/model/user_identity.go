@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// M_user_identity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's issuer and its subject for the user.
+type M_user_identity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"userId" gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	Issuer      string     `json:"issuer" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Subject     string     `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Email       string     `json:"email"` // address the provider reported at the last login
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
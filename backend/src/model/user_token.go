@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// User token purposes
+const (
+	TokenEmailVerification = "email_verification"
+	TokenPasswordReset     = "password_reset"
+	TokenOIDCLogin         = "oidc_login" // hands a finished OIDC login to the frontend
+)
+
+// M_user_token is a single-use token sent to a user by email, or through a
+// redirect for OIDC logins. Only its hash is stored.
+type M_user_token struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	Purpose   string     `json:"purpose" gorm:"size:32;not null;check:purpose IN ('email_verification','password_reset','oidc_login')"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Email     string     `json:"email" gorm:"not null"` // address the token was sent to
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -14,11 +14,8 @@ type M_workspace struct {
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description" gorm:"type:text"`
 	Slug        string         `json:"slug" gorm:"index"`
+	Currency    string         `json:"currency" gorm:"type:varchar(3);not null;default:'IDR'"` // base currency its reports and balances are in
 	CreatedAt   time.Time      `json:"createdAt"`
 	UpdatedAt   time.Time      `json:"updatedAt"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
-
-
-
-
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace roles. The owner manages members; editors can change financial
+// data; viewers only read it.
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// Membership states of an invitation.
+const (
+	MemberStatusPending  = "pending"
+	MemberStatusAccepted = "accepted"
+	MemberStatusDeclined = "declined"
+)
+
+// M_workspace_member grants a user access to a workspace shared with them.
+// Invitations are addressed by email; UserID is filled once the invitee accepts.
+type M_workspace_member struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	WorkspaceID uint           `json:"workspaceId" gorm:"not null;index:idx_workspace_member_email"`
+	Workspace   *M_workspace   `json:"workspace,omitempty" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+	UserID      uint           `json:"userId" gorm:"index"`
+	Email       string         `json:"email" gorm:"not null;index:idx_workspace_member_email"`
+	Role        string         `json:"role" gorm:"not null;default:'viewer';check:role IN ('owner','editor','viewer')"`
+	Status      string         `json:"status" gorm:"not null;default:'pending';check:status IN ('pending','accepted','declined')"`
+	InvitedBy   uint           `json:"invitedBy"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,39 @@
+package money
+
+import "strings"
+
+// decimals lists the ISO 4217 currencies whose minor unit is not a hundredth.
+var decimals = map[string]int{
+	// No minor unit in use
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	// Thousandths
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// Ten-thousandths
+	"CLF": 4, "UYW": 4,
+}
+
+// Decimals returns how many decimal places amounts in currency, an ISO 4217
+// code, are written with: 0 for JPY, 3 for KWD and 2 for most others,
+// including unknown codes.
+func Decimals(currency string) int {
+	if d, ok := decimals[strings.ToUpper(currency)]; ok {
+		return d
+	}
+	return 2
+}
+
+// IsCurrencyCode reports whether code looks like an ISO 4217 code: three
+// capital letters.
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+// Package money holds amounts of money exactly. An Amount is a fixed-point
+// decimal with four decimal places, which covers the minor units of every ISO
+// 4217 currency, so sums and differences never drift the way floats do.
+// Rounding to what a currency can actually express happens explicitly, with
+// Round, wherever an amount is divided.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places an Amount keeps.
+const Scale = 4
+
+// One is the amount 1 in any currency.
+const One Amount = 10000
+
+// Max is the largest amount that fits the numeric(18,4) columns amounts are
+// stored in; Min is its negative.
+const (
+	Max Amount = 999999999999999999
+	Min Amount = -Max
+)
+
+var (
+	ErrSyntax = errors.New("invalid amount")
+	ErrRange  = errors.New("amount out of range")
+)
+
+// pow10[n] is 10^n for the decimal places an amount can be rounded to.
+var pow10 = [Scale + 1]int64{1, 10, 100, 1000, 10000}
+
+// Amount is an exact amount of money in ten-thousandths of the currency's
+// main unit. Amounts add and subtract with the usual operators and compare
+// with ==, < and >; multiplying two amounts does not make sense.
+type Amount int64
+
+// Parse reads a decimal amount such as "12", "-0.5" or "1234.5600". More than
+// four significant decimal places are refused rather than rounded away.
+func Parse(s string) (Amount, error) {
+	v, err := parseFixed(s, Scale)
+	return Amount(v), err
+}
+
+// parseFixed reads a decimal into an integer of 10^-scale units. Like the
+// numeric(18,scale) columns such values are stored in, it takes at most 18
+// digits, scale of them after the decimal point.
+func parseFixed(s string, scale int) (int64, error) {
+	text := strings.TrimSpace(s)
+	negative := false
+	if text != "" && (text[0] == '-' || text[0] == '+') {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole+frac == "" || !digitsOnly(whole) || !digitsOnly(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > scale {
+		return 0, fmt.Errorf("%w: more than %d decimal places", ErrRange, scale)
+	}
+
+	whole = strings.TrimLeft(whole, "0")
+	if len(whole) > 18-scale {
+		return 0, ErrRange
+	}
+	digits := whole + frac + strings.Repeat("0", scale-len(frac))
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, ErrRange
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func digitsOnly(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Abs returns the amount without its sign.
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Round rounds the amount to the decimal places of currency, halves away
+// from zero.
+func (a Amount) Round(currency string) Amount {
+	return a.roundTo(Decimals(currency))
+}
+
+// Fits reports whether the amount needs no more decimal places than currency has.
+func (a Amount) Fits(currency string) bool {
+	return a.Round(currency) == a
+}
+
+func (a Amount) roundTo(places int) Amount {
+	step := Amount(pow10[Scale-places])
+	q, r := a/step, a%step
+	if 2*r.Abs() >= step {
+		if a < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q * step
+}
+
+// MulDiv returns a*num/den rounded to the amount's full scale. It is how an
+// amount is scaled by a ratio, such as a daily rate over a number of days.
+// A result beyond Max or Min is ErrRange.
+func (a Amount) MulDiv(num, den int64) (Amount, error) {
+	p := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num))
+	d := big.NewInt(den)
+	q, r := new(big.Int).QuoRem(p, d, new(big.Int))
+	if new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(d)) >= 0 {
+		if p.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, ErrRange
+	}
+	return Amount(q.Int64()).check()
+}
+
+// check returns the amount, or ErrRange when it is beyond Max or Min.
+func (a Amount) check() (Amount, error) {
+	if a > Max || a < Min {
+		return 0, ErrRange
+	}
+	return a, nil
+}
+
+// Ratio returns a/b as a float, for percentages and other figures that are
+// not money themselves. It is 0 when b is 0.
+func (a Amount) Ratio(b Amount) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// Float64 returns the amount as a float, for spreadsheets and other consumers
+// that only understand binary floating point.
+func (a Amount) Float64() float64 {
+	return float64(a) / float64(One)
+}
+
+// Split divides the amount into n shares in the smallest unit of currency.
+// Shares differ by at most one unit; the first ones get the larger shares.
+func (a Amount) Split(n int, currency string) []Amount {
+	if n <= 0 {
+		return nil
+	}
+	unit := Amount(pow10[Scale-Decimals(currency)])
+	units := a / unit
+	share, extra := units/Amount(n), units%Amount(n)
+
+	shares := make([]Amount, n)
+	for i := range shares {
+		shares[i] = share * unit
+		switch {
+		case Amount(i) < extra:
+			shares[i] += unit
+		case Amount(i) < -extra:
+			shares[i] -= unit
+		}
+	}
+	// Anything finer than the currency's unit stays with the first share
+	shares[0] += a - units*unit
+	return shares
+}
+
+// String formats the amount with as few decimal places as it needs.
+func (a Amount) String() string {
+	s := a.format(Scale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// Format formats the amount with exactly the decimal places of currency,
+// rounding if it has more.
+func (a Amount) Format(currency string) string {
+	places := Decimals(currency)
+	return a.roundTo(places).format(places)
+}
+
+// format prints an amount already rounded to places decimal places.
+func (a Amount) format(places int) string {
+	return formatFixed(int64(a), Scale, places)
+}
+
+// formatFixed prints v, in 10^-scale units, with the first places of its
+// decimals.
+func formatFixed(v int64, scale, places int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign, u = "-", uint64(-v)
+	}
+	unit := uint64(1)
+	for i := 0; i < scale; i++ {
+		unit *= 10
+	}
+	whole, frac := u/unit, u%unit
+	if places == 0 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+	fracDigits := fmt.Sprintf("%0*d", scale, frac)[:places]
+	return sign + strconv.FormatUint(whole, 10) + "." + fracDigits
+}
+
+// MarshalJSON encodes the amount as a decimal string, so clients never have
+// to round-trip it through a float.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number. Numbers are read
+// from their text, never through a float.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s, ok, err := jsonDecimal(b)
+	if !ok {
+		return err
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// jsonDecimal returns the text of a JSON string or number. It reports false
+// for null, which leaves the value alone, and for malformed strings.
+func jsonDecimal(b []byte) (string, bool, error) {
+	s := string(b)
+	if s == "null" {
+		return "", false, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return "", false, ErrSyntax
+		}
+		s = unquoted
+	}
+	return s, true, nil
+}
+
+// Value stores the amount as its decimal text.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a numeric column. Postgres drivers hand numerics over as text.
+func (a *Amount) Scan(src interface{}) error {
+	v, err := scanFixed(src, Scale)
+	if err != nil {
+		return err
+	}
+	*a = Amount(v)
+	return nil
+}
+
+// scanFixed reads a numeric column holding values of the given scale.
+func scanFixed(src interface{}, scale int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return parseFixed(v, scale)
+	case []byte:
+		return parseFixed(string(v), scale)
+	case int64:
+		return parseFixed(strconv.FormatInt(v, 10), scale)
+	case float64:
+		return parseFixed(strconv.FormatFloat(v, 'f', scale, 64), scale)
+	default:
+		return 0, fmt.Errorf("money: cannot scan %T", src)
+	}
+}
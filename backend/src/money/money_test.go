@@ -0,0 +1,181 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]Amount{
+		"12":                  12 * One,
+		"-0.5":                -One / 2,
+		"+1234.5600":          12345600,
+		" 0.0001 ":            1,
+		".25":                 One / 4,
+		"5.":                  5 * One,
+		"00012.10":            121000,
+		"99999999999999.9999": Max,
+	}
+	for s, want := range valid {
+		if got, err := Parse(s); err != nil || got != want {
+			t.Errorf("Parse(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	invalid := map[string]error{
+		"":                    ErrSyntax,
+		"-":                   ErrSyntax,
+		".":                   ErrSyntax,
+		"1,5":                 ErrSyntax,
+		"1e3":                 ErrSyntax,
+		"12.3.4":              ErrSyntax,
+		"0.00001":             ErrRange,
+		"100000000000000":     ErrRange,
+		"-100000000000000.00": ErrRange,
+	}
+	for s, want := range invalid {
+		if _, err := Parse(s); !errors.Is(err, want) {
+			t.Errorf("Parse(%q) error = %v, want %v", s, err, want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"1.005", "USD", "1.01"},
+		{"-1.005", "USD", "-1.01"},
+		{"1.0049", "USD", "1"},
+		{"2.5", "JPY", "3"},
+		{"-2.5", "JPY", "-3"},
+		{"1.0005", "KWD", "1.001"},
+		{"1.0005", "CLF", "1.0005"},
+	}
+	for _, c := range cases {
+		a, _ := Parse(c.amount)
+		if got := a.Round(c.currency).String(); got != c.want {
+			t.Errorf("%s rounded in %s = %s, want %s", c.amount, c.currency, got, c.want)
+		}
+	}
+
+	if a, _ := Parse("1.5"); a.Fits("JPY") || !a.Fits("USD") {
+		t.Error("1.5 should fit USD but not JPY")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	a, _ := Parse("-1234.5")
+	for currency, want := range map[string]string{"USD": "-1234.50", "JPY": "-1235", "KWD": "-1234.500"} {
+		if got := a.Format(currency); got != want {
+			t.Errorf("Format(%s) = %s, want %s", currency, got, want)
+		}
+	}
+	if got := Amount(0).String(); got != "0" {
+		t.Errorf("zero = %q", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	a, _ := Parse("100")
+	shares := a.Split(3, "USD")
+	if len(shares) != 3 || shares[0].String() != "33.34" || shares[1].String() != "33.33" || shares[2].String() != "33.33" {
+		t.Errorf("100 USD split three ways = %v", shares)
+	}
+
+	b, _ := Parse("-10.0005")
+	var sum Amount
+	for _, s := range b.Split(4, "JPY") {
+		sum += s
+	}
+	if sum != b {
+		t.Errorf("shares of %s add up to %s", b, sum)
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	a, _ := Parse("10")
+	cases := []struct {
+		a        Amount
+		num, den int64
+		want     string
+	}{
+		{a, 1, 3, "3.3333"},
+		{a, 2, 3, "6.6667"},
+		{-a, 2, 3, "-6.6667"},
+		{Max, 1, 1, "99999999999999.9999"},
+	}
+	for _, c := range cases {
+		if got, err := c.a.MulDiv(c.num, c.den); err != nil || got.String() != c.want {
+			t.Errorf("%s * %d / %d = %s, %v; want %s", c.a, c.num, c.den, got, err, c.want)
+		}
+	}
+
+	// Past Max, and past what an int64 holds
+	for _, num := range []int64{2, 1 << 40} {
+		if got, err := Max.MulDiv(num, 1); !errors.Is(err, ErrRange) {
+			t.Errorf("Max * %d = %s, %v; want ErrRange", num, got, err)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var v struct {
+		Amount Amount  `json:"amount"`
+		Rate   Rate    `json:"rate"`
+		Max    *Amount `json:"max"`
+	}
+	v.Amount, _ = Parse("-1234.5")
+	v.Rate, _ = ParseRate("0.0000631")
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"amount":"-1234.5","rate":"0.0000631","max":null}`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+
+	for _, in := range []string{`{"amount":12.34,"rate":15850}`, `{"amount":"12.34","rate":"15850"}`} {
+		v.Amount, v.Rate = 0, 0
+		if err := json.Unmarshal([]byte(in), &v); err != nil {
+			t.Errorf("Unmarshal(%s): %v", in, err)
+		} else if v.Amount != 123400 || v.Rate != 15850*rateOne {
+			t.Errorf("Unmarshal(%s) = %d, %d", in, v.Amount, v.Rate)
+		}
+	}
+
+	v.Amount = One
+	if err := json.Unmarshal([]byte(`{"amount":null}`), &v); err != nil || v.Amount != One {
+		t.Errorf("null left the amount at %s, %v", v.Amount, err)
+	}
+	for _, in := range []string{`{"amount":"twelve"}`, `{"amount":true}`, `{"amount":0.00001}`, `{"rate":-1}`} {
+		if err := json.Unmarshal([]byte(in), &v); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", in)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	cases := []struct {
+		src  interface{}
+		want Amount
+	}{
+		{"12.3400", 123400},
+		{[]byte("-0.5"), -One / 2},
+		{int64(7), 7 * One},
+		{1.25, One + One/4},
+		{nil, 0},
+	}
+	for _, c := range cases {
+		var a Amount
+		if err := a.Scan(c.src); err != nil || a != c.want {
+			t.Errorf("Scan(%v) = %d, %v; want %d", c.src, a, err, c.want)
+		}
+	}
+	if v, err := Amount(123400).Value(); err != nil || v != "12.34" {
+		t.Errorf("Value = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,81 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// RateScale is the number of decimal places a Rate keeps, enough to quote a
+// rupiah in dollars.
+const RateScale = 10
+
+// rateOne is the rate 1.
+const rateOne Rate = 10000000000
+
+// Rate is an exchange rate: how many units of one currency a single unit of
+// another is worth, in 10^-10 units. Rates are stored as numeric(18,10).
+type Rate int64
+
+// ParseRate reads a positive decimal rate such as "15850" or "0.0000631".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s, RateScale)
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("%w: rates must be positive", ErrRange)
+	}
+	return Rate(v), nil
+}
+
+// Convert returns what amount is worth at this rate, rounded to the decimal
+// places of currency, the currency converted into. It is ErrRange when the
+// result does not fit an Amount.
+func (r Rate) Convert(a Amount, currency string) (Amount, error) {
+	converted, err := a.MulDiv(int64(r), int64(rateOne))
+	if err != nil {
+		return 0, err
+	}
+	return converted.Round(currency).check()
+}
+
+// String formats the rate with as few decimal places as it needs.
+func (r Rate) String() string {
+	s := formatFixed(int64(r), RateScale, RateScale)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+// MarshalJSON encodes the rate as a decimal string, like amounts.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + r.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	s, ok, err := jsonDecimal(b)
+	if !ok {
+		return err
+	}
+	v, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// Value stores the rate as its decimal text.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan reads a numeric(18,10) column.
+func (r *Rate) Scan(src interface{}) error {
+	v, err := scanFixed(src, RateScale)
+	if err != nil {
+		return err
+	}
+	*r = Rate(v)
+	return nil
+}
@@ -0,0 +1,33 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		amount, rate, currency string
+		want                   string
+	}{
+		{"10", "15850.5", "IDR", "158505"},
+		{"3", "0.0000631", "USD", "0"},
+		{"1000000", "0.0000631", "USD", "63.1"},
+		{"-12.5", "1.1", "EUR", "-13.75"},
+		{"1", "0.3333333333", "KWD", "0.333"},
+	}
+	for _, c := range cases {
+		a, _ := Parse(c.amount)
+		r, _ := ParseRate(c.rate)
+		if got, err := r.Convert(a, c.currency); err != nil || got.String() != c.want {
+			t.Errorf("%s at %s into %s = %s, %v; want %s", c.amount, c.rate, c.currency, got, err, c.want)
+		}
+	}
+
+	r, _ := ParseRate("15850")
+	for _, a := range []Amount{Max, Min, Max / 15850 * 2} {
+		if got, err := r.Convert(a, "IDR"); !errors.Is(err, ErrRange) {
+			t.Errorf("%s at %s = %s, %v; want ErrRange", a, r, got, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+)
+
+// deliveryTimeout bounds how long one batch of alerts may spend in delivery.
+const deliveryTimeout = time.Minute
+
+// BudgetAlerter evaluates budget thresholds after spending changes and
+// delivers the alerts that fired.
+type BudgetAlerter struct {
+	repo       *repository.BudgetAlertRepository
+	dispatcher *Dispatcher
+}
+
+func NewBudgetAlerter(repo *repository.BudgetAlertRepository, dispatcher *Dispatcher) *BudgetAlerter {
+	return &BudgetAlerter{repo: repo, dispatcher: dispatcher}
+}
+
+// Check evaluates the months of the given expense dates. Alerts are recorded
+// before Check returns and delivered in the background, with amounts written
+// in currency; errors are logged since the change that triggered the check
+// has already been saved.
+func (a *BudgetAlerter) Check(userID uint, workspaceID uint, currency string, dates ...time.Time) {
+	seen := make(map[string]bool, len(dates))
+	var fired []model.T_budget_alert
+	for _, d := range dates {
+		month := d.Format("2006-01")
+		if seen[month] {
+			continue
+		}
+		seen[month] = true
+
+		alerts, err := a.repo.Evaluate(userID, workspaceID, month)
+		if err != nil {
+			log.Printf("Budget alerts: workspace %d month %s: %v", workspaceID, month, err)
+			continue
+		}
+		fired = append(fired, alerts...)
+	}
+	if len(fired) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		defer cancel()
+		for _, alert := range fired {
+			a.dispatcher.Dispatch(ctx, userID, workspaceID, alertMessage(alert, currency))
+		}
+	}()
+}
+
+func alertMessage(alert model.T_budget_alert, currency string) Message {
+	used := 0.0
+	if alert.Available > 0 {
+		used = alert.Spent.Ratio(alert.Available) * 100
+	}
+	return Message{
+		Kind:    "budget_threshold",
+		Subject: fmt.Sprintf("%s reached %d%% of its budget", alert.Category, alert.Percent),
+		Body: fmt.Sprintf("Spending in %s for %s is %s of %s available (%.0f%%).",
+			alert.Category, alert.Month, alert.Spent.Format(currency), alert.Available.Format(currency), used),
+		Data: alert,
+	}
+}
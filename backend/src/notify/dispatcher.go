@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"expenses-tracker/src/repository"
+)
+
+// Dispatcher fans a message out to everyone who can see a workspace: always to
+// their inbox, and to each of their active channels whose notifier is
+// configured.
+type Dispatcher struct {
+	inbox            Notifier
+	channels         map[string]Notifier // by channel type
+	notificationRepo *repository.NotificationRepository
+	workspaceRepo    *repository.WorkspaceRepository
+}
+
+func NewDispatcher(inbox Notifier, channels map[string]Notifier, notificationRepo *repository.NotificationRepository, workspaceRepo *repository.WorkspaceRepository) *Dispatcher {
+	return &Dispatcher{
+		inbox:            inbox,
+		channels:         channels,
+		notificationRepo: notificationRepo,
+		workspaceRepo:    workspaceRepo,
+	}
+}
+
+// Dispatch delivers msg for the workspace. Delivery failures are logged and
+// do not stop the remaining deliveries.
+func (d *Dispatcher) Dispatch(ctx context.Context, userID uint, workspaceID uint, msg Message) {
+	userIDs, err := d.workspaceRepo.MemberUserIDs(userID, workspaceID)
+	if err != nil {
+		log.Printf("Notify: workspace %d recipients: %v", workspaceID, err)
+		return
+	}
+
+	for _, uid := range userIDs {
+		to := Recipient{UserID: uid, WorkspaceID: workspaceID}
+		if err := d.inbox.Notify(ctx, to, msg); err != nil {
+			log.Printf("Notify: inbox for user %d: %v", uid, err)
+		}
+
+		channels, err := d.notificationRepo.GetChannels(uid, workspaceID)
+		if err != nil {
+			log.Printf("Notify: channels for user %d: %v", uid, err)
+			continue
+		}
+		for _, ch := range channels {
+			notifier, ok := d.channels[ch.Type]
+			if !ch.IsActive || !ok {
+				continue
+			}
+			to.Target = ch.Target
+			if err := notifier.Notify(ctx, to, msg); err != nil {
+				log.Printf("Notify: %s channel %d: %v", ch.Type, ch.ID, err)
+			}
+		}
+	}
+}
+
+// Supports reports whether channels of the given type can be delivered.
+func (d *Dispatcher) Supports(channelType string) bool {
+	_, ok := d.channels[channelType]
+	return ok
+}
+
+// Send delivers msg to a single channel, bypassing the workspace fan-out.
+func (d *Dispatcher) Send(ctx context.Context, channelType string, to Recipient, msg Message) error {
+	notifier, ok := d.channels[channelType]
+	if !ok {
+		return fmt.Errorf("%s delivery is not configured", channelType)
+	}
+	return notifier.Notify(ctx, to, msg)
+}
@@ -0,0 +1,20 @@
+package notify
+
+import (
+	"context"
+
+	"expenses-tracker/src/mailer"
+)
+
+// EmailNotifier emails messages to the recipient's address.
+type EmailNotifier struct {
+	mailer mailer.Mailer
+}
+
+func NewEmailNotifier(m mailer.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: m}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, to Recipient, msg Message) error {
+	return n.mailer.Send(ctx, mailer.Email{To: to.Target, Subject: msg.Subject, Body: msg.Body})
+}
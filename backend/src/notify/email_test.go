@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"expenses-tracker/src/config"
+	"expenses-tracker/src/mailer"
+)
+
+// smtpDelivery is one message received by a fakeSMTP server.
+type smtpDelivery struct {
+	from, to string
+	data     string
+}
+
+// fakeSMTP accepts a single SMTP session on a local port, without TLS or
+// authentication, like the stand-ins used in development.
+func fakeSMTP(t *testing.T) (host, port string, received <-chan smtpDelivery) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan smtpDelivery, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var d smtpDelivery
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				d.from = cmd[len("MAIL FROM:"):]
+				reply("250 OK")
+			case "RCPT":
+				d.to = cmd[len("RCPT TO:"):]
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				d.data = b.String()
+				reply("250 OK")
+				ch <- d
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, ch
+}
+
+func TestEmailNotifierSendsThroughSMTP(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	m := mailer.NewSMTPMailer(config.SMTPConfig{Host: host, Port: port, From: "alerts@example.com"})
+	n := NewEmailNotifier(m)
+
+	msg := Message{Kind: "budget_threshold", Subject: "Food reached 80% of its budget", Body: "Spending in Food is Rp 80.000 of Rp 100.000."}
+	if err := n.Notify(context.Background(), Recipient{Target: "owner@example.com"}, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	d := <-received
+	if d.from != "<alerts@example.com>" || d.to != "<owner@example.com>" {
+		t.Errorf("envelope from %s to %s", d.from, d.to)
+	}
+	for _, want := range []string{"To: owner@example.com\r\n", "Subject: " + msg.Subject + "\r\n", msg.Body} {
+		if !strings.Contains(d.data, want) {
+			t.Errorf("message lacks %q:\n%s", want, d.data)
+		}
+	}
+}
+
+func TestEmailNotifierRejectsHeaderInjection(t *testing.T) {
+	n := NewEmailNotifier(mailer.NewSMTPMailer(config.SMTPConfig{Host: "127.0.0.1", Port: "1", From: "alerts@example.com"}))
+	err := n.Notify(context.Background(), Recipient{Target: "owner@example.com\r\nBcc: everyone@example.com"}, Message{Subject: "x"})
+	if err == nil {
+		t.Error("Notify accepted an address with a line break")
+	}
+}
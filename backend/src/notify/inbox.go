@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+)
+
+// InboxNotifier stores messages in the recipient's in-app inbox.
+type InboxNotifier struct {
+	repo *repository.NotificationRepository
+}
+
+func NewInboxNotifier(repo *repository.NotificationRepository) *InboxNotifier {
+	return &InboxNotifier{repo: repo}
+}
+
+func (n *InboxNotifier) Notify(ctx context.Context, to Recipient, msg Message) error {
+	data := json.RawMessage("{}")
+	if msg.Data != nil {
+		b, err := json.Marshal(msg.Data)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+	return n.repo.Create(&model.T_notification{
+		UserID:      to.UserID,
+		WorkspaceID: to.WorkspaceID,
+		Kind:        msg.Kind,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		Data:        data,
+	})
+}
@@ -0,0 +1,23 @@
+package notify
+
+import "context"
+
+// Message is a notification ready for delivery on any channel.
+type Message struct {
+	Kind    string      `json:"kind"`
+	Subject string      `json:"subject"`
+	Body    string      `json:"body"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Recipient is who a message is for and, for external channels, where it goes.
+type Recipient struct {
+	UserID      uint
+	WorkspaceID uint
+	Target      string // webhook URL or email address; unused by the inbox
+}
+
+// Notifier delivers messages over one channel.
+type Notifier interface {
+	Notify(ctx context.Context, to Recipient, msg Message) error
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookTarget is returned for webhook URLs that are malformed or point
+// at the server's own network: loopback, link-local, private or otherwise
+// internal addresses.
+var ErrWebhookTarget = errors.New("webhook target is not allowed")
+
+// internalHostSuffixes are host names that only resolve inside a network.
+var internalHostSuffixes = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"}
+
+// sharedAddressSpace is the carrier-grade NAT range, which net.IP does not
+// count as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is an address webhooks may be delivered to.
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckWebhookURL validates a webhook URL before it is saved: it must be http
+// or https and its host must resolve to public addresses only. Delivery checks
+// the address again when connecting, since DNS answers can change.
+func CheckWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrWebhookTarget
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || !strings.Contains(host, ".") && net.ParseIP(host) == nil {
+		return ErrWebhookTarget
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return ErrWebhookTarget
+		}
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrWebhookTarget
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrWebhookTarget
+		}
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs messages as JSON to the recipient's URL. When a secret
+// is configured the body is signed with HMAC-SHA256 in the X-Signature header
+// so receivers can verify where it came from.
+//
+// Connections are only made to public addresses, whatever the URL's host
+// resolves to at the time and wherever a redirect leads.
+type WebhookNotifier struct {
+	client *http.Client
+	secret string
+}
+
+func NewWebhookNotifier(secret string) *WebhookNotifier {
+	return newWebhookNotifier(secret, publicIP)
+}
+
+// newWebhookNotifier returns a notifier that only connects to addresses
+// allow accepts. Proxies from the environment are not used, as the address
+// checked would be the proxy's.
+func newWebhookNotifier(secret string, allow func(net.IP) bool) *WebhookNotifier {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allow(ip) {
+				return ErrWebhookTarget
+			}
+			return nil
+		},
+	}
+	return &WebhookNotifier{
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		secret: secret,
+	}
+}
+
+type webhookPayload struct {
+	Message
+	WorkspaceID uint      `json:"workspaceId"`
+	SentAt      time.Time `json:"sentAt"`
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, to Recipient, msg Message) error {
+	body, err := json.Marshal(webhookPayload{Message: msg, WorkspaceID: to.WorkspaceID, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %s", to.Target, res.Status)
+	}
+	return nil
+}
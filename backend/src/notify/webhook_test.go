@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	refused := []string{
+		"ftp://93.184.216.34/hook",
+		"http:///hook",
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://localhost/hook",
+		"http://intranet/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.10/hook",
+		"http://100.64.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://metadata.google.internal/computeMetadata/v1/",
+		"http://printer.local/hook",
+	}
+	for _, raw := range refused {
+		if err := CheckWebhookURL(context.Background(), raw); !errors.Is(err, ErrWebhookTarget) {
+			t.Errorf("CheckWebhookURL(%q) = %v, want ErrWebhookTarget", raw, err)
+		}
+	}
+
+	allowed := []string{"https://93.184.216.34/hook", "http://[2606:2800:220:1:248:1893:25c8:1946]/hook"}
+	for _, raw := range allowed {
+		if err := CheckWebhookURL(context.Background(), raw); err != nil {
+			t.Errorf("CheckWebhookURL(%q) = %v, want nil", raw, err)
+		}
+	}
+}
+
+func TestWebhookNotifierRefusesInternalAddresses(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier("")
+	err := n.Notify(context.Background(), Recipient{Target: srv.URL}, Message{Kind: "test"})
+	if !errors.Is(err, ErrWebhookTarget) {
+		t.Errorf("Notify to %s = %v, want ErrWebhookTarget", srv.URL, err)
+	}
+	if hit {
+		t.Error("the internal server was reached")
+	}
+}
+
+func TestWebhookNotifierRefusesRedirectsToInternalAddresses(t *testing.T) {
+	// The redirector stands for a public host on 127.0.0.1, the target for
+	// an internal one on 127.0.0.2
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect target was reached")
+	}))
+	internal.Listener.Close()
+	internal.Listener = ln
+	internal.Start()
+	defer internal.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirector.Close()
+
+	n := newWebhookNotifier("", func(ip net.IP) bool { return ip.Equal(net.IPv4(127, 0, 0, 1)) })
+	err = n.Notify(context.Background(), Recipient{Target: redirector.URL}, Message{Kind: "test"})
+	if !errors.Is(err, ErrWebhookTarget) {
+		t.Errorf("Notify = %v, want ErrWebhookTarget", err)
+	}
+}
+
+func TestWebhookNotifierSignsPayload(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature")
+	}))
+	defer srv.Close()
+
+	n := newWebhookNotifier("s3cret", func(ip net.IP) bool { return ip.IsLoopback() })
+	msg := Message{Kind: "budget_threshold", Subject: "Food reached 80% of its budget", Body: "..."}
+	if err := n.Notify(context.Background(), Recipient{WorkspaceID: 7, Target: srv.URL}, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var got webhookPayload
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode %q: %v", body, err)
+	}
+	if got.Kind != msg.Kind || got.Subject != msg.Subject || got.WorkspaceID != 7 {
+		t.Errorf("payload = %+v", got)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("X-Signature = %q, want %q", signature, want)
+	}
+}
+
+func TestWebhookNotifierReportsFailedResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	n := newWebhookNotifier("", func(ip net.IP) bool { return ip.IsLoopback() })
+	if err := n.Notify(context.Background(), Recipient{Target: srv.URL}, Message{Kind: "test"}); err == nil {
+		t.Error("Notify succeeded on a 500 response")
+	}
+}
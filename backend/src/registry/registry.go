@@ -1,11 +1,23 @@
 package registry
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
 	"expenses-tracker/src/config"
 	"expenses-tracker/src/handler"
+	"expenses-tracker/src/mailer"
 	"expenses-tracker/src/middleware"
+	"expenses-tracker/src/migrate"
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/notify"
 	"expenses-tracker/src/repository"
+	"expenses-tracker/src/scheduler"
+	"expenses-tracker/src/security"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -15,25 +27,62 @@ type Registry struct {
 	DB *gorm.DB
 
 	// Repositories
-	UserRepo         *repository.UserRepository
-	ExpenseRepo      *repository.ExpenseRepository
-	IncomeRepo       *repository.IncomeRepository
-	CategoryRepo     *repository.CategoryRepository
-	BudgetRepo       *repository.BudgetRepository
-	TemplateRepo     *repository.TemplateRepository
-	RefreshTokenRepo *repository.RefreshTokenRepository
-	QuickAmountRepo  *repository.QuickAmountRepository
-	WorkspaceRepo    *repository.WorkspaceRepository
+	UserRepo          *repository.UserRepository
+	ExpenseRepo       *repository.ExpenseRepository
+	IncomeRepo        *repository.IncomeRepository
+	CategoryRepo      *repository.CategoryRepository
+	BudgetRepo        *repository.BudgetRepository
+	TemplateRepo      *repository.TemplateRepository
+	RefreshTokenRepo  *repository.RefreshTokenRepository
+	RecoveryCodeRepo  *repository.RecoveryCodeRepository
+	UserTokenRepo     *repository.UserTokenRepository
+	AuditRepo         *repository.AuditRepository
+	LoginThrottleRepo *repository.LoginThrottleRepository
+	QuickAmountRepo   *repository.QuickAmountRepository
+	WorkspaceRepo     *repository.WorkspaceRepository
+	MemberRepo        *repository.WorkspaceMemberRepository
+	RecurringRepo     *repository.RecurringRepository
+	ExportRepo        *repository.ExportRepository
+	AccountRepo       *repository.AccountRepository
+	TransferRepo      *repository.TransferRepository
+	BudgetAlertRepo   *repository.BudgetAlertRepository
+	NotificationRepo  *repository.NotificationRepository
+	AccessTokenRepo   *repository.PersonalAccessTokenRepository
+	IdentityRepo      *repository.UserIdentityRepository
+	TrashRepo         *repository.TrashRepository
+	PersonalDataRepo  *repository.PersonalDataRepository
+	ExchangeRateRepo  *repository.ExchangeRateRepository
 
 	// Handlers
-	AuthHandler        *handler.AuthHandler
-	ExpenseHandler     *handler.ExpenseHandler
-	IncomeHandler      *handler.IncomeHandler
-	CategoryHandler    *handler.CategoryHandler
-	BudgetHandler      *handler.BudgetHandler
-	TemplateHandler    *handler.TemplateHandler
-	QuickAmountHandler *handler.QuickAmountHandler
-	WorkSpaceHandler   *handler.WorkspaceHandler
+	AuthHandler         *handler.AuthHandler
+	TwoFactorHandler    *handler.TwoFactorHandler
+	ExpenseHandler      *handler.ExpenseHandler
+	IncomeHandler       *handler.IncomeHandler
+	CategoryHandler     *handler.CategoryHandler
+	BudgetHandler       *handler.BudgetHandler
+	TemplateHandler     *handler.TemplateHandler
+	QuickAmountHandler  *handler.QuickAmountHandler
+	WorkSpaceHandler    *handler.WorkspaceHandler
+	MemberHandler       *handler.WorkspaceMemberHandler
+	RecurringHandler    *handler.RecurringHandler
+	ImportHandler       *handler.ImportHandler
+	ExportHandler       *handler.ExportHandler
+	AccountHandler      *handler.AccountHandler
+	TransferHandler     *handler.TransferHandler
+	BudgetAlertHandler  *handler.BudgetAlertHandler
+	NotificationHandler *handler.NotificationHandler
+	AccessTokenHandler  *handler.PersonalAccessTokenHandler
+	OIDCHandler         *handler.OIDCHandler
+	AuditHandler        *handler.AuditHandler
+	TrashHandler        *handler.TrashHandler
+	PersonalDataHandler *handler.PersonalDataHandler
+	ExchangeRateHandler *handler.ExchangeRateHandler
+
+	// Background jobs
+	RecurringScheduler    *scheduler.RecurringScheduler
+	TokenCleanupScheduler *scheduler.TokenCleanupScheduler
+	TrashScheduler        *scheduler.TrashRetentionScheduler
+	AccountPurgeScheduler *scheduler.AccountPurgeScheduler
 
 	// Middleware
 	AuthMiddleware echo.MiddlewareFunc
@@ -46,21 +95,24 @@ func NewRegistry() (*Registry, error) {
 		return nil, err
 	}
 
-	// Auto migrate
-	if err := db.AutoMigrate(
-		&model.M_user{},
-		&model.T_expense{},
-		&model.M_category{},
-		&model.M_expense_template{},
-		&model.T_income{},
-		&model.R_balance{},
-		&model.R_budget{},
-		&model.M_refresh_token{},
-		&model.M_quick_amount{},
-		&model.M_workspace{},
-	); err != nil {
+	// Refuse to run against a schema this binary does not expect. With
+	// MIGRATE_ON_START=true pending migrations are applied first instead.
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
 		return nil, err
 	}
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		if _, err := migrator.Up(context.Background(), 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		return nil, fmt.Errorf("%w; run \"migrate up\" first", err)
+	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
@@ -70,41 +122,214 @@ func NewRegistry() (*Registry, error) {
 	budgetRepo := repository.NewBudgetRepository(db)
 	templateRepo := repository.NewTemplateRepository(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
 	quickAmountRepo := repository.NewQuickAmountRepository(db)
 	workspaceRepo := repository.NewWorkspaceRepository(db)
+	memberRepo := repository.NewWorkspaceMemberRepository(db)
+	recurringRepo := repository.NewRecurringRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+	transferRepo := repository.NewTransferRepository(db)
+	budgetAlertRepo := repository.NewBudgetAlertRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	accessTokenRepo := repository.NewPersonalAccessTokenRepository(db)
+	identityRepo := repository.NewUserIdentityRepository(db)
+	trashRepo := repository.NewTrashRepository(db)
+	personalDataRepo := repository.NewPersonalDataRepository(db)
+	exchangeRateRepo := repository.NewExchangeRateRepository(db)
+
+	// Initialize mail and notifiers
+	mailConfig := config.LoadMailConfig()
+	mail, err := mailer.New(mailConfig)
+	if err != nil {
+		return nil, err
+	}
+	channels := map[string]notify.Notifier{
+		model.ChannelWebhook: notify.NewWebhookNotifier(os.Getenv("WEBHOOK_SECRET")),
+	}
+	// Development mailers deliver nothing, so email channels are only
+	// offered when mail really goes out
+	if mailConfig.SMTP.Host != "" {
+		channels[model.ChannelEmail] = notify.NewEmailNotifier(mail)
+	}
+	dispatcher := notify.NewDispatcher(notify.NewInboxNotifier(notificationRepo), channels, notificationRepo, workspaceRepo)
+	budgetAlerter := notify.NewBudgetAlerter(budgetAlertRepo, dispatcher)
+
+	loginGuard := security.NewLoginGuard(loginThrottleRepo, auditRepo)
+	jwtKeys, err := security.NewKeySet(config.LoadJWTConfig())
+	if err != nil {
+		return nil, err
+	}
+	totpSecrets, err := security.NewSecretBox(config.LoadTOTPConfig())
+	if err != nil {
+		return nil, err
+	}
+	// Two-factor secrets enrolled before they were encrypted
+	if sealed, err := userRepo.SealTOTPSecrets(totpSecrets.Seal); err != nil {
+		return nil, err
+	} else if sealed > 0 {
+		log.Printf("Encrypted %d two-factor secret(s) stored in the clear", sealed)
+	}
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(userRepo, refreshTokenRepo, db)
-	expenseHandler := handler.NewExpenseHandler(db, expenseRepo, categoryRepo)
-	incomeHandler := handler.NewIncomeHandler(incomeRepo, categoryRepo)
-	categoryHandler := handler.NewCategoryHandler(categoryRepo)
-	budgetHandler := handler.NewBudgetHandler(budgetRepo, categoryRepo)
+	authHandler := handler.NewAuthHandler(userRepo, refreshTokenRepo, accessTokenRepo, recoveryCodeRepo, userTokenRepo, exchangeRateRepo, mail, loginGuard, jwtKeys, totpSecrets, db)
+	twoFactorHandler := handler.NewTwoFactorHandler(userRepo, recoveryCodeRepo, totpSecrets, db)
+	expenseHandler := handler.NewExpenseHandler(db, expenseRepo, categoryRepo, accountRepo, exchangeRateRepo, budgetAlerter, auditRepo)
+	incomeHandler := handler.NewIncomeHandler(db, incomeRepo, categoryRepo, accountRepo, exchangeRateRepo, auditRepo)
+	categoryHandler := handler.NewCategoryHandler(db, categoryRepo, auditRepo)
+	budgetHandler := handler.NewBudgetHandler(db, budgetRepo, categoryRepo, expenseRepo, budgetAlertRepo, auditRepo)
 	templateHandler := handler.NewTemplateHandler(templateRepo, categoryRepo)
 	quickAmountHandler := handler.NewQuickAmountHandler(quickAmountRepo)
-	workspaceHandler := handler.NewWorkspaceHandler(workspaceRepo, userRepo, db)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceRepo, userRepo, auditRepo, db)
+	memberHandler := handler.NewWorkspaceMemberHandler(memberRepo, workspaceRepo, userRepo, mail)
+	recurringHandler := handler.NewRecurringHandler(recurringRepo, categoryRepo, accountRepo)
+	importHandler := handler.NewImportHandler(db, categoryRepo, expenseRepo, incomeRepo, accountRepo, exchangeRateRepo, budgetAlerter, auditRepo)
+	exportHandler := handler.NewExportHandler(exportRepo)
+	accountHandler := handler.NewAccountHandler(accountRepo)
+	transferHandler := handler.NewTransferHandler(transferRepo, accountRepo)
+	budgetAlertHandler := handler.NewBudgetAlertHandler(budgetAlertRepo, budgetRepo)
+	notificationHandler := handler.NewNotificationHandler(notificationRepo, dispatcher)
+	accessTokenHandler := handler.NewPersonalAccessTokenHandler(accessTokenRepo)
+	auditHandler := handler.NewAuditHandler(auditRepo)
+	trashHandler := handler.NewTrashHandler(db, trashRepo, budgetAlerter, auditRepo)
+	exchangeRateHandler := handler.NewExchangeRateHandler(exchangeRateRepo)
 
-	// Initialize middleware (auth with JWT + refresh using Postgres)
-	authMiddleware := middleware.CustomContextMiddleware(userRepo, refreshTokenRepo)
+	// Deleted accounts can be recovered for ACCOUNT_DELETION_GRACE_DAYS
+	deletionGrace, err := envDays("ACCOUNT_DELETION_GRACE_DAYS", 14)
+	if err != nil {
+		return nil, err
+	}
+	personalDataHandler := handler.NewPersonalDataHandler(userRepo, recoveryCodeRepo, totpSecrets, personalDataRepo, mail, deletionGrace)
+	oidcHandler := handler.NewOIDCHandler(config.LoadOIDCConfig(), userRepo, identityRepo, userTokenRepo, authHandler, jwtKeys, db)
+
+	// Initialize background jobs
+	recurringInterval, err := envDuration("RECURRING_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	recurringScheduler := scheduler.NewRecurringScheduler(recurringRepo, budgetAlerter, recurringInterval)
+
+	tokenCleanupInterval, err := envDuration("TOKEN_CLEANUP_INTERVAL", 6*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	tokenCleanupScheduler := scheduler.NewTokenCleanupScheduler(refreshTokenRepo, userTokenRepo, loginThrottleRepo, tokenCleanupInterval)
+
+	// Deleted items stay restorable for TRASH_RETENTION_DAYS
+	trashRetention, err := envDays("TRASH_RETENTION_DAYS", 30)
+	if err != nil {
+		return nil, err
+	}
+	trashInterval, err := envDuration("TRASH_CLEANUP_INTERVAL", 6*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	trashScheduler := scheduler.NewTrashRetentionScheduler(trashRepo, trashRetention, trashInterval)
+
+	accountPurgeInterval, err := envDuration("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	accountPurgeScheduler := scheduler.NewAccountPurgeScheduler(personalDataRepo, accountPurgeInterval)
+
+	// Initialize middleware (auth with JWT + refresh, or a personal access token, using Postgres)
+	authMiddleware := middleware.CustomContextMiddleware(userRepo, refreshTokenRepo, workspaceRepo, accessTokenRepo, jwtKeys)
 
 	return &Registry{
-		DB:                 db,
-		UserRepo:           userRepo,
-		ExpenseRepo:        expenseRepo,
-		IncomeRepo:         incomeRepo,
-		CategoryRepo:       categoryRepo,
-		BudgetRepo:         budgetRepo,
-		TemplateRepo:       templateRepo,
-		RefreshTokenRepo:   refreshTokenRepo,
-		QuickAmountRepo:    quickAmountRepo,
-		WorkspaceRepo:      workspaceRepo,
-		AuthHandler:        authHandler,
-		ExpenseHandler:     expenseHandler,
-		IncomeHandler:      incomeHandler,
-		CategoryHandler:    categoryHandler,
-		BudgetHandler:      budgetHandler,
-		TemplateHandler:    templateHandler,
-		QuickAmountHandler: quickAmountHandler,
-		WorkSpaceHandler:   workspaceHandler,
-		AuthMiddleware:     authMiddleware,
+		DB:                    db,
+		UserRepo:              userRepo,
+		ExpenseRepo:           expenseRepo,
+		IncomeRepo:            incomeRepo,
+		CategoryRepo:          categoryRepo,
+		BudgetRepo:            budgetRepo,
+		TemplateRepo:          templateRepo,
+		RefreshTokenRepo:      refreshTokenRepo,
+		RecoveryCodeRepo:      recoveryCodeRepo,
+		UserTokenRepo:         userTokenRepo,
+		AuditRepo:             auditRepo,
+		LoginThrottleRepo:     loginThrottleRepo,
+		QuickAmountRepo:       quickAmountRepo,
+		WorkspaceRepo:         workspaceRepo,
+		MemberRepo:            memberRepo,
+		RecurringRepo:         recurringRepo,
+		ExportRepo:            exportRepo,
+		AccountRepo:           accountRepo,
+		TransferRepo:          transferRepo,
+		BudgetAlertRepo:       budgetAlertRepo,
+		NotificationRepo:      notificationRepo,
+		AccessTokenRepo:       accessTokenRepo,
+		IdentityRepo:          identityRepo,
+		TrashRepo:             trashRepo,
+		PersonalDataRepo:      personalDataRepo,
+		ExchangeRateRepo:      exchangeRateRepo,
+		AuthHandler:           authHandler,
+		TwoFactorHandler:      twoFactorHandler,
+		ExpenseHandler:        expenseHandler,
+		IncomeHandler:         incomeHandler,
+		CategoryHandler:       categoryHandler,
+		BudgetHandler:         budgetHandler,
+		TemplateHandler:       templateHandler,
+		QuickAmountHandler:    quickAmountHandler,
+		WorkSpaceHandler:      workspaceHandler,
+		MemberHandler:         memberHandler,
+		RecurringHandler:      recurringHandler,
+		ImportHandler:         importHandler,
+		ExportHandler:         exportHandler,
+		AccountHandler:        accountHandler,
+		TransferHandler:       transferHandler,
+		BudgetAlertHandler:    budgetAlertHandler,
+		NotificationHandler:   notificationHandler,
+		AccessTokenHandler:    accessTokenHandler,
+		OIDCHandler:           oidcHandler,
+		AuditHandler:          auditHandler,
+		TrashHandler:          trashHandler,
+		PersonalDataHandler:   personalDataHandler,
+		ExchangeRateHandler:   exchangeRateHandler,
+		RecurringScheduler:    recurringScheduler,
+		TokenCleanupScheduler: tokenCleanupScheduler,
+		TrashScheduler:        trashScheduler,
+		AccountPurgeScheduler: accountPurgeScheduler,
+		AuthMiddleware:        authMiddleware,
 	}, nil
 }
+
+// envDuration reads a positive duration such as "6h" from the environment
+// variable name, or returns def when it is unset. Anything else is refused
+// rather than ignored, so a typo cannot silently keep the default.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s=%q is not a positive duration such as 6h", name, v)
+	}
+	return d, nil
+}
+
+// envDays reads a positive whole number of days from the environment
+// variable name, or returns def days when it is unset.
+func envDays(name string, def int) (time.Duration, error) {
+	days := def
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%s=%q is not a positive number of days", name, v)
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// Close releases the database connections.
+func (r *Registry) Close() error {
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvDuration(t *testing.T) {
+	if d, err := envDuration("TEST_INTERVAL", time.Hour); err != nil || d != time.Hour {
+		t.Errorf("unset: %v, %v; want the default", d, err)
+	}
+	t.Setenv("TEST_INTERVAL", "90m")
+	if d, err := envDuration("TEST_INTERVAL", time.Hour); err != nil || d != 90*time.Minute {
+		t.Errorf("90m: %v, %v", d, err)
+	}
+	for _, v := range []string{"6", "-1h", "0s", "soon"} {
+		t.Setenv("TEST_INTERVAL", v)
+		if _, err := envDuration("TEST_INTERVAL", time.Hour); err == nil {
+			t.Errorf("%q accepted", v)
+		}
+	}
+}
+
+func TestEnvDays(t *testing.T) {
+	if d, err := envDays("TEST_DAYS", 30); err != nil || d != 30*24*time.Hour {
+		t.Errorf("unset: %v, %v; want 30 days", d, err)
+	}
+	t.Setenv("TEST_DAYS", "7")
+	if d, err := envDays("TEST_DAYS", 30); err != nil || d != 7*24*time.Hour {
+		t.Errorf("7: %v, %v", d, err)
+	}
+	for _, v := range []string{"0", "-3", "1.5", "7d", "a week"} {
+		t.Setenv("TEST_DAYS", v)
+		if _, err := envDays("TEST_DAYS", 30); err == nil {
+			t.Errorf("%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAccountInUse is returned when deleting an account that an income,
+// expense or transfer refers to.
+var ErrAccountInUse = errors.New("account is in use")
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *AccountRepository) WithTx(tx *gorm.DB) *AccountRepository {
+	return &AccountRepository{db: tx}
+}
+
+// AccountBalance is an account with its current balance.
+type AccountBalance struct {
+	AccountID      uint         `json:"accountId"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	IsArchived     bool         `json:"isArchived"`
+	OpeningBalance money.Amount `json:"openingBalance"`
+	Balance        money.Amount `json:"balance"`
+}
+
+// LedgerEntry is one movement on an account with the balance right after it.
+// Kind is income, expense, transfer_in or transfer_out; Amount is signed.
+type LedgerEntry struct {
+	Kind    string       `json:"kind"`
+	ID      uint         `json:"id"`
+	Date    time.Time    `json:"date"`
+	Amount  money.Amount `json:"amount"`
+	Notes   string       `json:"notes"`
+	Balance money.Amount `json:"balance"`
+}
+
+func (r *AccountRepository) GetAll(userID uint, workspaceID uint, includeArchived bool) ([]model.M_account, error) {
+	var accounts []model.M_account
+	db := r.db.Scopes(WorkspaceScope(userID, workspaceID))
+	if !includeArchived {
+		db = db.Where("is_archived = ?", false)
+	}
+	if err := db.Order("sequence ASC, id ASC").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (r *AccountRepository) GetByID(userID uint, workspaceID uint, id uint) (*model.M_account, error) {
+	var a model.M_account
+	if err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).Where("id = ?", id).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AccountRepository) Create(account *model.M_account) error {
+	return r.db.Create(account).Error
+}
+
+func (r *AccountRepository) Update(account *model.M_account) error {
+	return r.db.Save(account).Error
+}
+
+// Delete removes an account nothing refers to, failing with ErrAccountInUse
+// otherwise. The account row stays locked between the check and the delete.
+func (r *AccountRepository) Delete(userID uint, workspaceID uint, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var a model.M_account
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("id = ?", id).
+			First(&a).Error; err != nil {
+			return err
+		}
+		used, err := accountInUse(tx, a.ID)
+		if err != nil {
+			return err
+		}
+		if used {
+			return ErrAccountInUse
+		}
+		return tx.Delete(&a).Error
+	})
+}
+
+// accountInUse reports whether any income, expense or transfer refers to the account.
+func accountInUse(db *gorm.DB, id uint) (bool, error) {
+	var used bool
+	err := db.Raw(`SELECT
+		EXISTS (SELECT 1 FROM t_expenses WHERE account_id = @id AND deleted_at IS NULL)
+		OR EXISTS (SELECT 1 FROM t_incomes WHERE account_id = @id AND deleted_at IS NULL)
+		OR EXISTS (SELECT 1 FROM t_transfers WHERE (from_account_id = @id OR to_account_id = @id) AND deleted_at IS NULL)`,
+		map[string]interface{}{"id": id}).
+		Scan(&used).Error
+	return used, err
+}
+
+// Balances returns the current balance of every account in the workspace,
+// archived ones included.
+func (r *AccountRepository) Balances(userID uint, workspaceID uint) ([]AccountBalance, error) {
+	var rows []AccountBalance
+	if err := r.db.
+		Model(&model.M_account{}).
+		Select(`m_accounts.id AS account_id, m_accounts.name, m_accounts.type, m_accounts.is_archived, m_accounts.opening_balance,
+			m_accounts.opening_balance
+			+ COALESCE((SELECT SUM(amount) FROM t_incomes WHERE t_incomes.account_id = m_accounts.id AND t_incomes.deleted_at IS NULL), 0)
+			- COALESCE((SELECT SUM(amount) FROM t_expenses WHERE t_expenses.account_id = m_accounts.id AND t_expenses.deleted_at IS NULL), 0)
+			+ COALESCE((SELECT SUM(amount) FROM t_transfers WHERE t_transfers.to_account_id = m_accounts.id AND t_transfers.deleted_at IS NULL), 0)
+			- COALESCE((SELECT SUM(amount) FROM t_transfers WHERE t_transfers.from_account_id = m_accounts.id AND t_transfers.deleted_at IS NULL), 0)
+			AS balance`).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Order("m_accounts.sequence ASC, m_accounts.id ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Ledger lists the movements on an account dated within [from, to] (either
+// bound may be nil), oldest first, each with the running balance after it.
+// Movements before from still count towards the running balance.
+func (r *AccountRepository) Ledger(account *model.M_account, from, to *time.Time) ([]LedgerEntry, error) {
+	var entries []LedgerEntry
+	query := r.db.Raw(`WITH movements AS (
+			SELECT 'income' AS kind, id, date, amount, notes FROM t_incomes
+				WHERE account_id = @id AND deleted_at IS NULL
+			UNION ALL
+			SELECT 'expense', id, date, -amount, notes FROM t_expenses
+				WHERE account_id = @id AND deleted_at IS NULL
+			UNION ALL
+			SELECT 'transfer_in', id, date, amount, notes FROM t_transfers
+				WHERE to_account_id = @id AND deleted_at IS NULL
+			UNION ALL
+			SELECT 'transfer_out', id, date, -amount, notes FROM t_transfers
+				WHERE from_account_id = @id AND deleted_at IS NULL
+		), ledger AS (
+			SELECT kind, id, date, amount, notes,
+				@opening + SUM(amount) OVER (ORDER BY date, kind, id ROWS UNBOUNDED PRECEDING) AS balance
+			FROM movements
+		)
+		SELECT * FROM ledger
+		WHERE (CAST(@from AS date) IS NULL OR date >= @from) AND (CAST(@to AS date) IS NULL OR date <= @to)
+		ORDER BY date, kind, id`,
+		map[string]interface{}{"id": account.ID, "opening": account.OpeningBalance, "from": from, "to": to})
+	if err := query.Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+// auditUnchanged lists fields left out of update diffs because every save
+// changes them.
+var auditUnchanged = map[string]bool{"updatedAt": true}
+
+// AuditFilter narrows an audit log listing; zero values match everything.
+type AuditFilter struct {
+	UserID   uint
+	Action   string
+	Entity   string
+	EntityID uint
+	From     *time.Time
+	To       *time.Time // exclusive
+}
+
+// AuditRepository only ever appends: there is no way to change or remove an
+// entry once written.
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *AuditRepository) WithTx(tx *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: tx}
+}
+
+// Record appends entry. before and after are marshalled to JSON; nil leaves
+// them empty. When both are given only the fields that differ are kept, and
+// nothing is recorded if none do.
+func (r *AuditRepository) Record(entry *model.T_audit_log, before, after interface{}) error {
+	var err error
+	if entry.Before, err = marshalAudit(before); err != nil {
+		return err
+	}
+	if entry.After, err = marshalAudit(after); err != nil {
+		return err
+	}
+	if entry.Before != nil && entry.After != nil {
+		var changed bool
+		if entry.Before, entry.After, changed, err = diffAudit(entry.Before, entry.After); err != nil || !changed {
+			return err
+		}
+	}
+	return r.db.Create(entry).Error
+}
+
+// List returns a page of the workspace's audit log, newest first, and the
+// number of entries matching filter across all pages. Sign-in lockouts stay
+// in the table for operators but are left out: they are not changes to the
+// data.
+func (r *AuditRepository) List(userID uint, workspaceID uint, filter AuditFilter, page, pageSize int) ([]model.T_audit_log, int64, error) {
+	query := r.db.Model(&model.T_audit_log{}).Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("action <> ?", model.AuditLockout)
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.T_audit_log
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func marshalAudit(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// diffAudit drops the top-level fields that before and after share. Values
+// that are not JSON objects are compared whole.
+func diffAudit(before, after json.RawMessage) (json.RawMessage, json.RawMessage, bool, error) {
+	var b, a map[string]json.RawMessage
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil {
+		return before, after, !bytes.Equal(before, after), nil
+	}
+	for k, v := range b {
+		if auditUnchanged[k] || bytes.Equal(v, a[k]) {
+			delete(b, k)
+			delete(a, k)
+		}
+	}
+	for k := range a {
+		if auditUnchanged[k] {
+			delete(a, k)
+		}
+	}
+	if len(b) == 0 && len(a) == 0 {
+		return nil, nil, false, nil
+	}
+	before, err := json.Marshal(b)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	after, err = json.Marshal(a)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return before, after, true, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestAuditListLeavesOutLockouts(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := NewAuditRepository(db)
+	for _, entry := range []*model.T_audit_log{
+		{UserID: &user.ID, Action: model.AuditLockout, Entity: model.AuditUser, EntityID: &user.ID},
+		{UserID: &user.ID, Action: model.AuditCreate, Entity: model.AuditCategory},
+	} {
+		if err := repo.Record(entry, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, total, err := repo.List(user.ID, 0, AuditFilter{}, 1, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(items) != 1 || items[0].Action != model.AuditCreate {
+		t.Errorf("personal audit log = %+v (total %d), want only the create", items, total)
+	}
+}
+
+func TestMaterializeAuditsCreatedRows(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	cat := model.M_category{Name: "Rent", Type: "expense", UserID: user.ID}
+	if err := db.Create(&cat).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := model.M_recurring_rule{
+		UserID: user.ID, Type: "expense", Categories: []model.M_category{cat},
+		Amount: 100 * money.One, Frequency: "monthly", Interval: 1, StartDate: start, NextDate: start,
+	}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewRecurringRepository(db).Materialize(rule.ID, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil || m.Created != 3 {
+		t.Fatalf("Materialize = %+v, %v; want 3 created", m, err)
+	}
+	var entries []model.T_audit_log
+	if err := db.Where("action = ? AND entity = ?", model.AuditCreate, model.AuditExpense).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].UserID == nil || *entries[0].UserID != user.ID || entries[0].EntityID == nil {
+		t.Errorf("audit entries %+v, want one per created expense", entries)
+	}
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BudgetAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewBudgetAlertRepository(db *gorm.DB) *BudgetAlertRepository {
+	return &BudgetAlertRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *BudgetAlertRepository) WithTx(tx *gorm.DB) *BudgetAlertRepository {
+	return &BudgetAlertRepository{db: tx}
+}
+
+// GetThresholds lists the workspace's thresholds with their budgets, only
+// those of budgets for month (YYYY-MM) when it is not empty.
+func (r *BudgetAlertRepository) GetThresholds(userID uint, workspaceID uint, month string) ([]model.M_budget_threshold, error) {
+	var items []model.M_budget_threshold
+	db := r.db.Preload("Budget.Category").
+		Joins("JOIN r_budgets ON r_budgets.id = m_budget_thresholds.budget_id AND r_budgets.deleted_at IS NULL").
+		Scopes(WorkspaceScope(userID, workspaceID))
+	if month != "" {
+		db = db.Where("r_budgets.month = ?", month)
+	}
+	if err := db.Order("r_budgets.month DESC, r_budgets.category_id ASC, m_budget_thresholds.percent ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ReplaceThresholds sets the alert percentages of a budget, dropping any that
+// are no longer listed.
+func (r *BudgetAlertRepository) ReplaceThresholds(userID uint, workspaceID uint, budgetID uint, percents []int) ([]model.M_budget_threshold, error) {
+	items := make([]model.M_budget_threshold, 0, len(percents))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.M_budget_threshold
+		if err := tx.Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("budget_id = ?", budgetID).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		keep := make(map[int]bool, len(percents))
+		for _, p := range percents {
+			keep[p] = true
+		}
+		have := make(map[int]bool, len(existing))
+		for _, t := range existing {
+			if !keep[t.Percent] || have[t.Percent] {
+				if err := tx.Delete(&t).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			have[t.Percent] = true
+			items = append(items, t)
+		}
+		for _, p := range percents {
+			if have[p] {
+				continue
+			}
+			t := model.M_budget_threshold{UserID: userID, WorkspaceID: workspaceID, BudgetID: budgetID, Percent: p}
+			if err := tx.Omit("Budget").Create(&t).Error; err != nil {
+				return err
+			}
+			have[p] = true
+			items = append(items, t)
+		}
+		return nil
+	})
+	return items, err
+}
+
+// CopyThresholds gives the budgets of toMonth that have no thresholds yet the
+// thresholds of the same category's budget in fromMonth.
+func (r *BudgetAlertRepository) CopyThresholds(userID uint, workspaceID uint, fromMonth, toMonth string) error {
+	var sources []model.M_budget_threshold
+	if err := r.db.Preload("Budget").
+		Joins("JOIN r_budgets ON r_budgets.id = m_budget_thresholds.budget_id AND r_budgets.deleted_at IS NULL").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("r_budgets.month = ?", fromMonth).
+		Find(&sources).Error; err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	budgets := &BudgetRepository{db: r.db}
+	targets, err := budgets.GetByUserAndMonth(userID, workspaceID, toMonth)
+	if err != nil {
+		return err
+	}
+	var watched []uint
+	if err := r.db.Model(&model.M_budget_threshold{}).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("budget_id IN (?)", r.db.Model(&model.R_budget{}).Select("id").Scopes(WorkspaceScope(userID, workspaceID)).Where("month = ?", toMonth)).
+		Distinct().
+		Pluck("budget_id", &watched).Error; err != nil {
+		return err
+	}
+	skip := make(map[uint]bool, len(watched))
+	for _, id := range watched {
+		skip[id] = true
+	}
+
+	var copies []model.M_budget_threshold
+	for _, b := range targets {
+		if skip[b.ID] {
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, t := range sources {
+			if t.Budget.CategoryID != b.CategoryID || seen[t.Percent] {
+				continue
+			}
+			seen[t.Percent] = true
+			copies = append(copies, model.M_budget_threshold{UserID: userID, WorkspaceID: workspaceID, BudgetID: b.ID, Percent: t.Percent})
+		}
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+	return r.db.Omit("Budget").Create(&copies).Error
+}
+
+// GetAlerts lists fired alerts newest first, optionally for one month only.
+func (r *BudgetAlertRepository) GetAlerts(userID uint, workspaceID uint, month string) ([]model.T_budget_alert, error) {
+	var items []model.T_budget_alert
+	db := r.db.Scopes(WorkspaceScope(userID, workspaceID))
+	if month != "" {
+		db = db.Where("month = ?", month)
+	}
+	if err := db.Order("created_at DESC, id DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Evaluate compares spending in month (YYYY-MM) against the thresholds of
+// the month's budgets and records the thresholds that have been reached. Only
+// alerts that fired for the first time are returned; a threshold fires at
+// most once per month. Rollover categories are measured against their
+// available amount, including what was carried in.
+func (r *BudgetAlertRepository) Evaluate(userID uint, workspaceID uint, month string) ([]model.T_budget_alert, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds, err := r.GetThresholds(userID, workspaceID, month)
+	if err != nil || len(thresholds) == 0 {
+		return nil, err
+	}
+
+	budgets := &BudgetRepository{db: r.db}
+	planned, err := budgets.SumByCategory(userID, workspaceID, month, month)
+	if err != nil {
+		return nil, err
+	}
+	spent, err := (&ExpenseRepository{db: r.db}).SumByCategory(userID, workspaceID, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	plannedBy := make(map[uint]money.Amount, len(planned))
+	for _, p := range planned {
+		plannedBy[p.CategoryID] = p.Total
+	}
+	spentBy := make(map[uint]money.Amount, len(spent))
+	for _, s := range spent {
+		spentBy[s.CategoryID] = s.Total
+	}
+
+	categories := make([]model.M_category, 0, len(thresholds))
+	for _, t := range thresholds {
+		categories = append(categories, t.Budget.Category)
+	}
+	carried, err := budgets.CarriedInto(userID, workspaceID, categories, month)
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []model.T_budget_alert
+	for _, t := range thresholds {
+		catID := t.Budget.CategoryID
+		avail := plannedBy[catID] + carried[catID]
+		if avail <= 0 {
+			continue
+		}
+		// A limit past the largest amount cannot be reached
+		limit, err := avail.MulDiv(int64(t.Percent), 100)
+		if err != nil || spentBy[catID] < limit {
+			continue
+		}
+
+		alert := model.T_budget_alert{
+			UserID:      t.UserID,
+			WorkspaceID: workspaceID,
+			ThresholdID: t.ID,
+			CategoryID:  catID,
+			Category:    t.Budget.Category.Name,
+			Month:       month,
+			Percent:     t.Percent,
+			Available:   avail,
+			Spent:       spentBy[catID],
+		}
+		res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected > 0 {
+			fired = append(fired, alert)
+		}
+	}
+	return fired, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestBudgetThresholdsBelongToOneBudget(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	cat := model.M_category{Name: "Food", Type: "expense", UserID: user.ID}
+	if err := db.Create(&cat).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	budgets := NewBudgetRepository(db)
+	expenses := NewExpenseRepository(db)
+	alerts := NewBudgetAlertRepository(db)
+	for _, month := range []string{"2024-03", "2024-04"} {
+		if _, err := budgets.Upsert(&model.R_budget{UserID: user.ID, CategoryID: cat.ID, Month: month, Amount: 100 * money.One}); err != nil {
+			t.Fatal(err)
+		}
+		d, _ := time.Parse("2006-01", month)
+		exp := model.T_expense{
+			UserID: user.ID, Date: d.AddDate(0, 0, 9), Currency: "IDR",
+			Amount: 90 * money.One, OriginalAmount: 90 * money.One,
+			Splits: []model.T_expense_split{{CategoryID: cat.ID, Amount: 90 * money.One}},
+		}
+		if err := expenses.Create(&exp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	march, err := budgets.GetByUserAndMonth(user.ID, 0, "2024-03")
+	if err != nil || len(march) != 1 {
+		t.Fatalf("March budgets: %v, %v", march, err)
+	}
+	if _, err := alerts.ReplaceThresholds(user.ID, 0, march[0].ID, []int{80}); err != nil {
+		t.Fatal(err)
+	}
+
+	fired, err := alerts.Evaluate(user.ID, 0, "2024-03")
+	if err != nil || len(fired) != 1 {
+		t.Fatalf("March: fired %v, err %v; want one alert", fired, err)
+	}
+	if fired[0].CategoryID != cat.ID || fired[0].Category != "Food" || fired[0].Available != 100*money.One {
+		t.Errorf("alert = %+v", fired[0])
+	}
+	if fired, err := alerts.Evaluate(user.ID, 0, "2024-03"); err != nil || len(fired) != 0 {
+		t.Errorf("March again: fired %v, err %v; want none", fired, err)
+	}
+	if fired, err := alerts.Evaluate(user.ID, 0, "2024-04"); err != nil || len(fired) != 0 {
+		t.Errorf("April without thresholds: fired %v, err %v; want none", fired, err)
+	}
+
+	if err := alerts.CopyThresholds(user.ID, 0, "2024-03", "2024-04"); err != nil {
+		t.Fatal(err)
+	}
+	if fired, err := alerts.Evaluate(user.ID, 0, "2024-04"); err != nil || len(fired) != 1 {
+		t.Errorf("April after copying: fired %v, err %v; want one alert", fired, err)
+	}
+	if list, err := alerts.GetThresholds(user.ID, 0, "2024-04"); err != nil || len(list) != 1 || list[0].Budget.Month != "2024-04" {
+		t.Errorf("April thresholds: %+v, %v", list, err)
+	}
+}
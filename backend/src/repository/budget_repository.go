@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"time"
+
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BudgetRepository struct {
@@ -14,10 +18,15 @@ func NewBudgetRepository(db *gorm.DB) *BudgetRepository {
 	return &BudgetRepository{db: db}
 }
 
-func (r *BudgetRepository) GetByUser(userID uint) ([]model.R_budget, error) {
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *BudgetRepository) WithTx(tx *gorm.DB) *BudgetRepository {
+	return &BudgetRepository{db: tx}
+}
+
+func (r *BudgetRepository) GetByUser(userID uint, workspaceID uint) ([]model.R_budget, error) {
 	var budgets []model.R_budget
 	if err := r.db.Preload("Category").
-		Where("user_id = ?", userID).
+		Scopes(WorkspaceScope(userID, workspaceID)).
 		Order("month DESC, category_id ASC").
 		Find(&budgets).Error; err != nil {
 		return nil, err
@@ -25,10 +34,11 @@ func (r *BudgetRepository) GetByUser(userID uint) ([]model.R_budget, error) {
 	return budgets, nil
 }
 
-func (r *BudgetRepository) GetByUserAndMonth(userID uint, month string) ([]model.R_budget, error) {
+func (r *BudgetRepository) GetByUserAndMonth(userID uint, workspaceID uint, month string) ([]model.R_budget, error) {
 	var budgets []model.R_budget
 	if err := r.db.Preload("Category").
-		Where("user_id = ? AND month = ?", userID, month).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("month = ?", month).
 		Order("category_id ASC").
 		Find(&budgets).Error; err != nil {
 		return nil, err
@@ -36,20 +46,69 @@ func (r *BudgetRepository) GetByUserAndMonth(userID uint, month string) ([]model
 	return budgets, nil
 }
 
+func (r *BudgetRepository) GetByID(userID uint, workspaceID uint, id uint) (*model.R_budget, error) {
+	var b model.R_budget
+	if err := r.db.Preload("Category").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SumByCategory totals the planned amounts per category for budgets of months
+// fromMonth through toMonth (YYYY-MM, inclusive).
+func (r *BudgetRepository) SumByCategory(userID uint, workspaceID uint, fromMonth, toMonth string) ([]CategoryTotal, error) {
+	var rows []CategoryTotal
+	if err := r.db.
+		Model(&model.R_budget{}).
+		Select("r_budgets.category_id, m_categories.name AS category, COALESCE(SUM(r_budgets.amount), 0) AS total").
+		Joins("JOIN m_categories ON m_categories.id = r_budgets.category_id AND m_categories.deleted_at IS NULL").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("r_budgets.month >= ? AND r_budgets.month <= ?", fromMonth, toMonth).
+		Group("r_budgets.category_id, m_categories.name, m_categories.sequence").
+		Order("m_categories.sequence ASC, r_budgets.category_id ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 func (r *BudgetRepository) Create(budget *model.R_budget) error {
 	return r.db.Create(budget).Error
 }
 
-func (r *BudgetRepository) DeleteByCategory(userID, categoryID uint, month string) error {
-	db := r.db.Where("user_id = ? AND category_id = ?", userID, categoryID)
-	if month != "" {
-		db = db.Where("month = ?", month)
+// DeleteByCategory deletes the category's budget for month, or for every
+// month when month is empty, and returns the budgets it deleted.
+func (r *BudgetRepository) DeleteByCategory(userID, workspaceID, categoryID uint, month string) ([]model.R_budget, error) {
+	var deleted []model.R_budget
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		db := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("category_id = ?", categoryID)
+		if month != "" {
+			db = db.Where("month = ?", month)
+		}
+		if err := db.Order("month ASC, id ASC").Find(&deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(deleted))
+		for i, b := range deleted {
+			ids[i] = b.ID
+		}
+		return tx.Delete(&model.R_budget{}, ids).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	return db.
-		Delete(&model.R_budget{}).Error
+	return deleted, nil
 }
 
-func (r *BudgetRepository) LatestMonth(userID uint) (string, error) {
+func (r *BudgetRepository) LatestMonth(userID uint, workspaceID uint) (string, error) {
 	type Row struct {
 		Month string
 	}
@@ -57,7 +116,7 @@ func (r *BudgetRepository) LatestMonth(userID uint) (string, error) {
 	if err := r.db.
 		Model(&model.R_budget{}).
 		Select("month").
-		Where("user_id = ?", userID).
+		Scopes(WorkspaceScope(userID, workspaceID)).
 		Order("month DESC").
 		Limit(1).
 		Scan(&row).Error; err != nil {
@@ -69,4 +128,182 @@ func (r *BudgetRepository) LatestMonth(userID uint) (string, error) {
 	return row.Month, nil
 }
 
+// EnvelopeMonth is one month of a rollover category's envelope. Available is
+// the planned amount plus whatever the previous month left over (negative
+// after an overspend); Balance is what is left at the end of the month and
+// becomes the next month's CarriedIn.
+type EnvelopeMonth struct {
+	Month     string       `json:"month"`
+	Planned   money.Amount `json:"planned"`
+	CarriedIn money.Amount `json:"carriedIn"`
+	Available money.Amount `json:"available"`
+	Spent     money.Amount `json:"spent"`
+	Balance   money.Amount `json:"balance"`
+}
+
+// EnvelopeHistory walks a category's envelope month by month from since
+// through the given month (both YYYY-MM). Months without a budget still carry
+// their balance forward.
+func (r *BudgetRepository) EnvelopeHistory(userID uint, workspaceID uint, categoryID uint, since, through string) ([]EnvelopeMonth, error) {
+	start, err := time.Parse("2006-01", since)
+	if err != nil {
+		return nil, err
+	}
+	last, err := time.Parse("2006-01", through)
+	if err != nil {
+		return nil, err
+	}
+	if last.Before(start) {
+		return []EnvelopeMonth{}, nil
+	}
+	totals, err := r.envelopeTotals(userID, workspaceID, []uint{categoryID}, start, last)
+	if err != nil {
+		return nil, err
+	}
+	return walkEnvelope(start, last, totals[categoryID]), nil
+}
+
+// CarriedInto returns the amount each rollover category carries into month:
+// the envelope balance at the end of the month before. Categories whose
+// rollover starts in or after month carry 0 and are left out of the map.
+func (r *BudgetRepository) CarriedInto(userID uint, workspaceID uint, categories []model.M_category, month string) (map[uint]money.Amount, error) {
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, err
+	}
+	last := m.AddDate(0, -1, 0)
+
+	carried := make(map[uint]money.Amount, len(categories))
+	starts := make(map[uint]time.Time, len(categories))
+	var first time.Time
+	for _, cat := range categories {
+		if cat.RolloverSince == nil || *cat.RolloverSince >= month {
+			continue
+		}
+		start, err := time.Parse("2006-01", *cat.RolloverSince)
+		if err != nil {
+			return nil, err
+		}
+		starts[cat.ID] = start
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+	}
+	if len(starts) == 0 {
+		return carried, nil
+	}
+
+	ids := make([]uint, 0, len(starts))
+	for id := range starts {
+		ids = append(ids, id)
+	}
+	totals, err := r.envelopeTotals(userID, workspaceID, ids, first, last)
+	if err != nil {
+		return nil, err
+	}
+	for id, start := range starts {
+		if history := walkEnvelope(start, last, totals[id]); len(history) > 0 {
+			carried[id] = history[len(history)-1].Balance
+		}
+	}
+	return carried, nil
+}
+
+// envelopeMonthTotal is what was planned for and spent in one category in
+// one month.
+type envelopeMonthTotal struct {
+	CategoryID uint
+	Month      string
+	Planned    money.Amount
+	Spent      money.Amount
+}
+
+// envelopeTotals returns the planned and spent totals of categories for the
+// months start through last, by category and then by month, in one query.
+func (r *BudgetRepository) envelopeTotals(userID uint, workspaceID uint, categoryIDs []uint, start, last time.Time) (map[uint]map[string]envelopeMonthTotal, error) {
+	planned := r.db.
+		Model(&model.R_budget{}).
+		Select("r_budgets.category_id, r_budgets.month, r_budgets.amount AS planned, 0 AS spent").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("r_budgets.category_id IN ? AND r_budgets.month >= ? AND r_budgets.month <= ?", categoryIDs, start.Format("2006-01"), last.Format("2006-01"))
+	spent := r.db.
+		Model(&model.T_expense{}).
+		Select("t_expense_splits.category_id, TO_CHAR(t_expenses.date, 'YYYY-MM') AS month, 0 AS planned, t_expense_splits.amount AS spent").
+		Joins("JOIN t_expense_splits ON t_expense_splits.expense_id = t_expenses.id").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("t_expense_splits.category_id IN ? AND t_expenses.date >= ? AND t_expenses.date < ?", categoryIDs, start, last.AddDate(0, 1, 0))
 
+	var rows []envelopeMonthTotal
+	if err := r.db.Raw(`SELECT category_id, month, COALESCE(SUM(planned), 0) AS planned, COALESCE(SUM(spent), 0) AS spent
+		FROM (? UNION ALL ?) AS movements
+		GROUP BY category_id, month`, planned, spent).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	totals := make(map[uint]map[string]envelopeMonthTotal)
+	for _, row := range rows {
+		if totals[row.CategoryID] == nil {
+			totals[row.CategoryID] = make(map[string]envelopeMonthTotal)
+		}
+		totals[row.CategoryID][row.Month] = row
+	}
+	return totals, nil
+}
+
+// walkEnvelope carries an envelope's balance month by month from start
+// through last.
+func walkEnvelope(start, last time.Time, totals map[string]envelopeMonthTotal) []EnvelopeMonth {
+	var history []EnvelopeMonth
+	var carry money.Amount
+	for m := start; !m.After(last); m = m.AddDate(0, 1, 0) {
+		key := m.Format("2006-01")
+		e := EnvelopeMonth{
+			Month:     key,
+			Planned:   totals[key].Planned,
+			CarriedIn: carry,
+			Spent:     totals[key].Spent,
+		}
+		e.Available = e.Planned + e.CarriedIn
+		e.Balance = e.Available - e.Spent
+		carry = e.Balance
+		history = append(history, e)
+	}
+	return history
+}
+
+// Upsert sets the planned amount of a category for a month, replacing any
+// existing budget rows so a month never holds the same category twice. It
+// returns the budget as it was before, or nil when it was created.
+func (r *BudgetRepository) Upsert(budget *model.R_budget) (*model.R_budget, error) {
+	var previous *model.R_budget
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.R_budget
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(WorkspaceScope(budget.UserID, budget.WorkspaceID)).
+			Where("category_id = ? AND month = ?", budget.CategoryID, budget.Month).
+			Order("id ASC").
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return tx.Create(budget).Error
+		}
+		for _, dup := range existing[1:] {
+			if err := tx.Delete(&dup).Error; err != nil {
+				return err
+			}
+		}
+		old := existing[0]
+		previous = &old
+		existing[0].Amount = budget.Amount
+		if err := tx.Save(&existing[0]).Error; err != nil {
+			return err
+		}
+		*budget = existing[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestWalkEnvelope(t *testing.T) {
+	month := func(s string) time.Time {
+		m, err := time.Parse("2006-01", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	tests := []struct {
+		name        string
+		start, last string
+		totals      map[string]envelopeMonthTotal
+		want        []EnvelopeMonth
+	}{
+		{
+			name: "surplus carried forward", start: "2024-01", last: "2024-02",
+			totals: map[string]envelopeMonthTotal{
+				"2024-01": {Planned: 100 * money.One, Spent: 30 * money.One},
+				"2024-02": {Planned: 100 * money.One, Spent: 200 * money.One},
+			},
+			want: []EnvelopeMonth{
+				{Month: "2024-01", Planned: 100 * money.One, Available: 100 * money.One, Spent: 30 * money.One, Balance: 70 * money.One},
+				{Month: "2024-02", Planned: 100 * money.One, CarriedIn: 70 * money.One, Available: 170 * money.One, Spent: 200 * money.One, Balance: -30 * money.One},
+			},
+		},
+		{
+			name: "overspend carried forward", start: "2023-12", last: "2024-02",
+			totals: map[string]envelopeMonthTotal{
+				"2023-12": {Planned: 100 * money.One, Spent: 150 * money.One},
+				"2024-02": {Planned: 50 * money.One, Spent: 20 * money.One},
+			},
+			want: []EnvelopeMonth{
+				{Month: "2023-12", Planned: 100 * money.One, Available: 100 * money.One, Spent: 150 * money.One, Balance: -50 * money.One},
+				{Month: "2024-01", CarriedIn: -50 * money.One, Available: -50 * money.One, Balance: -50 * money.One},
+				{Month: "2024-02", Planned: 50 * money.One, CarriedIn: -50 * money.One, Spent: 20 * money.One, Balance: -20 * money.One},
+			},
+		},
+		{
+			name: "no months", start: "2024-03", last: "2024-02",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := walkEnvelope(month(tt.start), month(tt.last), tt.totals)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarriedInto(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	january, april := "2024-01", "2024-04"
+	food := model.M_category{Name: "Food", Type: "expense", UserID: user.ID, RolloverSince: &january}
+	fuel := model.M_category{Name: "Fuel", Type: "expense", UserID: user.ID, RolloverSince: &april}
+	rent := model.M_category{Name: "Rent", Type: "expense", UserID: user.ID}
+	for _, cat := range []*model.M_category{&food, &fuel, &rent} {
+		if err := db.Create(cat).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	budgets := NewBudgetRepository(db)
+	expenses := NewExpenseRepository(db)
+	spend := func(cat model.M_category, date time.Time, amount money.Amount) {
+		exp := model.T_expense{
+			UserID: user.ID, Date: date, Currency: "IDR", Amount: amount, OriginalAmount: amount,
+			Splits: []model.T_expense_split{{CategoryID: cat.ID, Amount: amount}},
+		}
+		if err := expenses.Create(&exp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cat := range []model.M_category{food, fuel, rent} {
+		for _, month := range []string{"2024-01", "2024-02"} {
+			if _, err := budgets.Upsert(&model.R_budget{UserID: user.ID, CategoryID: cat.ID, Month: month, Amount: 100 * money.One}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Food: January overspends by 50, February leaves 70, March has no budget
+	spend(food, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), 150*money.One)
+	spend(food, time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), 30*money.One)
+	spend(rent, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 10*money.One)
+	// Spending from April on belongs to the month itself, not what it carries in
+	spend(food, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 5*money.One)
+
+	categories := []model.M_category{food, fuel, rent}
+	carried, err := budgets.CarriedInto(user.ID, 0, categories, "2024-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[uint]money.Amount{food.ID: 20 * money.One}; !reflect.DeepEqual(carried, want) {
+		t.Errorf("carried into April = %v, want %v", carried, want)
+	}
+	carried, err = budgets.CarriedInto(user.ID, 0, categories, "2024-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[uint]money.Amount{food.ID: -50 * money.One}; !reflect.DeepEqual(carried, want) {
+		t.Errorf("carried into February = %v, want %v", carried, want)
+	}
+	if carried, err := budgets.CarriedInto(user.ID, 0, categories, "2024-01"); err != nil || len(carried) != 0 {
+		t.Errorf("carried into January = %v, %v; want nothing", carried, err)
+	}
+	if carried, err := budgets.CarriedInto(user.ID, 1, categories, "2024-04"); err != nil || carried[food.ID] != 0 {
+		t.Errorf("carried into another workspace = %v, %v; want nothing", carried, err)
+	}
+}
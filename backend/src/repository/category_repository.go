@@ -14,9 +14,14 @@ func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *CategoryRepository) WithTx(tx *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: tx}
+}
+
 func (r *CategoryRepository) GetAll(userID uint, workspaceID uint, typeFilter string) ([]model.M_category, error) {
 	var categories []model.M_category
-	query := r.db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID)
+	query := r.db.Scopes(WorkspaceScope(userID, workspaceID))
 
 	// Filter by type if provided
 	if typeFilter == "income" || typeFilter == "expense" {
@@ -27,27 +32,37 @@ func (r *CategoryRepository) GetAll(userID uint, workspaceID uint, typeFilter st
 	return categories, err
 }
 
-func (r *CategoryRepository) GetByID(userID uint, id uint) (*model.M_category, error) {
+// GetWithRollover returns the categories whose budgets roll over month to month.
+func (r *CategoryRepository) GetWithRollover(userID uint, workspaceID uint) ([]model.M_category, error) {
+	var categories []model.M_category
+	err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("rollover_since IS NOT NULL").
+		Order("sequence ASC, id ASC").
+		Find(&categories).Error
+	return categories, err
+}
+
+func (r *CategoryRepository) GetByID(userID uint, workspaceID uint, id uint) (*model.M_category, error) {
 	var category model.M_category
-	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&category).Error
+	err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).Where("id = ?", id).First(&category).Error
 	return &category, err
 }
 
-func (r *CategoryRepository) GetByIDs(userID uint, ids []uint) ([]model.M_category, error) {
+func (r *CategoryRepository) GetByIDs(userID uint, workspaceID uint, ids []uint) ([]model.M_category, error) {
 	var categories []model.M_category
-	err := r.db.Where("id IN ? AND user_id = ?", ids, userID).Find(&categories).Error
+	err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).Where("id IN ?", ids).Find(&categories).Error
 	return categories, err
 }
 
-func (r *CategoryRepository) GetBySlug(userID uint, slug string) (*model.M_category, error) {
+func (r *CategoryRepository) GetBySlug(userID uint, workspaceID uint, slug string) (*model.M_category, error) {
 	var category model.M_category
-	err := r.db.Where("slug = ? AND user_id = ?", slug, userID).First(&category).Error
+	err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).Where("slug = ?", slug).First(&category).Error
 	return &category, err
 }
 
-func (r *CategoryRepository) SlugExists(userID uint, slug string, excludeID uint) (bool, error) {
+func (r *CategoryRepository) SlugExists(userID uint, workspaceID uint, slug string, excludeID uint) (bool, error) {
 	var count int64
-	query := r.db.Model(&model.M_category{}).Where("slug = ? AND user_id = ?", slug, userID)
+	query := r.db.Model(&model.M_category{}).Scopes(WorkspaceScope(userID, workspaceID)).Where("slug = ?", slug)
 	if excludeID > 0 {
 		query = query.Where("id != ?", excludeID)
 	}
@@ -63,6 +78,6 @@ func (r *CategoryRepository) Update(category *model.M_category) error {
 	return r.db.Save(category).Error
 }
 
-func (r *CategoryRepository) Delete(userID uint, id uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.M_category{}).Error
+func (r *CategoryRepository) Delete(userID uint, workspaceID uint, id uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).Where("id = ?", id).Delete(&model.M_category{}).Error
 }
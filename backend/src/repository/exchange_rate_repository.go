@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoExchangeRate means no rate of a currency is dated on or before the
+	// day a transaction needs converting.
+	ErrNoExchangeRate = errors.New("no exchange rate")
+	// ErrBaseCurrencyInUse means a workspace holds transactions or rates that
+	// depend on its base currency, so the base currency cannot change.
+	ErrBaseCurrencyInUse = errors.New("base currency is in use")
+)
+
+// ExchangeRateRepository keeps the rates transactions in foreign currencies
+// are converted into their workspace's base currency with.
+type ExchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ExchangeRateRepository) WithTx(tx *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: tx}
+}
+
+// List returns the workspace's rates newest first, only those of currency
+// when it is not empty.
+func (r *ExchangeRateRepository) List(userID uint, workspaceID uint, currency string) ([]model.M_exchange_rate, error) {
+	var list []model.M_exchange_rate
+	query := r.db.Scopes(WorkspaceScope(userID, workspaceID))
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+	err := query.Order("date DESC, currency ASC").Find(&list).Error
+	return list, err
+}
+
+// Set stores rates into base, the workspace's base currency, replacing the
+// rate already recorded for the same currency and day. Each rate is updated
+// in place with the stored row. Transactions the new rates apply to are
+// converted again in the same transaction; money.ErrRange means one of them
+// no longer fits an amount.
+func (r *ExchangeRateRepository) Set(userID uint, workspaceID uint, base string, rates []model.M_exchange_rate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			rate := &rates[i]
+			var existing model.M_exchange_rate
+			err := tx.Scopes(WorkspaceScope(userID, workspaceID)).
+				Where("currency = ? AND date = ?", rate.Currency, rate.Date).
+				First(&existing).Error
+			switch {
+			case err == nil:
+				existing.Rate = rate.Rate
+				if err := tx.Save(&existing).Error; err != nil {
+					return err
+				}
+				*rate = existing
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				rate.UserID, rate.WorkspaceID = userID, workspaceID
+				if err := tx.Create(rate).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+		}
+		for _, rate := range rates {
+			if err := r.WithTx(tx).reconvert(userID, workspaceID, base, rate.Currency, rate.Date); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes a rate and converts the transactions it applied to again
+// with the rate before it. It returns gorm.ErrRecordNotFound when the
+// workspace has no such rate, and ErrNoExchangeRate when transactions depend
+// on it and no earlier rate can take its place.
+func (r *ExchangeRateRepository) Delete(id uint, userID uint, workspaceID uint, base string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rate model.M_exchange_rate
+		if err := tx.Scopes(WorkspaceScope(userID, workspaceID)).First(&rate, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return r.WithTx(tx).reconvert(userID, workspaceID, base, rate.Currency, rate.Date)
+	})
+}
+
+// reconvert converts the transactions in currency dated from the day a rate
+// changed up to the next rate into base again, trashed ones included, and
+// rescales the splits of expenses to match.
+func (r *ExchangeRateRepository) reconvert(userID uint, workspaceID uint, base, currency string, from time.Time) error {
+	var next *time.Time
+	if err := r.db.Model(&model.M_exchange_rate{}).Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("currency = ? AND date > ?", currency, from).
+		Select("MIN(date)").
+		Scan(&next).Error; err != nil {
+		return err
+	}
+	affected := func(db *gorm.DB) *gorm.DB {
+		db = db.Unscoped().Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("currency = ? AND date >= ?", currency, from)
+		if next != nil {
+			db = db.Where("date < ?", *next)
+		}
+		return db
+	}
+
+	var expenses []model.T_expense
+	if err := r.db.Scopes(affected).Preload("Splits").Find(&expenses).Error; err != nil {
+		return err
+	}
+	var incomes []model.T_income
+	if err := r.db.Scopes(affected).Find(&incomes).Error; err != nil {
+		return err
+	}
+	if len(expenses) == 0 && len(incomes) == 0 {
+		return nil
+	}
+
+	// Every day up to the next rate converts with the same one
+	rate, err := r.RateOn(userID, workspaceID, currency, from)
+	if err != nil {
+		return err
+	}
+	for _, e := range expenses {
+		amount, err := rate.Rate.Convert(e.OriginalAmount, base)
+		if err != nil {
+			return err
+		}
+		if amount == e.Amount {
+			continue
+		}
+		if err := RescaleSplits(e.Splits, e.Amount, amount, base); err != nil {
+			return err
+		}
+		for _, split := range e.Splits {
+			if err := r.db.Model(&split).Update("amount", split.Amount).Error; err != nil {
+				return err
+			}
+		}
+		if err := r.db.Unscoped().Model(&e).Update("amount", amount).Error; err != nil {
+			return err
+		}
+	}
+	for _, in := range incomes {
+		amount, err := rate.Rate.Convert(in.OriginalAmount, base)
+		if err != nil {
+			return err
+		}
+		if amount != in.Amount {
+			if err := r.db.Unscoped().Model(&in).Update("amount", amount).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RateOn returns the newest rate of currency dated on or before date, or
+// ErrNoExchangeRate.
+func (r *ExchangeRateRepository) RateOn(userID uint, workspaceID uint, currency string, date time.Time) (*model.M_exchange_rate, error) {
+	var rate model.M_exchange_rate
+	err := r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("currency = ? AND date <= ?", currency, date).
+		Order("date DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoExchangeRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Convert returns what amount, in currency, was worth in base on date. An
+// amount already in base is returned as it is. A result that does not fit an
+// amount is money.ErrRange.
+func (r *ExchangeRateRepository) Convert(userID uint, workspaceID uint, amount money.Amount, currency, base string, date time.Time) (money.Amount, error) {
+	if currency == base {
+		return amount, nil
+	}
+	rate, err := r.RateOn(userID, workspaceID, currency, date)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate.Convert(amount, base)
+}
+
+// ChangeBaseCurrency relabels the transactions of a workspace whose base
+// currency changes from one currency to another without converting them, as
+// when a user corrects the currency they keep their books in. It fails with
+// ErrBaseCurrencyInUse when the workspace has exchange rates or transactions
+// in other currencies, whose converted amounts would no longer be right.
+func (r *ExchangeRateRepository) ChangeBaseCurrency(userID uint, workspaceID uint, from, to string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rates int64
+		if err := tx.Model(&model.M_exchange_rate{}).Scopes(WorkspaceScope(userID, workspaceID)).Count(&rates).Error; err != nil {
+			return err
+		}
+		if rates > 0 {
+			return ErrBaseCurrencyInUse
+		}
+		for _, m := range []interface{}{&model.T_expense{}, &model.T_income{}} {
+			var foreign int64
+			if err := tx.Unscoped().Model(m).Scopes(WorkspaceScope(userID, workspaceID)).
+				Where("currency <> ?", from).
+				Count(&foreign).Error; err != nil {
+				return err
+			}
+			if foreign > 0 {
+				return ErrBaseCurrencyInUse
+			}
+		}
+		for _, m := range []interface{}{&model.T_expense{}, &model.T_income{}} {
+			if err := tx.Unscoped().Model(m).Scopes(WorkspaceScope(userID, workspaceID)).
+				Update("currency", to).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestRateChangesReconvert(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	food := model.M_category{Name: "Food", Type: "expense", UserID: user.ID}
+	fun := model.M_category{Name: "Fun", Type: "expense", UserID: user.ID}
+	for _, c := range []*model.M_category{&food, &fun} {
+		if err := db.Create(c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	idr := func(units int64) money.Amount { return money.Amount(units) * money.One }
+	rate := func(s string) money.Rate { r, _ := money.ParseRate(s); return r }
+
+	repo := NewExchangeRateRepository(db)
+	if err := repo.Set(user.ID, 0, "IDR", []model.M_exchange_rate{
+		{Currency: "USD", Date: day(1, 1), Rate: rate("15000")},
+		{Currency: "USD", Date: day(2, 1), Rate: rate("16000")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expense := model.T_expense{UserID: user.ID, Date: day(1, 15), Currency: "USD", OriginalAmount: idr(10), Amount: idr(150000),
+		Splits: []model.T_expense_split{{CategoryID: food.ID, Amount: idr(90000)}, {CategoryID: fun.ID, Amount: idr(60000)}}}
+	trashed := model.T_expense{UserID: user.ID, Date: day(1, 20), Currency: "USD", OriginalAmount: idr(1), Amount: idr(15000),
+		Splits: []model.T_expense_split{{CategoryID: food.ID, Amount: idr(15000)}}}
+	income := model.T_income{UserID: user.ID, Date: day(2, 10), Currency: "USD", OriginalAmount: idr(5), Amount: idr(80000)}
+	for _, v := range []interface{}{&expense, &trashed, &income} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete(&trashed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(when string, wantExpense, wantFood, wantFun, wantTrashed, wantIncome money.Amount) {
+		t.Helper()
+		var e, tr model.T_expense
+		var in model.T_income
+		db.Preload("Splits").First(&e, expense.ID)
+		db.Unscoped().First(&tr, trashed.ID)
+		db.First(&in, income.ID)
+		splits := map[uint]money.Amount{}
+		for _, s := range e.Splits {
+			splits[s.CategoryID] = s.Amount
+		}
+		if e.Amount != wantExpense || splits[food.ID] != wantFood || splits[fun.ID] != wantFun {
+			t.Errorf("%s: expense %s split %s/%s, want %s split %s/%s", when, e.Amount, splits[food.ID], splits[fun.ID], wantExpense, wantFood, wantFun)
+		}
+		if tr.Amount != wantTrashed {
+			t.Errorf("%s: trashed expense %s, want %s", when, tr.Amount, wantTrashed)
+		}
+		if in.Amount != wantIncome {
+			t.Errorf("%s: income %s, want %s", when, in.Amount, wantIncome)
+		}
+	}
+
+	// Replacing the January rate converts January's transactions only
+	if err := repo.Set(user.ID, 0, "IDR", []model.M_exchange_rate{{Currency: "USD", Date: day(1, 1), Rate: rate("15500")}}); err != nil {
+		t.Fatal(err)
+	}
+	check("after setting", idr(155000), idr(93000), idr(62000), idr(15500), idr(80000))
+	var count int64
+	db.Model(&model.M_exchange_rate{}).Where("user_id = ? AND currency = ?", user.ID, "USD").Count(&count)
+	if count != 2 {
+		t.Errorf("%d USD rates after replacing one, want 2", count)
+	}
+
+	// Without the February rate February converts with January's
+	var february model.M_exchange_rate
+	db.Where("user_id = ? AND date = ?", user.ID, day(2, 1)).First(&february)
+	if err := repo.Delete(february.ID, user.ID, 0, "IDR"); err != nil {
+		t.Fatal(err)
+	}
+	check("after deleting February", idr(155000), idr(93000), idr(62000), idr(15500), idr(77500))
+
+	// The only rate left cannot go while transactions need it
+	var january model.M_exchange_rate
+	db.Where("user_id = ? AND date = ?", user.ID, day(1, 1)).First(&january)
+	if err := repo.Delete(january.ID, user.ID, 0, "IDR"); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("deleting the last rate: %v, want ErrNoExchangeRate", err)
+	}
+	if err := db.First(&model.M_exchange_rate{}, january.ID).Error; err != nil {
+		t.Errorf("refused deletion removed the rate: %v", err)
+	}
+	if err := repo.Delete(january.ID, user.ID+1, 0, "IDR"); err == nil {
+		t.Error("deleted another user's rate")
+	}
+}
+
+func TestOneRatePerWorkspaceDay(t *testing.T) {
+	db := testdb.Open(t)
+	owner := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	member := model.M_user{Name: "Member", Email: "member@example.com", Password: "x", Currency: "IDR"}
+	for _, u := range []*model.M_user{&owner, &member} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared := model.M_workspace{UserID: owner.ID, Name: "Home", Currency: "IDR"}
+	if err := db.Create(&shared).Error; err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rate := func(s string) money.Rate { r, _ := money.ParseRate(s); return r }
+
+	// Personal workspaces share the number 0 but not their rates
+	repo := NewExchangeRateRepository(db)
+	for _, u := range []model.M_user{owner, member} {
+		if err := repo.Set(u.ID, 0, "IDR", []model.M_exchange_rate{{Currency: "USD", Date: day, Rate: rate("15000")}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// In a shared workspace a member's rate replaces the owner's
+	for _, u := range []model.M_user{owner, member} {
+		if err := repo.Set(u.ID, shared.ID, "IDR", []model.M_exchange_rate{{Currency: "USD", Date: day, Rate: rate("16000")}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count int64
+	db.Model(&model.M_exchange_rate{}).Where("workspace_id = ?", shared.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("%d rates for one day of the shared workspace, want 1", count)
+	}
+
+	// Writers that miss each other's rate cannot store a second one
+	for _, dup := range []model.M_exchange_rate{
+		{UserID: member.ID, WorkspaceID: shared.ID, Currency: "USD", Date: day, Rate: rate("17000")},
+		{UserID: owner.ID, Currency: "USD", Date: day, Rate: rate("17000")},
+	} {
+		if err := db.Create(&dup).Error; err == nil {
+			t.Errorf("stored a second USD rate on %s in workspace %d", day.Format("2006-01-02"), dup.WorkspaceID)
+		}
+	}
+}
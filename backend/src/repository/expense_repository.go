@@ -4,8 +4,10 @@ import (
 	"time"
 
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ExpenseRepository struct {
@@ -16,28 +18,54 @@ func NewExpenseRepository(db *gorm.DB) *ExpenseRepository {
 	return &ExpenseRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ExpenseRepository) WithTx(tx *gorm.DB) *ExpenseRepository {
+	return &ExpenseRepository{db: tx}
+}
+
 func (r *ExpenseRepository) Create(expense *model.T_expense) error {
 	return r.db.Create(expense).Error
 }
 
+// Update saves the expense and replaces its category splits with expense.Splits.
 func (r *ExpenseRepository) Update(expense *model.T_expense) error {
-	return r.db.Save(expense).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(expense).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expense_id = ?", expense.ID).Delete(&model.T_expense_split{}).Error; err != nil {
+			return err
+		}
+		if len(expense.Splits) == 0 {
+			return nil
+		}
+		for i := range expense.Splits {
+			expense.Splits[i].ID = 0
+			expense.Splits[i].ExpenseID = expense.ID
+		}
+		return tx.Omit("Category").Create(&expense.Splits).Error
+	})
 }
 
-func (r *ExpenseRepository) Delete(id uint, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.T_expense{}).Error
+func (r *ExpenseRepository) Delete(id uint, userID uint, workspaceID uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		Delete(&model.T_expense{}).Error
 }
 
-func (r *ExpenseRepository) GetByID(id uint, userID uint) (*model.T_expense, error) {
+func (r *ExpenseRepository) GetByID(id uint, userID uint, workspaceID uint) (*model.T_expense, error) {
 	var e model.T_expense
-	if err := r.db.Preload("Categories").Where("id = ? AND user_id = ?", id, userID).First(&e).Error; err != nil {
+	if err := r.db.Preload("Splits.Category").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		First(&e).Error; err != nil {
 		return nil, err
 	}
 	return &e, nil
 }
 
 // GetMonths returns distinct YYYY-MM strings where the user has expenses.
-func (r *ExpenseRepository) GetMonths(userID uint) ([]string, error) {
+func (r *ExpenseRepository) GetMonths(userID uint, workspaceID uint) ([]string, error) {
 	type Row struct {
 		Month string
 	}
@@ -45,7 +73,7 @@ func (r *ExpenseRepository) GetMonths(userID uint) ([]string, error) {
 	if err := r.db.
 		Model(&model.T_expense{}).
 		Select("to_char(date, 'YYYY-MM') as month").
-		Where("user_id = ?", userID).
+		Scopes(WorkspaceScope(userID, workspaceID)).
 		Group("month").
 		Order("month DESC").
 		Scan(&rows).Error; err != nil {
@@ -62,8 +90,9 @@ func (r *ExpenseRepository) GetMonths(userID uint) ([]string, error) {
 func (r *ExpenseRepository) GetByMonth(userID uint, workspaceID uint, month string) ([]model.T_expense, error) {
 	var expenses []model.T_expense
 	if err := r.db.
-		Preload("Categories").
-		Where("user_id = ? AND workspace_id = ? AND to_char(date, 'YYYY-MM') = ?", userID, workspaceID, month).
+		Preload("Splits.Category").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("to_char(date, 'YYYY-MM') = ?", month).
 		Order("date DESC, id DESC").
 		Find(&expenses).Error; err != nil {
 		return nil, err
@@ -75,8 +104,9 @@ func (r *ExpenseRepository) GetByMonth(userID uint, workspaceID uint, month stri
 func (r *ExpenseRepository) GetByDate(userID uint, workspaceID uint, date time.Time) ([]model.T_expense, error) {
 	var expenses []model.T_expense
 	if err := r.db.
-		Preload("Categories").
-		Where("user_id = ? AND workspace_id = ? AND date = ?", userID, workspaceID, date).
+		Preload("Splits.Category").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("date = ?", date).
 		Order("id DESC").
 		Find(&expenses).Error; err != nil {
 		return nil, err
@@ -85,10 +115,10 @@ func (r *ExpenseRepository) GetByDate(userID uint, workspaceID uint, date time.T
 }
 
 // Search by notes substring with optional filters.
-func (r *ExpenseRepository) Search(userID uint, query string, categoryID *uint, dateFrom, dateTo *time.Time) ([]model.T_expense, error) {
+func (r *ExpenseRepository) Search(userID uint, workspaceID uint, query string, categoryID *uint, dateFrom, dateTo *time.Time) ([]model.T_expense, error) {
 	var expenses []model.T_expense
 
-	db := r.db.Preload("Categories").Where("user_id = ?", userID)
+	db := r.db.Preload("Splits.Category").Scopes(WorkspaceScope(userID, workspaceID))
 	if query != "" {
 		db = db.Where("notes ILIKE ?", "%"+query+"%")
 	}
@@ -99,9 +129,8 @@ func (r *ExpenseRepository) Search(userID uint, query string, categoryID *uint,
 		db = db.Where("date <= ?", *dateTo)
 	}
 	if categoryID != nil {
-		// Join through many2many table to filter by category
-		db = db.Joins("JOIN t_expense_categories ON t_expense_categories.t_expense_id = t_expenses.id").
-			Where("t_expense_categories.m_category_id = ?", *categoryID)
+		// Only expenses with a split in this category
+		db = db.Where("EXISTS (SELECT 1 FROM t_expense_splits WHERE t_expense_splits.expense_id = t_expenses.id AND t_expense_splits.category_id = ?)", *categoryID)
 	}
 
 	if err := db.
@@ -112,9 +141,76 @@ func (r *ExpenseRepository) Search(userID uint, query string, categoryID *uint,
 	return expenses, nil
 }
 
-// ReplaceCategories replaces the categories association for an expense.
-func (r *ExpenseRepository) ReplaceCategories(expense *model.T_expense, categories []model.M_category) error {
-	return r.db.Model(expense).Association("Categories").Replace(categories)
+// CategoryTotal is the amount spent in one category.
+type CategoryTotal struct {
+	CategoryID uint         `json:"categoryId"`
+	Category   string       `json:"category"`
+	Total      money.Amount `json:"total"`
 }
 
+// SumByCategory totals the split amounts per category for expenses dated in
+// [from, to). An expense spread over several categories counts each category
+// only for its own share.
+func (r *ExpenseRepository) SumByCategory(userID uint, workspaceID uint, from, to time.Time) ([]CategoryTotal, error) {
+	var rows []CategoryTotal
+	if err := r.db.
+		Model(&model.T_expense{}).
+		Select("t_expense_splits.category_id, m_categories.name AS category, COALESCE(SUM(t_expense_splits.amount), 0) AS total").
+		Joins("JOIN t_expense_splits ON t_expense_splits.expense_id = t_expenses.id").
+		Joins("JOIN m_categories ON m_categories.id = t_expense_splits.category_id AND m_categories.deleted_at IS NULL").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("t_expenses.date >= ? AND t_expenses.date < ?", from, to).
+		Group("t_expense_splits.category_id, m_categories.name").
+		Order("total DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
 
+// SplitEvenly divides amount over the categories in the smallest unit of
+// currency. Shares differ by at most one unit, the first categories taking
+// the larger ones.
+func SplitEvenly(amount money.Amount, currency string, categoryIDs []uint) []model.T_expense_split {
+	shares := amount.Split(len(categoryIDs), currency)
+	splits := make([]model.T_expense_split, len(shares))
+	for i, share := range shares {
+		splits[i] = model.T_expense_split{CategoryID: categoryIDs[i], Amount: share}
+	}
+	return splits
+}
+
+// SplitsMatchAmount reports whether the splits add up to exactly amount.
+func SplitsMatchAmount(splits []model.T_expense_split, amount money.Amount) bool {
+	var total money.Amount
+	for _, s := range splits {
+		total += s.Amount
+	}
+	return total == amount
+}
+
+// RescaleSplits scales splits that add up to from so that they add up to to,
+// keeping their proportions, as when an expense's amount is converted into
+// another currency. Shares are rounded to currency, the currency of to, and
+// the rounding difference goes to the first split. A share that does not fit
+// an amount is money.ErrRange.
+func RescaleSplits(splits []model.T_expense_split, from, to money.Amount, currency string) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	var total money.Amount
+	for i := range splits {
+		if from != 0 {
+			scaled, err := splits[i].Amount.MulDiv(int64(to), int64(from))
+			if err != nil {
+				return err
+			}
+			splits[i].Amount = scaled.Round(currency)
+		} else {
+			splits[i].Amount = 0
+		}
+		total += splits[i].Amount
+	}
+	splits[0].Amount += to - total
+	return nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// ExportRepository reads whole histories for export. Rows are handed to a
+// callback one at a time straight from the database cursor, so an export never
+// holds more than a single row in memory.
+type ExportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+// ExpenseExportRow is one expense with its splits flattened into aligned,
+// "; "-separated lists.
+type ExpenseExportRow struct {
+	ID             uint
+	Date           time.Time
+	Amount         money.Amount
+	Currency       string
+	OriginalAmount money.Amount
+	Notes          string
+	Account        string
+	Categories     string
+	CategorySlugs  string
+	SplitAmounts   string
+}
+
+type IncomeExportRow struct {
+	ID             uint
+	Date           time.Time
+	Amount         money.Amount
+	Currency       string
+	OriginalAmount money.Amount
+	Notes          string
+	Account        string
+	Categories     string
+	CategorySlugs  string
+}
+
+type BudgetExportRow struct {
+	Month        string
+	CategoryID   uint
+	Category     string
+	CategorySlug string
+	Amount       money.Amount
+}
+
+type CategoryExportRow struct {
+	ID       uint
+	Name     string
+	Slug     string
+	Type     string
+	IsActive bool
+	Sequence int
+}
+
+// EachExpense calls fn for every expense dated within [from, to] (either bound
+// may be nil), oldest first. Categories deleted since keep their names.
+func (r *ExportRepository) EachExpense(userID uint, workspaceID uint, from, to *time.Time, fn func(*ExpenseExportRow) error) error {
+	query := r.db.
+		Model(&model.T_expense{}).
+		Select(`t_expenses.id, t_expenses.date, t_expenses.amount, t_expenses.currency, t_expenses.original_amount, t_expenses.notes, COALESCE(m_accounts.name, '') AS account,
+			COALESCE(string_agg(m_categories.name, '; ' ORDER BY t_expense_splits.id), '') AS categories,
+			COALESCE(string_agg(COALESCE(m_categories.slug, ''), '; ' ORDER BY t_expense_splits.id), '') AS category_slugs,
+			COALESCE(string_agg(trim_scale(t_expense_splits.amount)::text, '; ' ORDER BY t_expense_splits.id), '') AS split_amounts`).
+		Joins("LEFT JOIN t_expense_splits ON t_expense_splits.expense_id = t_expenses.id").
+		Joins("LEFT JOIN m_categories ON m_categories.id = t_expense_splits.category_id").
+		Joins("LEFT JOIN m_accounts ON m_accounts.id = t_expenses.account_id").
+		Scopes(WorkspaceScope(userID, workspaceID), dateRange("t_expenses.date", from, to)).
+		Group("t_expenses.id, m_accounts.name").
+		Order("t_expenses.date ASC, t_expenses.id ASC")
+
+	var row ExpenseExportRow
+	return r.each(query, &row, func() error { return fn(&row) })
+}
+
+// EachIncome calls fn for every income dated within [from, to], oldest first.
+func (r *ExportRepository) EachIncome(userID uint, workspaceID uint, from, to *time.Time, fn func(*IncomeExportRow) error) error {
+	query := r.db.
+		Model(&model.T_income{}).
+		Select(`t_incomes.id, t_incomes.date, t_incomes.amount, t_incomes.currency, t_incomes.original_amount, t_incomes.notes, COALESCE(m_accounts.name, '') AS account,
+			COALESCE(string_agg(m_categories.name, '; ' ORDER BY m_categories.id), '') AS categories,
+			COALESCE(string_agg(COALESCE(m_categories.slug, ''), '; ' ORDER BY m_categories.id), '') AS category_slugs`).
+		Joins("LEFT JOIN t_income_categories ON t_income_categories.t_income_id = t_incomes.id").
+		Joins("LEFT JOIN m_categories ON m_categories.id = t_income_categories.m_category_id").
+		Joins("LEFT JOIN m_accounts ON m_accounts.id = t_incomes.account_id").
+		Scopes(WorkspaceScope(userID, workspaceID), dateRange("t_incomes.date", from, to)).
+		Group("t_incomes.id, m_accounts.name").
+		Order("t_incomes.date ASC, t_incomes.id ASC")
+
+	var row IncomeExportRow
+	return r.each(query, &row, func() error { return fn(&row) })
+}
+
+// EachBudget calls fn for every budget whose month overlaps [from, to].
+func (r *ExportRepository) EachBudget(userID uint, workspaceID uint, from, to *time.Time, fn func(*BudgetExportRow) error) error {
+	query := r.db.
+		Model(&model.R_budget{}).
+		Select("r_budgets.month, r_budgets.category_id, m_categories.name AS category, COALESCE(m_categories.slug, '') AS category_slug, r_budgets.amount").
+		Joins("LEFT JOIN m_categories ON m_categories.id = r_budgets.category_id").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Order("r_budgets.month ASC, r_budgets.category_id ASC")
+	if from != nil {
+		query = query.Where("r_budgets.month >= ?", from.Format("2006-01"))
+	}
+	if to != nil {
+		query = query.Where("r_budgets.month <= ?", to.Format("2006-01"))
+	}
+
+	var row BudgetExportRow
+	return r.each(query, &row, func() error { return fn(&row) })
+}
+
+// EachCategory calls fn for every category of the workspace, inactive ones included.
+func (r *ExportRepository) EachCategory(userID uint, workspaceID uint, fn func(*CategoryExportRow) error) error {
+	query := r.db.
+		Model(&model.M_category{}).
+		Select("id, name, COALESCE(slug, '') AS slug, type, is_active, sequence").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Order("type ASC, sequence ASC, id ASC")
+
+	var row CategoryExportRow
+	return r.each(query, &row, func() error { return fn(&row) })
+}
+
+// each scans query row by row into dest and calls fn after every row.
+func (r *ExportRepository) each(query *gorm.DB, dest interface{}, fn func() error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := r.db.ScanRows(rows, dest); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// dateRange limits column to [from, to]; nil bounds are open.
+func dateRange(column string, from, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if from != nil {
+			db = db.Where(column+" >= ?", *from)
+		}
+		if to != nil {
+			db = db.Where(column+" <= ?", *to)
+		}
+		return db
+	}
+}
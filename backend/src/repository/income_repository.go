@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -18,9 +19,17 @@ func NewIncomeRepository(db *gorm.DB) *IncomeRepository {
 	return &IncomeRepository{db: db}
 }
 
-func (r *IncomeRepository) GetByID(id, userID uint) (*model.T_income, error) {
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *IncomeRepository) WithTx(tx *gorm.DB) *IncomeRepository {
+	return &IncomeRepository{db: tx}
+}
+
+func (r *IncomeRepository) GetByID(id, userID, workspaceID uint) (*model.T_income, error) {
 	var in model.T_income
-	if err := r.db.Preload("Categories").Where("id = ? AND user_id = ?", id, userID).First(&in).Error; err != nil {
+	if err := r.db.Preload("Categories").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		First(&in).Error; err != nil {
 		return nil, err
 	}
 	return &in, nil
@@ -34,14 +43,17 @@ func (r *IncomeRepository) Update(income *model.T_income) error {
 	return r.db.Save(income).Error
 }
 
-func (r *IncomeRepository) Delete(id uint, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.T_income{}).Error
+func (r *IncomeRepository) Delete(id uint, userID uint, workspaceID uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		Delete(&model.T_income{}).Error
 }
 
-func (r *IncomeRepository) GetByDate(userID uint, date time.Time) ([]model.T_income, error) {
+func (r *IncomeRepository) GetByDate(userID uint, workspaceID uint, date time.Time) ([]model.T_income, error) {
 	var items []model.T_income
 	if err := r.db.Preload("Categories").
-		Where("user_id = ? AND date = ?", userID, date).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("date = ?", date).
 		Order("id DESC").
 		Find(&items).Error; err != nil {
 		return nil, err
@@ -53,17 +65,27 @@ func (r *IncomeRepository) ReplaceCategories(income *model.T_income, categories
 	return r.db.Model(income).Association("Categories").Replace(categories)
 }
 
-func (r *IncomeRepository) GetBalance(userID uint) (*model.R_balance, error) {
+func (r *IncomeRepository) GetBalance(userID uint, workspaceID uint) (*model.R_balance, error) {
 	var b model.R_balance
 
-	// Always derive the latest balance from income and expenses, and upsert it atomically.
+	// Always derive the latest balance from account opening balances, income and
+	// expenses, and upsert it atomically. Transfers between accounts cancel out.
 	if err := r.db.Transaction(func(tx *gorm.DB) error {
-		var incomeTotal float64
-		var expenseTotal float64
+		var openingTotal money.Amount
+		var incomeTotal money.Amount
+		var expenseTotal money.Amount
+
+		if err := tx.
+			Model(&model.M_account{}).
+			Scopes(WorkspaceScope(userID, workspaceID)).
+			Select("COALESCE(SUM(opening_balance), 0)").
+			Scan(&openingTotal).Error; err != nil {
+			return err
+		}
 
 		if err := tx.
 			Model(&model.T_income{}).
-			Where("user_id = ?", userID).
+			Scopes(WorkspaceScope(userID, workspaceID)).
 			Select("COALESCE(SUM(amount), 0)").
 			Scan(&incomeTotal).Error; err != nil {
 			return err
@@ -71,20 +93,21 @@ func (r *IncomeRepository) GetBalance(userID uint) (*model.R_balance, error) {
 
 		if err := tx.
 			Model(&model.T_expense{}).
-			Where("user_id = ?", userID).
+			Scopes(WorkspaceScope(userID, workspaceID)).
 			Select("COALESCE(SUM(amount), 0)").
 			Scan(&expenseTotal).Error; err != nil {
 			return err
 		}
 
 		b = model.R_balance{
-			UserID: userID,
-			Amount: incomeTotal - expenseTotal,
+			UserID:      userID,
+			WorkspaceID: workspaceID,
+			Amount:      openingTotal + incomeTotal - expenseTotal,
 		}
 
-		// Upsert by user_id (requires unique index on user_id).
+		// Upsert by (user_id, workspace_id) (requires unique index on both columns).
 		return tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "user_id"}},
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "workspace_id"}},
 			DoUpdates: clause.AssignmentColumns([]string{"amount", "updated_at"}),
 		}).Create(&b).Error
 	}); err != nil {
@@ -95,11 +118,11 @@ func (r *IncomeRepository) GetBalance(userID uint) (*model.R_balance, error) {
 }
 
 func (r *IncomeRepository) UpsertBalance(b *model.R_balance) error {
-	// upsert based on user_id with row-level locking to avoid races
+	// upsert based on (user_id, workspace_id) with row-level locking to avoid races
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var existing model.R_balance
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ?", b.UserID).
+			Scopes(WorkspaceScope(b.UserID, b.WorkspaceID)).
 			First(&existing).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return tx.Create(b).Error
@@ -111,3 +134,28 @@ func (r *IncomeRepository) UpsertBalance(b *model.R_balance) error {
 		return tx.Save(&existing).Error
 	})
 }
+
+// ListBalances returns the balances of a user, or of everyone when userID is
+// 0: one for every workspace the user can open, and for their personal
+// workspace once it holds anything. Balances that were never stored come
+// with ID 0 and a zero amount.
+func (r *IncomeRepository) ListBalances(userID uint) ([]model.R_balance, error) {
+	var balances []model.R_balance
+	err := r.db.Raw(`WITH owners AS (
+			SELECT user_id, workspace_id FROM r_balances
+			UNION SELECT user_id, 0 FROM m_accounts WHERE workspace_id = 0 AND deleted_at IS NULL
+			UNION SELECT user_id, 0 FROM t_incomes WHERE workspace_id = 0 AND deleted_at IS NULL
+			UNION SELECT user_id, 0 FROM t_expenses WHERE workspace_id = 0 AND deleted_at IS NULL
+			UNION SELECT user_id, id FROM m_workspaces WHERE deleted_at IS NULL
+			UNION SELECT user_id, workspace_id FROM m_workspace_members
+				WHERE status = @accepted AND user_id <> 0 AND deleted_at IS NULL
+		)
+		SELECT COALESCE(b.id, 0) AS id, o.user_id, o.workspace_id, COALESCE(b.amount, 0) AS amount
+		FROM owners o
+		LEFT JOIN r_balances b ON b.user_id = o.user_id AND b.workspace_id = o.workspace_id
+		WHERE o.user_id IS NOT NULL AND (@user = 0 OR o.user_id = @user)
+		ORDER BY o.user_id, o.workspace_id`,
+		map[string]interface{}{"user": userID, "accepted": model.MemberStatusAccepted}).
+		Scan(&balances).Error
+	return balances, err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestListBalancesIncludesMissing(t *testing.T) {
+	db := testdb.Open(t)
+	owner := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	member := model.M_user{Name: "Member", Email: "member@example.com", Password: "x", Currency: "IDR"}
+	idle := model.M_user{Name: "Idle", Email: "idle@example.com", Password: "x", Currency: "IDR"}
+	for _, u := range []*model.M_user{&owner, &member, &idle} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared := model.M_workspace{UserID: owner.ID, Name: "Home"}
+	if err := db.Create(&shared).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []interface{}{
+		&model.M_workspace_member{WorkspaceID: shared.ID, UserID: member.ID, Email: member.Email, Role: model.WorkspaceRoleViewer, Status: model.MemberStatusAccepted},
+		&model.M_workspace_member{WorkspaceID: shared.ID, Email: "invited@example.com", Role: model.WorkspaceRoleViewer, Status: model.MemberStatusPending},
+		&model.T_income{UserID: owner.ID, Date: time.Now(), Currency: "IDR", Amount: 5 * money.One, OriginalAmount: 5 * money.One},
+		&model.T_income{UserID: owner.ID, WorkspaceID: shared.ID, Date: time.Now(), Currency: "IDR", Amount: 7 * money.One, OriginalAmount: 7 * money.One},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewIncomeRepository(db)
+	if _, err := repo.GetBalance(owner.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	balances, err := repo.ListBalances(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type key struct{ user, workspace uint }
+	got := map[key]model.R_balance{}
+	for _, b := range balances {
+		got[key{b.UserID, b.WorkspaceID}] = b
+	}
+	want := map[key]bool{{owner.ID, 0}: true, {owner.ID, shared.ID}: false, {member.ID, shared.ID}: false}
+	if len(got) != len(want) {
+		t.Errorf("ListBalances = %+v, want %d balances", balances, len(want))
+	}
+	for k, stored := range want {
+		b, ok := got[k]
+		if !ok {
+			t.Errorf("no balance for user %d in workspace %d", k.user, k.workspace)
+		} else if (b.ID != 0) != stored {
+			t.Errorf("balance of user %d in workspace %d has ID %d, stored %v", k.user, k.workspace, b.ID, stored)
+		}
+	}
+	if b := got[key{owner.ID, 0}]; b.Amount != 5*money.One {
+		t.Errorf("stored personal balance %s, want 5", b.Amount)
+	}
+
+	if mine, err := repo.ListBalances(member.ID); err != nil || len(mine) != 1 || mine[0].WorkspaceID != shared.ID {
+		t.Errorf("ListBalances(member) = %+v, %v", mine, err)
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+type LoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// AccountThrottleKey is the key counting failed sign-ins to the account
+// with email.
+func AccountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Get returns the counters of key, or a zero row when it has none.
+func (r *LoginThrottleRepository) Get(key string) (*model.M_login_throttle, error) {
+	var t model.M_login_throttle
+	err := r.db.Where("key = ?", key).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.M_login_throttle{Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Reserve counts an attempt for key at now as a failure, unless key is still
+// waiting after its earlier failures. waits[i] is how long a key must wait
+// after its (i+1)-th failure, the last entry applying to any more; failures
+// older than window no longer count. The check and the count are one
+// statement, so parallel attempts cannot all pass the same check. It
+// returns the failures including this attempt, or false when refused.
+func (r *LoginThrottleRepository) Reserve(key string, now time.Time, window time.Duration, waits []time.Duration) (int, bool, error) {
+	seconds := make([]string, len(waits))
+	for i, w := range waits {
+		seconds[i] = strconv.FormatFloat(w.Seconds(), 'f', -1, 64)
+	}
+
+	var reserved []model.M_login_throttle
+	err := r.db.Raw(`INSERT INTO m_login_throttles (key, failures, last_failure_at, updated_at)
+		VALUES (@key, 1, @now, @now)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN m_login_throttles.last_failure_at < @stale THEN 1 ELSE m_login_throttles.failures + 1 END,
+			last_failure_at = @now,
+			updated_at = @now
+		WHERE m_login_throttles.failures = 0
+			OR m_login_throttles.last_failure_at < @stale
+			OR m_login_throttles.last_failure_at + make_interval(secs => (CAST(@waits AS float8[]))[LEAST(m_login_throttles.failures, @last)]) <= @now
+		RETURNING key, failures, last_failure_at, updated_at`,
+		map[string]interface{}{
+			"key":   key,
+			"now":   now,
+			"stale": now.Add(-window),
+			"waits": "{" + strings.Join(seconds, ",") + "}",
+			"last":  len(waits),
+		}).Scan(&reserved).Error
+	if err != nil || len(reserved) == 0 {
+		return 0, false, err
+	}
+	return reserved[0].Failures, true, nil
+}
+
+// Refund takes back one failure counted by Reserve, for an attempt that
+// turned out to be right.
+func (r *LoginThrottleRepository) Refund(key string) error {
+	return r.db.Model(&model.M_login_throttle{}).Where("key = ?", key).
+		Update("failures", gorm.Expr("GREATEST(failures - 1, 0)")).Error
+}
+
+// Reset forgets the failures of key.
+func (r *LoginThrottleRepository) Reset(key string) error {
+	return r.db.Where("key = ?", key).Delete(&model.M_login_throttle{}).Error
+}
+
+// PurgeStale deletes counters whose last failure is older than before.
+func (r *LoginThrottleRepository) PurgeStale(before time.Time) (int64, error) {
+	res := r.db.Where("last_failure_at < ?", before).Delete(&model.M_login_throttle{})
+	return res.RowsAffected, res.Error
+}
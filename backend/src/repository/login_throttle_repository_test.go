@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/testdb"
+)
+
+func TestLoginThrottleReserve(t *testing.T) {
+	repo := NewLoginThrottleRepository(testdb.Open(t))
+	waits := []time.Duration{0, time.Minute, time.Hour}
+	now := time.Now().Truncate(time.Second)
+
+	// Free attempts pass, and all of them count
+	for want := 1; want <= 2; want++ {
+		if failures, ok, err := repo.Reserve("account:a@example.com", now, 24*time.Hour, waits); err != nil || !ok || failures != want {
+			t.Fatalf("Reserve #%d = %d, %v, %v", want, failures, ok, err)
+		}
+	}
+
+	// Past them, parallel attempts get one slot between them
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := repo.Reserve("account:a@example.com", now.Add(2*time.Minute), 24*time.Hour, waits)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted != 1 {
+		t.Errorf("%d parallel attempts admitted, want 1", admitted)
+	}
+
+	// The last wait applies to every further failure
+	if _, ok, _ := repo.Reserve("account:a@example.com", now.Add(time.Hour), 24*time.Hour, waits); ok {
+		t.Error("attempt admitted during the lockout")
+	}
+	if failures, ok, err := repo.Reserve("account:a@example.com", now.Add(3*time.Hour), 24*time.Hour, waits); err != nil || !ok || failures != 4 {
+		t.Errorf("Reserve after the lockout = %d, %v, %v; want 4", failures, ok, err)
+	}
+
+	// Refunds take one failure back, and old failures are forgotten
+	if err := repo.Refund("account:a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Get("account:a@example.com"); got.Failures != 3 {
+		t.Errorf("failures after refund = %d, want 3", got.Failures)
+	}
+	if failures, ok, err := repo.Reserve("account:a@example.com", now.Add(48*time.Hour), 24*time.Hour, waits); err != nil || !ok || failures != 1 {
+		t.Errorf("Reserve after the window = %d, %v, %v; want 1", failures, ok, err)
+	}
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+// NotificationRepository stores inbox entries and delivery channels. Both are
+// personal, so queries match the user even inside shared workspaces.
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) Create(n *model.T_notification) error {
+	return r.db.Create(n).Error
+}
+
+func (r *NotificationRepository) List(userID uint, workspaceID uint, unreadOnly bool, limit int) ([]model.T_notification, error) {
+	var items []model.T_notification
+	db := r.db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID)
+	if unreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	if err := db.Order("created_at DESC, id DESC").Limit(limit).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) CountUnread(userID uint, workspaceID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.T_notification{}).
+		Where("user_id = ? AND workspace_id = ? AND read_at IS NULL", userID, workspaceID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks one notification, or all of them when id is 0, as read.
+func (r *NotificationRepository) MarkRead(userID uint, workspaceID uint, id uint) error {
+	db := r.db.Model(&model.T_notification{}).
+		Where("user_id = ? AND workspace_id = ? AND read_at IS NULL", userID, workspaceID)
+	if id != 0 {
+		db = db.Where("id = ?", id)
+	}
+	return db.Update("read_at", time.Now()).Error
+}
+
+func (r *NotificationRepository) GetChannels(userID uint, workspaceID uint) ([]model.M_notification_channel, error) {
+	var items []model.M_notification_channel
+	if err := r.db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID).
+		Order("id ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) GetChannel(userID uint, workspaceID uint, id uint) (*model.M_notification_channel, error) {
+	var ch model.M_notification_channel
+	if err := r.db.Where("id = ? AND user_id = ? AND workspace_id = ?", id, userID, workspaceID).First(&ch).Error; err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (r *NotificationRepository) CreateChannel(ch *model.M_notification_channel) error {
+	return r.db.Create(ch).Error
+}
+
+func (r *NotificationRepository) UpdateChannel(ch *model.M_notification_channel) error {
+	return r.db.Save(ch).Error
+}
+
+func (r *NotificationRepository) DeleteChannel(userID uint, workspaceID uint, id uint) error {
+	return r.db.Where("id = ? AND user_id = ? AND workspace_id = ?", id, userID, workspaceID).
+		Delete(&model.M_notification_channel{}).Error
+}
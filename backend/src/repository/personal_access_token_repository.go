@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a token's last use is written back.
+const lastUsedResolution = time.Minute
+
+type PersonalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *PersonalAccessTokenRepository) WithTx(tx *gorm.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: tx}
+}
+
+// GetByUser lists all of the user's tokens, across workspaces, newest first.
+func (r *PersonalAccessTokenRepository) GetByUser(userID uint) ([]model.M_personal_access_token, error) {
+	var items []model.M_personal_access_token
+	if err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Create generates the token for t, stores t and returns the token in
+// plaintext. It cannot be retrieved later.
+func (r *PersonalAccessTokenRepository) Create(t *model.M_personal_access_token) (string, error) {
+	secret, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := model.PersonalAccessTokenPrefix + secret
+	t.Prefix = token[:len(model.PersonalAccessTokenPrefix)+6]
+	t.TokenHash = hashToken(token)
+	if err := r.db.Create(t).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetActive returns the unexpired token matching the plaintext token.
+func (r *PersonalAccessTokenRepository) GetActive(token string) (*model.M_personal_access_token, error) {
+	var t model.M_personal_access_token
+	if err := r.db.
+		Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?)", hashToken(token), time.Now()).
+		First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Touch records a use of the token, at most once per lastUsedResolution.
+func (r *PersonalAccessTokenRepository) Touch(id uint, ip string, now time.Time) error {
+	return r.db.Model(&model.M_personal_access_token{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
+
+// Revoke deletes one of the user's tokens. It returns gorm.ErrRecordNotFound
+// when the user has no such token.
+func (r *PersonalAccessTokenRepository) Revoke(userID uint, id uint) error {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.M_personal_access_token{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAll deletes every token of the user and returns how many there were.
+func (r *PersonalAccessTokenRepository) RevokeAll(userID uint) (int64, error) {
+	res := r.db.Where("user_id = ?", userID).Delete(&model.M_personal_access_token{})
+	return res.RowsAffected, res.Error
+}
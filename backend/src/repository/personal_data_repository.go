@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+// personalDataBatchSize is how many records an export loads at a time.
+const personalDataBatchSize = 500
+
+// PersonalDataWriter receives a personal data export one dataset at a time.
+// Begin starts a dataset and every Write that follows gets a pointer to a
+// slice with the next batch of its records.
+type PersonalDataWriter interface {
+	Begin(name string) error
+	Write(rows interface{}) error
+}
+
+// personalDataset is one file of the export: the rows of a table whose
+// user_id is the user's. rows returns a pointer to an empty slice to load into.
+type personalDataset struct {
+	name     string
+	rows     func() interface{}
+	preloads []string
+}
+
+var personalDatasets = []personalDataset{
+	{name: "identities", rows: func() interface{} { return &[]model.M_user_identity{} }},
+	{name: "sessions", rows: func() interface{} { return &[]model.M_refresh_token{} }},
+	{name: "access_tokens", rows: func() interface{} { return &[]model.M_personal_access_token{} }},
+	{name: "workspaces", rows: func() interface{} { return &[]model.M_workspace{} }},
+	{name: "workspace_memberships", rows: func() interface{} { return &[]model.M_workspace_member{} }},
+	{name: "accounts", rows: func() interface{} { return &[]model.M_account{} }},
+	{name: "categories", rows: func() interface{} { return &[]model.M_category{} }},
+	{name: "expenses", rows: func() interface{} { return &[]model.T_expense{} }, preloads: []string{"Splits"}},
+	{name: "income", rows: func() interface{} { return &[]model.T_income{} }, preloads: []string{"Categories"}},
+	{name: "transfers", rows: func() interface{} { return &[]model.T_transfer{} }},
+	{name: "balances", rows: func() interface{} { return &[]model.R_balance{} }},
+	{name: "budgets", rows: func() interface{} { return &[]model.R_budget{} }},
+	{name: "budget_thresholds", rows: func() interface{} { return &[]model.M_budget_threshold{} }},
+	{name: "budget_alerts", rows: func() interface{} { return &[]model.T_budget_alert{} }},
+	{name: "expense_templates", rows: func() interface{} { return &[]model.M_expense_template{} }, preloads: []string{"Categories"}},
+	{name: "quick_amounts", rows: func() interface{} { return &[]model.M_quick_amount{} }},
+	{name: "exchange_rates", rows: func() interface{} { return &[]model.M_exchange_rate{} }},
+	{name: "recurring_rules", rows: func() interface{} { return &[]model.M_recurring_rule{} }, preloads: []string{"Categories", "Skips"}},
+	{name: "notifications", rows: func() interface{} { return &[]model.T_notification{} }},
+	{name: "notification_channels", rows: func() interface{} { return &[]model.M_notification_channel{} }},
+	{name: "audit_log", rows: func() interface{} { return &[]model.T_audit_log{} }},
+}
+
+// PersonalDataRepository exports and erases everything stored about a user.
+type PersonalDataRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalDataRepository(db *gorm.DB) *PersonalDataRepository {
+	return &PersonalDataRepository{db: db}
+}
+
+// Export hands w every record the user created or that describes them, in
+// every workspace, a batch at a time. The user's own profile is not included.
+func (r *PersonalDataRepository) Export(userID uint, w PersonalDataWriter) error {
+	for _, set := range personalDatasets {
+		if err := w.Begin(set.name); err != nil {
+			return err
+		}
+		query := r.db.Where("user_id = ?", userID)
+		for _, p := range set.preloads {
+			query = query.Preload(p)
+		}
+		rows := set.rows()
+		err := query.FindInBatches(rows, personalDataBatchSize, func(tx *gorm.DB, batch int) error {
+			return w.Write(rows)
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DueForPurge returns the users whose deletion grace period ended before now.
+func (r *PersonalDataRepository) DueForPurge(now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&model.M_user{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Purge permanently deletes a user together with the workspaces they own and
+// everything in them, their personal workspace, and their sign-in data.
+// Records they added to workspaces owned by others stay with those
+// workspaces. The audit log keeps every entry, of deleted workspaces too, but
+// entries lose the link to the user and the address they came from.
+func (r *PersonalDataRepository) Purge(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user model.M_user
+		if err := tx.Unscoped().First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		owned := tx.Unscoped().Model(&model.M_workspace{}).Select("id").Where("user_id = ?", userID)
+		doomed := func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Where("workspace_id IN (?) OR (workspace_id = 0 AND user_id = ?)", owned, userID)
+		}
+		ids := func(m interface{}) *gorm.DB {
+			return tx.Model(m).Scopes(doomed).Select("id")
+		}
+
+		// Rows hanging off the workspace data go first
+		children := []struct {
+			table  string
+			column string
+			parent interface{}
+		}{
+			{"t_expense_splits", "expense_id", &model.T_expense{}},
+			{"t_income_categories", "t_income_id", &model.T_income{}},
+			{"m_expense_template_categories", "m_expense_template_id", &model.M_expense_template{}},
+			{"m_recurring_rule_categories", "m_recurring_rule_id", &model.M_recurring_rule{}},
+			{"m_recurring_skips", "recurring_rule_id", &model.M_recurring_rule{}},
+		}
+		for _, c := range children {
+			if err := tx.Exec("DELETE FROM "+c.table+" WHERE "+c.column+" IN (?)", ids(c.parent)).Error; err != nil {
+				return err
+			}
+		}
+
+		// Then the workspace data itself, referencing rows before referenced ones
+		for _, m := range []interface{}{
+			&model.T_budget_alert{},
+			&model.M_budget_threshold{},
+			&model.R_budget{},
+			&model.T_transfer{},
+			&model.T_expense{},
+			&model.T_income{},
+			&model.M_expense_template{},
+			&model.M_recurring_rule{},
+			&model.R_balance{},
+			&model.M_quick_amount{},
+			&model.M_exchange_rate{},
+			&model.M_category{},
+			&model.M_account{},
+			&model.T_notification{},
+			&model.M_notification_channel{},
+			&model.M_personal_access_token{},
+		} {
+			if err := tx.Scopes(doomed).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+
+		// What is personal to the user in workspaces they do not own
+		for _, m := range []interface{}{
+			&model.T_notification{},
+			&model.M_notification_channel{},
+			&model.M_personal_access_token{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.T_audit_log{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"user_id": nil, "ip": ""}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.T_audit_log{}).Where("entity = ? AND entity_id = ?", model.AuditUser, userID).
+			Update("entity_id", nil).Error; err != nil {
+			return err
+		}
+
+		// Memberships and invitations, both of the user and to their workspaces
+		if err := tx.Unscoped().
+			Where("workspace_id IN (?) OR user_id = ? OR email = ?", owned, userID, user.Email).
+			Delete(&model.M_workspace_member{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.M_workspace{}).Error; err != nil {
+			return err
+		}
+
+		// Sign-in data, then the account
+		if err := tx.Where("key = ?", AccountThrottleKey(user.Email)).Delete(&model.M_login_throttle{}).Error; err != nil {
+			return err
+		}
+		for _, m := range []interface{}{
+			&model.M_refresh_token{},
+			&model.M_recovery_code{},
+			&model.M_user_token{},
+			&model.M_user_identity{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&user).Error
+	})
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestPurgeKeepsAnonymousAuditLog(t *testing.T) {
+	db := testdb.Open(t)
+	alice := model.M_user{Name: "Alice", Email: "alice@example.com", Password: "x", Currency: "IDR"}
+	bob := model.M_user{Name: "Bob", Email: "bob@example.com", Password: "x", Currency: "IDR"}
+	for _, u := range []*model.M_user{&alice, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	own := model.M_workspace{UserID: alice.ID, Name: "Alice's"}
+	shared := model.M_workspace{UserID: bob.ID, Name: "Bob's"}
+	for _, w := range []*model.M_workspace{&own, &shared} {
+		if err := db.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	member := model.M_workspace_member{WorkspaceID: shared.ID, UserID: alice.ID, Email: alice.Email, Role: model.WorkspaceRoleEditor, Status: model.MemberStatusAccepted}
+	if err := db.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	expense := func(workspaceID uint) *model.T_expense {
+		e := &model.T_expense{UserID: alice.ID, WorkspaceID: workspaceID, Date: time.Now(), Currency: "IDR", Amount: money.One, OriginalAmount: money.One}
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	inOwn, inShared := expense(own.ID), expense(shared.ID)
+
+	audit := NewAuditRepository(db)
+	for _, e := range []*model.T_expense{inOwn, inShared} {
+		entry := &model.T_audit_log{UserID: &alice.ID, WorkspaceID: e.WorkspaceID, Action: model.AuditCreate, Entity: model.AuditExpense, EntityID: &e.ID, IP: "192.0.2.1"}
+		if err := audit.Record(entry, nil, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lockout := &model.T_audit_log{UserID: &alice.ID, Action: model.AuditLockout, Entity: model.AuditUser, EntityID: &alice.ID, IP: "192.0.2.1"}
+	if err := audit.Record(lockout, nil, map[string]int{"failures": 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	throttles := NewLoginThrottleRepository(db)
+	waits := []time.Duration{0}
+	for _, key := range []string{AccountThrottleKey(alice.Email), AccountThrottleKey(bob.Email)} {
+		if _, _, err := throttles.Reserve(key, time.Now(), time.Hour, waits); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := NewPersonalDataRepository(db).Purge(alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var users, expenses, memberships int64
+	db.Unscoped().Model(&model.M_user{}).Count(&users)
+	db.Unscoped().Model(&model.T_expense{}).Count(&expenses)
+	db.Unscoped().Model(&model.M_workspace_member{}).Count(&memberships)
+	if users != 1 || expenses != 1 || memberships != 0 {
+		t.Errorf("after purge: %d users, %d expenses, %d memberships; want 1, 1, 0", users, expenses, memberships)
+	}
+
+	var entries []model.T_audit_log
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d audit entries after purge, want all 3", len(entries))
+	}
+	for _, e := range entries {
+		if e.UserID != nil || e.IP != "" {
+			t.Errorf("audit entry %d still names user %v from %q", e.ID, e.UserID, e.IP)
+		}
+	}
+	if entries[2].EntityID != nil {
+		t.Errorf("lockout entry still names user %d", *entries[2].EntityID)
+	}
+
+	if th, err := throttles.Get(AccountThrottleKey(alice.Email)); err != nil || th.Failures != 0 {
+		t.Errorf("alice's sign-in failures after purge: %+v, %v", th, err)
+	}
+	if th, err := throttles.Get(AccountThrottleKey(bob.Email)); err != nil || th.Failures != 1 {
+		t.Errorf("bob's sign-in failures after purge: %+v, %v", th, err)
+	}
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/utils"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *RecoveryCodeRepository) WithTx(tx *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: tx}
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(utils.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Replace discards the user's recovery codes and stores hashes of codes instead.
+func (r *RecoveryCodeRepository) Replace(userID uint, codes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.M_recovery_code{}).Error; err != nil {
+			return err
+		}
+		rows := make([]model.M_recovery_code, len(codes))
+		for i, code := range codes {
+			rows[i] = model.M_recovery_code{UserID: userID, CodeHash: hashRecoveryCode(code)}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// Consume marks code as used and reports whether it was a valid, unused code
+// of the user.
+func (r *RecoveryCodeRepository) Consume(userID uint, code string) (bool, error) {
+	res := r.db.Model(&model.M_recovery_code{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *RecoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.M_recovery_code{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *RecoveryCodeRepository) DeleteAll(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.M_recovery_code{}).Error
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecurringRepository struct {
+	db *gorm.DB
+}
+
+func NewRecurringRepository(db *gorm.DB) *RecurringRepository {
+	return &RecurringRepository{db: db}
+}
+
+// RecurrenceOf returns the date series described by a rule.
+func RecurrenceOf(rule *model.M_recurring_rule) utils.Recurrence {
+	return utils.Recurrence{
+		Frequency: rule.Frequency,
+		Interval:  rule.Interval,
+		Anchor:    rule.Anchor,
+		Start:     rule.StartDate,
+	}
+}
+
+// RuleEndsBefore reports whether occurrence n on date d lies past the rule's
+// end date or occurrence limit.
+func RuleEndsBefore(rule *model.M_recurring_rule, n int, d time.Time) bool {
+	if rule.MaxOccurrences != nil && n >= *rule.MaxOccurrences {
+		return true
+	}
+	return rule.EndDate != nil && d.After(*rule.EndDate)
+}
+
+func (r *RecurringRepository) Create(rule *model.M_recurring_rule) error {
+	return r.db.Create(rule).Error
+}
+
+// SetPaused pauses or resumes a rule, locking it against a concurrent
+// Materialize. Occurrences that fell due while the rule was paused are left
+// out: resuming moves it to its first occurrence on or after today.
+func (r *RecurringRepository) SetPaused(id, userID, workspaceID uint, paused bool, today time.Time) (*model.M_recurring_rule, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var rule model.M_recurring_rule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("id = ?", id).
+			First(&rule).Error; err != nil {
+			return err
+		}
+		changes := map[string]interface{}{"is_paused": paused}
+		if rule.IsPaused && !paused && !rule.IsFinished {
+			series := RecurrenceOf(&rule)
+			for rule.NextDate.Before(today) && !RuleEndsBefore(&rule, rule.OccurrenceCount, rule.NextDate) {
+				rule.OccurrenceCount++
+				rule.NextDate = series.Occurrence(rule.OccurrenceCount)
+			}
+			changes["next_date"] = rule.NextDate
+			changes["occurrence_count"] = rule.OccurrenceCount
+			changes["is_finished"] = RuleEndsBefore(&rule, rule.OccurrenceCount, rule.NextDate)
+		}
+		return tx.Model(&rule).Updates(changes).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id, userID, workspaceID)
+}
+
+func (r *RecurringRepository) Delete(id, userID, workspaceID uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		Delete(&model.M_recurring_rule{}).Error
+}
+
+func (r *RecurringRepository) GetByID(id, userID, workspaceID uint) (*model.M_recurring_rule, error) {
+	var rule model.M_recurring_rule
+	if err := r.db.Preload("Categories").
+		Preload("Skips", func(db *gorm.DB) *gorm.DB { return db.Order("date ASC") }).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *RecurringRepository) GetByUser(userID, workspaceID uint) ([]model.M_recurring_rule, error) {
+	var rules []model.M_recurring_rule
+	if err := r.db.Preload("Categories").
+		Preload("Skips", func(db *gorm.DB) *gorm.DB { return db.Order("date ASC") }).
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Order("next_date ASC, id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *RecurringRepository) AddSkip(skip *model.M_recurring_skip) error {
+	return r.db.Create(skip).Error
+}
+
+// ListDueIDs returns active rules with an occurrence on or before today.
+func (r *RecurringRepository) ListDueIDs(today time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.M_recurring_rule{}).
+		Where("is_paused = ? AND is_finished = ? AND next_date <= ?", false, false, today).
+		Order("id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Materialized is what one Materialize call created for a rule.
+type Materialized struct {
+	UserID       uint
+	WorkspaceID  uint
+	Currency     string      // the workspace's base currency
+	Created      int         // expense and income rows
+	ExpenseDates []time.Time // dates of the expenses among them
+}
+
+// Materialize creates the expense or income rows for every occurrence of the
+// rule up to and including today, then advances the rule. The rule row is
+// locked so concurrent schedulers never create the same occurrence twice.
+func (r *RecurringRepository) Materialize(ruleID uint, today time.Time) (*Materialized, error) {
+	result := &Materialized{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var rule model.M_recurring_rule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ?", ruleID).
+			First(&rule).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if rule.IsPaused || rule.IsFinished {
+			return nil
+		}
+		if err := tx.Model(&rule).Association("Categories").Find(&rule.Categories); err != nil {
+			return err
+		}
+		if err := tx.Where("recurring_rule_id = ?", rule.ID).Find(&rule.Skips).Error; err != nil {
+			return err
+		}
+		skipped := make(map[string]bool, len(rule.Skips))
+		for _, s := range rule.Skips {
+			skipped[s.Date.Format("2006-01-02")] = true
+		}
+		// Rule amounts are in the workspace's base currency
+		currency, err := BaseCurrency(tx, rule.UserID, rule.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		result.UserID, result.WorkspaceID, result.Currency = rule.UserID, rule.WorkspaceID, currency
+
+		series := RecurrenceOf(&rule)
+		for !rule.NextDate.After(today) {
+			if RuleEndsBefore(&rule, rule.OccurrenceCount, rule.NextDate) {
+				rule.IsFinished = true
+				break
+			}
+			if !skipped[rule.NextDate.Format("2006-01-02")] {
+				if err := createOccurrence(tx, &rule, currency); err != nil {
+					return err
+				}
+				result.Created++
+				if rule.Type != "income" {
+					result.ExpenseDates = append(result.ExpenseDates, rule.NextDate)
+				}
+			}
+			rule.OccurrenceCount++
+			rule.NextDate = series.Occurrence(rule.OccurrenceCount)
+		}
+		if RuleEndsBefore(&rule, rule.OccurrenceCount, rule.NextDate) {
+			rule.IsFinished = true
+		}
+
+		return tx.Omit("Categories", "Skips").Save(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// createOccurrence adds the expense or income of the rule's next date, with
+// an audit entry in the name of the rule's owner.
+func createOccurrence(tx *gorm.DB, rule *model.M_recurring_rule, currency string) error {
+	ruleID := rule.ID
+	entry := &model.T_audit_log{UserID: &rule.UserID, WorkspaceID: rule.WorkspaceID, Action: model.AuditCreate}
+	if rule.Type == "income" {
+		in := &model.T_income{
+			UserID:          rule.UserID,
+			WorkspaceID:     rule.WorkspaceID,
+			Categories:      rule.Categories,
+			AccountID:       rule.AccountID,
+			Date:            rule.NextDate,
+			Amount:          rule.Amount,
+			Currency:        currency,
+			OriginalAmount:  rule.Amount,
+			Notes:           rule.Notes,
+			RecurringRuleID: &ruleID,
+		}
+		if err := tx.Create(in).Error; err != nil {
+			return err
+		}
+		entry.Entity, entry.EntityID = model.AuditIncome, &in.ID
+		return NewAuditRepository(tx).Record(entry, nil, in)
+	}
+	categoryIDs := make([]uint, len(rule.Categories))
+	for i, c := range rule.Categories {
+		categoryIDs[i] = c.ID
+	}
+	exp := &model.T_expense{
+		UserID:          rule.UserID,
+		WorkspaceID:     rule.WorkspaceID,
+		Splits:          SplitEvenly(rule.Amount, currency, categoryIDs),
+		AccountID:       rule.AccountID,
+		Date:            rule.NextDate,
+		Notes:           rule.Notes,
+		Amount:          rule.Amount,
+		Currency:        currency,
+		OriginalAmount:  rule.Amount,
+		RecurringRuleID: &ruleID,
+	}
+	if err := tx.Create(exp).Error; err != nil {
+		return err
+	}
+	entry.Entity, entry.EntityID = model.AuditExpense, &exp.ID
+	return NewAuditRepository(tx).Record(entry, nil, exp)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+)
+
+func TestResumeSkipsPausedOccurrences(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	two := 2
+	rent := model.M_recurring_rule{UserID: user.ID, Type: "expense", Amount: 100 * money.One, Frequency: "monthly", Interval: 1,
+		StartDate: day(1, 1), NextDate: day(1, 1), IsPaused: true}
+	limited := model.M_recurring_rule{UserID: user.ID, Type: "expense", Amount: 5 * money.One, Frequency: "monthly", Interval: 1,
+		StartDate: day(1, 1), NextDate: day(1, 1), MaxOccurrences: &two, IsPaused: true}
+	for _, rule := range []*model.M_recurring_rule{&rent, &limited} {
+		if err := db.Create(rule).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := NewRecurringRepository(db)
+	today := day(4, 15)
+	resumed, err := repo.SetPaused(rent.ID, user.ID, 0, false, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.IsPaused || !resumed.NextDate.Equal(day(5, 1)) || resumed.OccurrenceCount != 4 || resumed.IsFinished {
+		t.Errorf("resumed rule next on %s after %d occurrences (paused %v, finished %v), want 2026-05-01 after 4",
+			resumed.NextDate.Format("2006-01-02"), resumed.OccurrenceCount, resumed.IsPaused, resumed.IsFinished)
+	}
+	m, err := repo.Materialize(rent.ID, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Created != 0 {
+		t.Errorf("materializing after resuming created %d transactions, want 0", m.Created)
+	}
+
+	// A rule whose occurrences all fell inside the pause ends
+	resumed, err = repo.SetPaused(limited.ID, user.ID, 0, false, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.IsFinished {
+		t.Errorf("rule limited to 2 occurrences resumed after 3 months is not finished: %+v", resumed)
+	}
+
+	// Pausing leaves the schedule alone
+	paused, err := repo.SetPaused(rent.ID, user.ID, 0, true, day(6, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !paused.IsPaused || !paused.NextDate.Equal(day(5, 1)) {
+		t.Errorf("paused rule: paused %v, next on %s", paused.IsPaused, paused.NextDate.Format("2006-01-02"))
+	}
+	if _, err := repo.SetPaused(rent.ID, user.ID+1, 0, false, today); err == nil {
+		t.Error("another user resumed the rule")
+	}
+}
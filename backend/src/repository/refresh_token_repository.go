@@ -1,9 +1,37 @@
 package repository
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
 	"expenses-tracker/src/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenTTL is how long a refresh token stays valid after it is issued.
+// Each rotation starts the period again.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// refreshReuseGrace tolerates requests that were already in flight with a
+// token when it got rotated, so parallel requests from one client do not
+// look like theft.
+const refreshReuseGrace = 15 * time.Second
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	// ErrRefreshTokenRotated means the token was rotated moments ago, most
+	// likely by a parallel request of the same client, which holds the
+	// successor.
+	ErrRefreshTokenRotated = errors.New("refresh token already rotated")
 )
 
 type RefreshTokenRepository struct {
@@ -14,22 +42,192 @@ func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
 	return &RefreshTokenRepository{db: db}
 }
 
-func (r *RefreshTokenRepository) Create(t *model.M_refresh_token) error {
-	return r.db.Create(t).Error
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *RefreshTokenRepository) WithTx(tx *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: tx}
+}
+
+// hashToken returns the form an opaque token is stored and looked up in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue starts a new token family for a sign-in and returns the plaintext
+// token alongside its stored row.
+func (r *RefreshTokenRepository) Issue(userID uint, deviceID, userAgent string) (string, *model.M_refresh_token, error) {
+	family, err := RandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	rt := &model.M_refresh_token{
+		UserID:    userID,
+		FamilyID:  family,
+		DeviceID:  deviceID,
+		UserAgent: userAgent,
+		StartedAt: time.Now(),
+	}
+	token, err := issue(r.db, rt)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, rt, nil
+}
+
+// Rotate exchanges token for its successor in the same family and returns the
+// new plaintext token with its row.
+//
+// Presenting a token that was already rotated revokes the whole family and
+// returns ErrRefreshTokenReused, since either the client or whoever copied the
+// token is replaying it. Within refreshReuseGrace of the rotation the family
+// is left alone but nothing is issued either: Rotate returns
+// ErrRefreshTokenRotated and the client should retry with the successor it
+// received first.
+func (r *RefreshTokenRepository) Rotate(token, deviceID string) (string, *model.M_refresh_token, error) {
+	var (
+		newToken string
+		next     *model.M_refresh_token
+		reused   *model.M_refresh_token
+	)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var rt model.M_refresh_token
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(token)).
+			First(&rt).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if rt.RotatedAt != nil {
+			if now.Sub(*rt.RotatedAt) <= refreshReuseGrace {
+				return ErrRefreshTokenRotated
+			}
+			reused = &rt
+			return tx.Where("user_id = ? AND family_id = ?", rt.UserID, rt.FamilyID).Delete(&model.M_refresh_token{}).Error
+		}
+		if now.After(rt.ExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		if err := tx.Model(&rt).Update("rotated_at", now).Error; err != nil {
+			return err
+		}
+		next = &model.M_refresh_token{
+			UserID:     rt.UserID,
+			FamilyID:   rt.FamilyID,
+			DeviceID:   rt.DeviceID,
+			UserAgent:  rt.UserAgent,
+			UsedCount:  rt.UsedCount + 1,
+			StartedAt:  rt.StartedAt,
+			LastUsedAt: &now,
+		}
+		if deviceID != "" {
+			next.DeviceID = deviceID
+		}
+		var err error
+		newToken, err = issue(tx, next)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if reused != nil {
+		log.Printf("Refresh token reuse for user %d, revoked family %s", reused.UserID, reused.FamilyID)
+		return "", nil, ErrRefreshTokenReused
+	}
+	return newToken, next, nil
 }
 
+// GetByToken returns the stored row of a plaintext token.
 func (r *RefreshTokenRepository) GetByToken(token string) (*model.M_refresh_token, error) {
 	var rt model.M_refresh_token
-	if err := r.db.Where("token = ?", token).First(&rt).Error; err != nil {
+	if err := r.db.Where("token_hash = ?", hashToken(token)).First(&rt).Error; err != nil {
 		return nil, err
 	}
 	return &rt, nil
 }
 
-func (r *RefreshTokenRepository) Delete(t *model.M_refresh_token) error {
-	return r.db.Delete(t).Error
+// GetActive lists the user's sessions as the live token of each family, most
+// recently used first.
+func (r *RefreshTokenRepository) GetActive(userID uint) ([]model.M_refresh_token, error) {
+	var items []model.M_refresh_token
+	if err := r.db.
+		Where("user_id = ? AND rotated_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("COALESCE(last_used_at, created_at) DESC, id DESC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Revoke deletes every token of one of the user's families. It returns
+// gorm.ErrRecordNotFound when the user has no such family.
+func (r *RefreshTokenRepository) Revoke(userID uint, familyID string) error {
+	res := r.db.Where("user_id = ? AND family_id = ?", userID, familyID).Delete(&model.M_refresh_token{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAll deletes every token of the user except those of keepFamily
+// ("" keeps none) and returns how many sessions were revoked.
+func (r *RefreshTokenRepository) RevokeAll(userID uint, keepFamily string) (int64, error) {
+	others := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("user_id = ?", userID)
+		if keepFamily != "" {
+			db = db.Where("family_id <> ?", keepFamily)
+		}
+		return db
+	}
+	var sessions int64
+	if err := r.db.Model(&model.M_refresh_token{}).
+		Scopes(others).
+		Where("rotated_at IS NULL AND expires_at > ?", time.Now()).
+		Count(&sessions).Error; err != nil {
+		return 0, err
+	}
+	if err := r.db.Scopes(others).Delete(&model.M_refresh_token{}).Error; err != nil {
+		return 0, err
+	}
+	return sessions, nil
 }
 
-func (r *RefreshTokenRepository) Save(t *model.M_refresh_token) error {
-	return r.db.Save(t).Error
+// PurgeExpired permanently removes tokens that expired before now and those
+// already revoked, returning how many rows were deleted.
+func (r *RefreshTokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	res := r.db.Unscoped().
+		Where("expires_at < ? OR deleted_at IS NOT NULL", now).
+		Delete(&model.M_refresh_token{})
+	return res.RowsAffected, res.Error
+}
+
+// issue generates a token for rt, stores rt with the token's hash and returns
+// the plaintext token.
+func issue(db *gorm.DB, rt *model.M_refresh_token) (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	rt.TokenHash = hashToken(token)
+	rt.ExpiresAt = time.Now().Add(RefreshTokenTTL)
+	if err := db.Create(rt).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RandomToken returns n random bytes, base64url encoded without padding.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/testdb"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRefreshTokenRepository(db)
+
+	first, _, err := repo.Issue(user.ID, "laptop", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, rt, err := repo.Rotate(first, "")
+	if err != nil || second == "" || rt.UsedCount != 1 {
+		t.Fatalf("Rotate = %q, %+v, %v", second, rt, err)
+	}
+
+	// A parallel request with the first token gets nothing new, and the
+	// session survives
+	if token, _, err := repo.Rotate(first, ""); !errors.Is(err, ErrRefreshTokenRotated) || token != "" {
+		t.Errorf("Rotate within grace = %q, %v; want ErrRefreshTokenRotated", token, err)
+	}
+	third, _, err := repo.Rotate(second, "")
+	if err != nil {
+		t.Fatalf("Rotate successor after grace refusal: %v", err)
+	}
+
+	// Replaying a token rotated long ago signs the whole session out
+	if err := db.Model(&model.M_refresh_token{}).
+		Where("token_hash = ?", hashToken(first)).
+		Update("rotated_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.Rotate(first, ""); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Rotate after grace = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, _, err := repo.Rotate(third, ""); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("Rotate of the live token after reuse = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if active, err := repo.GetActive(user.ID); err != nil || len(active) != 0 {
+		t.Errorf("active sessions after reuse: %d, %v", len(active), err)
+	}
+}
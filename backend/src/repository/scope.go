@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WorkspaceScope restricts a query to the rows of the active workspace. Every
+// repository read and write goes through this scope so that data from one
+// workspace never shows up in another.
+//
+// Shared workspaces are visible to all of their members (membership is checked
+// by CustomContextMiddleware), so rows are matched on workspace_id alone. The
+// personal workspace (ID 0) is private to each user and also matches user_id.
+func WorkspaceScope(userID uint, workspaceID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		inWorkspace := clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "workspace_id"}, Value: workspaceID}
+		if workspaceID != 0 {
+			return db.Where(inWorkspace)
+		}
+		return db.Where(clause.And(
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "user_id"}, Value: userID},
+			inWorkspace,
+		))
+	}
+}
@@ -14,10 +14,10 @@ func NewTemplateRepository(db *gorm.DB) *TemplateRepository {
 	return &TemplateRepository{db: db}
 }
 
-func (r *TemplateRepository) GetByUser(userID uint) ([]model.M_expense_template, error) {
+func (r *TemplateRepository) GetByUser(userID uint, workspaceID uint) ([]model.M_expense_template, error) {
 	var templates []model.M_expense_template
-	if err := r.db.Preload("Category").
-		Where("user_id = ?", userID).
+	if err := r.db.Preload("Categories").
+		Scopes(WorkspaceScope(userID, workspaceID)).
 		Order("name ASC").
 		Find(&templates).Error; err != nil {
 		return nil, err
@@ -29,9 +29,8 @@ func (r *TemplateRepository) Create(t *model.M_expense_template) error {
 	return r.db.Create(t).Error
 }
 
-func (r *TemplateRepository) Delete(id, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).
+func (r *TemplateRepository) Delete(id, userID, workspaceID uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
 		Delete(&model.M_expense_template{}).Error
 }
-
-
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+type TransferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+func (r *TransferRepository) Create(t *model.T_transfer) error {
+	return r.db.Create(t).Error
+}
+
+func (r *TransferRepository) Delete(id uint, userID uint, workspaceID uint) error {
+	return r.db.Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ?", id).
+		Delete(&model.T_transfer{}).Error
+}
+
+// GetByUser lists transfers newest first, optionally only those touching accountID.
+func (r *TransferRepository) GetByUser(userID uint, workspaceID uint, accountID *uint) ([]model.T_transfer, error) {
+	var items []model.T_transfer
+	db := r.db.Preload("FromAccount").Preload("ToAccount").
+		Scopes(WorkspaceScope(userID, workspaceID))
+	if accountID != nil {
+		db = db.Where("(from_account_id = ? OR to_account_id = ?)", *accountID, *accountID)
+	}
+	if err := db.Order("date DESC, id DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
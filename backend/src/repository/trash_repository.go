@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of trashed items
+const (
+	TrashExpense  = "expense"
+	TrashIncome   = "income"
+	TrashCategory = "category"
+)
+
+// ErrCategoryInUse is returned when purging a category that expenses or
+// income, trashed or not, still refer to.
+var ErrCategoryInUse = errors.New("category is still in use")
+
+// TrashItem is a soft-deleted expense, income or category. Label is the notes
+// of a transaction or the name of a category; categories have no amount or date.
+type TrashItem struct {
+	Type      string        `json:"type"`
+	ID        uint          `json:"id"`
+	Label     string        `json:"label"`
+	Amount    *money.Amount `json:"amount,omitempty"`
+	Date      *time.Time    `json:"date,omitempty"`
+	DeletedAt time.Time     `json:"deletedAt"`
+}
+
+// TrashRepository lists, restores and permanently deletes soft-deleted rows.
+// Expense splits and income category links are left in place by a soft
+// delete, so restoring a record brings its categories back with it.
+type TrashRepository struct {
+	db *gorm.DB
+}
+
+func NewTrashRepository(db *gorm.DB) *TrashRepository {
+	return &TrashRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *TrashRepository) WithTx(tx *gorm.DB) *TrashRepository {
+	return &TrashRepository{db: tx}
+}
+
+// List returns up to limit trashed items of the workspace, most recently
+// deleted first. itemType limits the list to one kind; empty lists all.
+func (r *TrashRepository) List(userID uint, workspaceID uint, itemType string, limit int) ([]TrashItem, error) {
+	queries := []struct {
+		kind   string
+		model  interface{}
+		fields string
+	}{
+		{TrashExpense, &model.T_expense{}, "id, notes AS label, amount, date, deleted_at"},
+		{TrashIncome, &model.T_income{}, "id, notes AS label, amount, date, deleted_at"},
+		{TrashCategory, &model.M_category{}, "id, name AS label, deleted_at"},
+	}
+
+	items := []TrashItem{}
+	for _, q := range queries {
+		if itemType != "" && itemType != q.kind {
+			continue
+		}
+		var found []TrashItem
+		if err := r.db.Unscoped().Model(q.model).
+			Select(q.fields).
+			Scopes(WorkspaceScope(userID, workspaceID)).
+			Where("deleted_at IS NOT NULL").
+			Order("deleted_at DESC").
+			Limit(limit).
+			Scan(&found).Error; err != nil {
+			return nil, err
+		}
+		for i := range found {
+			found[i].Type = q.kind
+		}
+		items = append(items, found...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// GetExpense returns a trashed expense with its splits.
+func (r *TrashRepository) GetExpense(userID uint, workspaceID uint, id uint) (*model.T_expense, error) {
+	var e model.T_expense
+	err := r.db.Unscoped().Preload("Splits.Category").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&e).Error
+	return &e, err
+}
+
+// GetIncome returns a trashed income with its categories.
+func (r *TrashRepository) GetIncome(userID uint, workspaceID uint, id uint) (*model.T_income, error) {
+	var in model.T_income
+	err := r.db.Unscoped().Preload("Categories").
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&in).Error
+	return &in, err
+}
+
+// GetCategory returns a trashed category.
+func (r *TrashRepository) GetCategory(userID uint, workspaceID uint, id uint) (*model.M_category, error) {
+	var category model.M_category
+	err := r.db.Unscoped().
+		Scopes(WorkspaceScope(userID, workspaceID)).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&category).Error
+	return &category, err
+}
+
+// RestoreExpense takes an expense out of the trash together with any trashed
+// categories its splits use, and returns those categories.
+func (r *TrashRepository) RestoreExpense(e *model.T_expense) ([]model.M_category, error) {
+	var restored []model.M_category
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, err = restoreCategories(tx, tx.Model(&model.T_expense_split{}).Select("category_id").Where("expense_id = ?", e.ID))
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&model.T_expense{}).Where("id = ?", e.ID).Update("deleted_at", nil).Error
+	})
+	return restored, err
+}
+
+// RestoreIncome takes an income out of the trash together with any trashed
+// categories it is linked to, and returns those categories.
+func (r *TrashRepository) RestoreIncome(in *model.T_income) ([]model.M_category, error) {
+	var restored []model.M_category
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, err = restoreCategories(tx, tx.Table("t_income_categories").Select("m_category_id").Where("t_income_id = ?", in.ID))
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&model.T_income{}).Where("id = ?", in.ID).Update("deleted_at", nil).Error
+	})
+	return restored, err
+}
+
+// RestoreCategory takes a category out of the trash. If a live category of
+// the workspace has taken its slug meanwhile, the restored one gets a numbered
+// suffix.
+func (r *TrashRepository) RestoreCategory(category *model.M_category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		restored, err := restoreCategories(tx, []uint{category.ID})
+		if err == nil && len(restored) == 1 {
+			*category = restored[0]
+		}
+		return err
+	})
+}
+
+// restoreCategories undeletes the trashed categories among ids, which may be
+// a subquery, and returns them as restored.
+func restoreCategories(tx *gorm.DB, ids interface{}) ([]model.M_category, error) {
+	var categories []model.M_category
+	if err := tx.Unscoped().Where("id IN (?) AND deleted_at IS NOT NULL", ids).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for i := range categories {
+		category := &categories[i]
+		updates := map[string]interface{}{"deleted_at": nil}
+		if category.Slug != "" {
+			slug, err := freeSlug(tx, category)
+			if err != nil {
+				return nil, err
+			}
+			category.Slug = slug
+			updates["slug"] = slug
+		}
+		if err := tx.Unscoped().Model(&model.M_category{}).Where("id = ?", category.ID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		category.DeletedAt = gorm.DeletedAt{}
+	}
+	return categories, nil
+}
+
+// freeSlug returns the category's slug, or the first "<slug>-<n>" that no live
+// category of its workspace uses.
+func freeSlug(tx *gorm.DB, category *model.M_category) (string, error) {
+	slug := category.Slug
+	for n := 1; ; n++ {
+		var count int64
+		if err := tx.Model(&model.M_category{}).
+			Scopes(WorkspaceScope(category.UserID, category.WorkspaceID)).
+			Where("slug = ? AND id != ?", slug, category.ID).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = category.Slug + "-" + strconv.Itoa(n)
+	}
+}
+
+// PurgeExpense permanently deletes a trashed expense and its splits.
+func (r *TrashRepository) PurgeExpense(e *model.T_expense) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return purgeExpenses(tx, tx.Unscoped().Model(&model.T_expense{}).Select("id").Where("id = ? AND deleted_at IS NOT NULL", e.ID))
+	})
+}
+
+// PurgeIncome permanently deletes a trashed income and its category links.
+func (r *TrashRepository) PurgeIncome(in *model.T_income) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return purgeIncome(tx, tx.Unscoped().Model(&model.T_income{}).Select("id").Where("id = ? AND deleted_at IS NOT NULL", in.ID))
+	})
+}
+
+// PurgeCategory permanently deletes a trashed category, which also drops its
+// budgets and alerts. It fails with ErrCategoryInUse while any expense or
+// income, including trashed ones, still uses the category.
+func (r *TrashRepository) PurgeCategory(category *model.M_category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		purged, err := purgeCategories(tx, tx.Unscoped().Model(&model.M_category{}).Select("id").Where("id = ? AND deleted_at IS NOT NULL", category.ID))
+		if err != nil {
+			return err
+		}
+		if purged == 0 {
+			return ErrCategoryInUse
+		}
+		return nil
+	})
+}
+
+// PurgeDeletedBefore permanently deletes everything trashed before cutoff in
+// all workspaces and returns how many items went. Categories still in use are
+// kept until the records using them are gone.
+func (r *TrashRepository) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	var total int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var expenses, income int64
+		if err := tx.Unscoped().Model(&model.T_expense{}).Where("deleted_at < ?", cutoff).Count(&expenses).Error; err != nil {
+			return err
+		}
+		if err := purgeExpenses(tx, tx.Unscoped().Model(&model.T_expense{}).Select("id").Where("deleted_at < ?", cutoff)); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&model.T_income{}).Where("deleted_at < ?", cutoff).Count(&income).Error; err != nil {
+			return err
+		}
+		if err := purgeIncome(tx, tx.Unscoped().Model(&model.T_income{}).Select("id").Where("deleted_at < ?", cutoff)); err != nil {
+			return err
+		}
+		categories, err := purgeCategories(tx, tx.Unscoped().Model(&model.M_category{}).Select("id").Where("deleted_at < ?", cutoff))
+		if err != nil {
+			return err
+		}
+		total = expenses + income + categories
+		return nil
+	})
+	return total, err
+}
+
+// purgeExpenses hard-deletes the expenses whose IDs ids selects.
+func purgeExpenses(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Where("expense_id IN (?)", ids).Delete(&model.T_expense_split{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN (?)", ids).Delete(&model.T_expense{}).Error
+}
+
+// purgeIncome hard-deletes the income whose IDs ids selects.
+func purgeIncome(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM t_income_categories WHERE t_income_id IN (?)", ids).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN (?)", ids).Delete(&model.T_income{}).Error
+}
+
+// purgeCategories hard-deletes the categories whose IDs ids selects, skipping
+// those still used by an expense or income, and returns how many went.
+func purgeCategories(tx *gorm.DB, ids *gorm.DB) (int64, error) {
+	res := tx.Unscoped().
+		Where("id IN (?)", ids).
+		Where("NOT EXISTS (SELECT 1 FROM t_expense_splits WHERE t_expense_splits.category_id = m_categories.id)").
+		Where("NOT EXISTS (SELECT 1 FROM t_income_categories WHERE t_income_categories.m_category_id = m_categories.id)").
+		Delete(&model.M_category{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestPurgeDeletedBefore(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	old := gorm.DeletedAt{Time: now.AddDate(0, 0, -40), Valid: true}
+	recent := gorm.DeletedAt{Time: now.AddDate(0, 0, -1), Valid: true}
+
+	unused := model.M_category{Name: "Unused", Type: "expense", UserID: user.ID}
+	used := model.M_category{Name: "Used", Type: "expense", UserID: user.ID}
+	for _, c := range []*model.M_category{&unused, &used} {
+		if err := db.Create(c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	expense := func(deleted gorm.DeletedAt) *model.T_expense {
+		e := &model.T_expense{
+			UserID: user.ID, Date: now, Currency: "IDR", Amount: money.One, OriginalAmount: money.One,
+			Splits: []model.T_expense_split{{CategoryID: used.ID, Amount: money.One}},
+		}
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Unscoped().Model(e).Update("deleted_at", deleted).Error; err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	expired, kept := expense(old), expense(recent)
+	for _, c := range []*model.M_category{&unused, &used} {
+		if err := db.Unscoped().Model(c).Update("deleted_at", old).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := NewTrashRepository(db).PurgeDeletedBefore(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d items, want the expired expense and the unused category", purged)
+	}
+	exists := func(v interface{}, id uint) bool {
+		var n int64
+		db.Unscoped().Model(v).Where("id = ?", id).Count(&n)
+		return n > 0
+	}
+	if exists(&model.T_expense{}, expired.ID) || exists(&model.M_category{}, unused.ID) {
+		t.Error("items past the retention period are still there")
+	}
+	if !exists(&model.T_expense{}, kept.ID) {
+		t.Error("an expense within the retention period was purged")
+	}
+	// Still used by an expense in the trash, so it waits for that one
+	if !exists(&model.M_category{}, used.ID) {
+		t.Error("a category still in use was purged")
+	}
+	var splits int64
+	db.Model(&model.T_expense_split{}).Where("expense_id = ?", expired.ID).Count(&splits)
+	if splits != 0 {
+		t.Errorf("%d splits of the purged expense remain", splits)
+	}
+}
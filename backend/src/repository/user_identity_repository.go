@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+func (r *UserIdentityRepository) WithTx(tx *gorm.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{db: tx}
+}
+
+func (r *UserIdentityRepository) GetBySubject(issuer, subject string) (*model.M_user_identity, error) {
+	var identity model.M_user_identity
+	if err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *UserIdentityRepository) GetByUser(userID uint) ([]model.M_user_identity, error) {
+	var items []model.M_user_identity
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *UserIdentityRepository) Create(identity *model.M_user_identity) error {
+	return r.db.Create(identity).Error
+}
+
+// Touch records a login through the identity and the email it came with.
+func (r *UserIdentityRepository) Touch(id uint, email string, now time.Time) error {
+	return r.db.Model(&model.M_user_identity{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"email": email, "last_login_at": now}).Error
+}
+
+// Delete unlinks one of the user's identities. It returns
+// gorm.ErrRecordNotFound when the user has no such identity.
+func (r *UserIdentityRepository) Delete(userID uint, id uint) error {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.M_user_identity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
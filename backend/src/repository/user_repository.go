@@ -14,6 +14,11 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{db: tx}
+}
+
 func (r *UserRepository) Create(user *model.M_user) error {
 	return r.db.Create(user).Error
 }
@@ -49,3 +54,37 @@ func (r *UserRepository) EmailExists(email string, excludedID uint) (bool, error
 	}
 	return count > 0, nil
 }
+
+// ClaimTOTPStep records step as the newest accepted TOTP step. It reports
+// false when a code of that step or a later one was already used, which makes
+// every code single-use.
+func (r *UserRepository) ClaimTOTPStep(userID uint, step int64) (bool, error) {
+	res := r.db.Model(&model.M_user{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected > 0, res.Error
+}
+
+// SealTOTPSecrets encrypts the two-factor secrets still stored in the clear
+// with seal and returns how many were updated.
+func (r *UserRepository) SealTOTPSecrets(seal func(userID uint, secret string) (string, error)) (int, error) {
+	var users []model.M_user
+	if err := r.db.Unscoped().
+		Select("id", "totp_secret").
+		Where("totp_secret <> '' AND totp_secret NOT LIKE 'v1:%'").
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+	for _, u := range users {
+		sealed, err := seal(u.ID, u.TOTPSecret)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.db.Unscoped().Model(&model.M_user{}).
+			Where("id = ? AND totp_secret = ?", u.ID, u.TOTPSecret).
+			Update("totp_secret", sealed).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
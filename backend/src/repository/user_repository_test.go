@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/testdb"
+)
+
+func TestTOTPStepsAreClaimedOnce(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	other := model.M_user{Name: "Other", Email: "other@example.com", Password: "x", Currency: "IDR"}
+	for _, u := range []*model.M_user{&user, &other} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	users := NewUserRepository(db)
+
+	const step = 37037036
+	if ok, err := users.ClaimTOTPStep(user.ID, step); err != nil || !ok {
+		t.Fatalf("first claim = %v, %v; want true", ok, err)
+	}
+	if ok, err := users.ClaimTOTPStep(user.ID, step); err != nil || ok {
+		t.Errorf("same step again = %v, %v; want false", ok, err)
+	}
+	if ok, err := users.ClaimTOTPStep(user.ID, step-1); err != nil || ok {
+		t.Errorf("earlier step = %v, %v; want false", ok, err)
+	}
+	if ok, err := users.ClaimTOTPStep(other.ID, step); err != nil || !ok {
+		t.Errorf("same step for another user = %v, %v; want true", ok, err)
+	}
+	if ok, err := users.ClaimTOTPStep(user.ID, step+1); err != nil || !ok {
+		t.Errorf("next step = %v, %v; want true", ok, err)
+	}
+}
+
+func TestRecoveryCodesAreUsedOnce(t *testing.T) {
+	db := testdb.Open(t)
+	user := model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes := NewRecoveryCodeRepository(db)
+	if err := codes.Replace(user.ID, []string{"abcde-fghij", "klmno-pqrst"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := codes.Consume(user.ID, "ABCDE FGHIJ"); err != nil || !ok {
+		t.Fatalf("first use = %v, %v; want true", ok, err)
+	}
+	if ok, err := codes.Consume(user.ID, "abcde-fghij"); err != nil || ok {
+		t.Errorf("second use = %v, %v; want false", ok, err)
+	}
+	if n, err := codes.CountUnused(user.ID); err != nil || n != 1 {
+		t.Errorf("unused = %d, %v; want 1", n, err)
+	}
+}
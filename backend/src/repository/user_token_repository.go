@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUserTokenInvalid is returned for tokens that are unknown, used, expired
+// or meant for another purpose.
+var ErrUserTokenInvalid = errors.New("invalid or expired token")
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// Issue creates a token for purpose sent to email and returns it in
+// plaintext. Earlier unused tokens of the user for the same purpose stop
+// working, so only the latest email counts.
+func (r *UserTokenRepository) Issue(userID uint, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&model.M_user_token{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.M_user_token{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			Email:     email,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume marks a token as used and returns it. Each token works once; the
+// use is rolled back if fn fails, so fn should apply whatever the token
+// grants using tx.
+func (r *UserTokenRepository) Consume(token, purpose string, fn func(tx *gorm.DB, t *model.M_user_token) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var t model.M_user_token
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+			First(&t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if t.UsedAt != nil || now.After(t.ExpiresAt) {
+			return ErrUserTokenInvalid
+		}
+		if err := tx.Model(&t).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return fn(tx, &t)
+	})
+}
+
+// PurgeExpired deletes tokens that can no longer be used as of now.
+func (r *UserTokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ? OR used_at IS NOT NULL", now).Delete(&model.M_user_token{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"expenses-tracker/src/model"
+
+	"gorm.io/gorm"
+)
+
+type WorkspaceMemberRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceMemberRepository(db *gorm.DB) *WorkspaceMemberRepository {
+	return &WorkspaceMemberRepository{db: db}
+}
+
+func (r *WorkspaceMemberRepository) Create(m *model.M_workspace_member) error {
+	return r.db.Create(m).Error
+}
+
+func (r *WorkspaceMemberRepository) Update(m *model.M_workspace_member) error {
+	return r.db.Save(m).Error
+}
+
+func (r *WorkspaceMemberRepository) Delete(m *model.M_workspace_member) error {
+	return r.db.Delete(m).Error
+}
+
+func (r *WorkspaceMemberRepository) ListByWorkspace(workspaceID uint) ([]model.M_workspace_member, error) {
+	var list []model.M_workspace_member
+	err := r.db.Where("workspace_id = ?", workspaceID).
+		Order("created_at ASC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *WorkspaceMemberRepository) GetByID(workspaceID uint, id uint) (*model.M_workspace_member, error) {
+	var m model.M_workspace_member
+	if err := r.db.Where("id = ? AND workspace_id = ?", id, workspaceID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *WorkspaceMemberRepository) GetByEmail(workspaceID uint, email string) (*model.M_workspace_member, error) {
+	var m model.M_workspace_member
+	if err := r.db.Where("workspace_id = ? AND LOWER(email) = LOWER(?)", workspaceID, email).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListPendingByEmail returns open invitations addressed to email.
+func (r *WorkspaceMemberRepository) ListPendingByEmail(email string) ([]model.M_workspace_member, error) {
+	var list []model.M_workspace_member
+	err := r.db.Preload("Workspace").
+		Where("LOWER(email) = LOWER(?) AND status = ?", email, model.MemberStatusPending).
+		Order("created_at DESC").
+		Find(&list).Error
+	return list, err
+}
@@ -14,10 +14,33 @@ func NewWorkspaceRepository(db *gorm.DB) *WorkspaceRepository {
 	return &WorkspaceRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *WorkspaceRepository) WithTx(tx *gorm.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: tx}
+}
+
+// Create stores the workspace together with the owner's membership row.
 func (r *WorkspaceRepository) Create(ws *model.M_workspace) error {
-	return r.db.Create(ws).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ws).Error; err != nil {
+			return err
+		}
+		var owner model.M_user
+		if err := tx.First(&owner, ws.UserID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.M_workspace_member{
+			WorkspaceID: ws.ID,
+			UserID:      ws.UserID,
+			Email:       owner.Email,
+			Role:        model.WorkspaceRoleOwner,
+			Status:      model.MemberStatusAccepted,
+			InvitedBy:   ws.UserID,
+		}).Error
+	})
 }
 
+// GetByID returns a workspace owned by userID.
 func (r *WorkspaceRepository) GetByID(userID uint, id uint) (*model.M_workspace, error) {
 	var ws model.M_workspace
 	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&ws).Error; err != nil {
@@ -26,9 +49,68 @@ func (r *WorkspaceRepository) GetByID(userID uint, id uint) (*model.M_workspace,
 	return &ws, nil
 }
 
+// GetAccessible returns a workspace the user owns or is an accepted member of.
+func (r *WorkspaceRepository) GetAccessible(userID uint, id uint) (*model.M_workspace, error) {
+	var ws model.M_workspace
+	if err := r.db.Scopes(r.accessibleBy(userID)).Where("id = ?", id).First(&ws).Error; err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// GetAccess returns a workspace together with the user's role in it. The
+// workspace creator is always the owner, so workspaces created before
+// memberships existed keep working.
+func (r *WorkspaceRepository) GetAccess(userID uint, workspaceID uint) (*model.M_workspace, string, error) {
+	var ws model.M_workspace
+	if err := r.db.First(&ws, workspaceID).Error; err != nil {
+		return nil, "", err
+	}
+	if ws.UserID == userID {
+		return &ws, model.WorkspaceRoleOwner, nil
+	}
+	var m model.M_workspace_member
+	if err := r.db.
+		Where("workspace_id = ? AND user_id = ? AND status = ?", workspaceID, userID, model.MemberStatusAccepted).
+		First(&m).Error; err != nil {
+		return nil, "", err
+	}
+	return &ws, m.Role, nil
+}
+
+// BaseCurrency returns the currency a workspace's amounts are kept in: its
+// own for a shared workspace, the user's for the personal workspace 0.
+func BaseCurrency(db *gorm.DB, userID uint, workspaceID uint) (string, error) {
+	var currency string
+	query := db.Model(&model.M_workspace{}).Select("currency").Where("id = ?", workspaceID)
+	if workspaceID == 0 {
+		query = db.Model(&model.M_user{}).Select("currency").Where("id = ?", userID)
+	}
+	if err := query.Scan(&currency).Error; err != nil {
+		return "", err
+	}
+	return currency, nil
+}
+
+// MemberUserIDs returns the users who can see a workspace: its owner and
+// every accepted member. The personal workspace 0 belongs to userID alone.
+func (r *WorkspaceRepository) MemberUserIDs(userID uint, workspaceID uint) ([]uint, error) {
+	if workspaceID == 0 {
+		return []uint{userID}, nil
+	}
+	var ids []uint
+	err := r.db.Raw(`SELECT user_id FROM m_workspaces WHERE id = ? AND deleted_at IS NULL
+		UNION
+		SELECT user_id FROM m_workspace_members WHERE workspace_id = ? AND status = ? AND user_id <> 0 AND deleted_at IS NULL`,
+		workspaceID, workspaceID, model.MemberStatusAccepted).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// ListByUser returns workspaces the user owns or has joined.
 func (r *WorkspaceRepository) ListByUser(userID uint, q string) ([]model.M_workspace, error) {
 	var list []model.M_workspace
-	query := r.db.Where("user_id = ?", userID)
+	query := r.db.Scopes(r.accessibleBy(userID))
 	if q != "" {
 		query = query.Where("name ILIKE ?", "%"+q+"%")
 	}
@@ -40,7 +122,11 @@ func (r *WorkspaceRepository) Update(ws *model.M_workspace) error {
 	return r.db.Save(ws).Error
 }
 
-
-
-
-
+func (r *WorkspaceRepository) accessibleBy(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		joined := r.db.Model(&model.M_workspace_member{}).
+			Select("workspace_id").
+			Where("user_id = ? AND status = ?", userID, model.MemberStatusAccepted)
+		return db.Where("user_id = ? OR id IN (?)", userID, joined)
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/money"
+	"expenses-tracker/src/testdb"
+
+	"gorm.io/gorm"
+)
+
+// scopeFixture holds one user's data in a shared workspace and in their
+// personal workspace, plus the views that must not see it.
+type scopeFixture struct {
+	owner, stranger model.M_user
+	shared, other   model.M_workspace
+}
+
+type scopeView struct {
+	name        string
+	userID      uint
+	workspaceID uint
+}
+
+func newScopeFixture(t *testing.T, db *gorm.DB) *scopeFixture {
+	t.Helper()
+	f := &scopeFixture{
+		owner:    model.M_user{Name: "Owner", Email: "owner@example.com", Password: "x", Currency: "IDR"},
+		stranger: model.M_user{Name: "Stranger", Email: "stranger@example.com", Password: "x", Currency: "IDR"},
+	}
+	for _, u := range []*model.M_user{&f.owner, &f.stranger} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.shared = model.M_workspace{UserID: f.owner.ID, Name: "Shared", Currency: "IDR"}
+	f.other = model.M_workspace{UserID: f.owner.ID, Name: "Other", Currency: "IDR"}
+	for _, ws := range []*model.M_workspace{&f.shared, &f.other} {
+		if err := db.Create(ws).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// outsiders returns the views of the same data from anywhere but workspaceID.
+func (f *scopeFixture) outsiders(workspaceID uint) []scopeView {
+	if workspaceID == 0 {
+		return []scopeView{
+			{"owner in shared workspace", f.owner.ID, f.shared.ID},
+			{"stranger in personal workspace", f.stranger.ID, 0},
+		}
+	}
+	return []scopeView{
+		{"owner in other workspace", f.owner.ID, f.other.ID},
+		{"owner in personal workspace", f.owner.ID, 0},
+		{"stranger in personal workspace", f.stranger.ID, 0},
+	}
+}
+
+func TestWorkspaceScopeIsolatesData(t *testing.T) {
+	db := testdb.Open(t)
+	f := newScopeFixture(t, db)
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	expenses := NewExpenseRepository(db)
+	incomes := NewIncomeRepository(db)
+	categories := NewCategoryRepository(db)
+	budgets := NewBudgetRepository(db)
+	templates := NewTemplateRepository(db)
+
+	for _, wsID := range []uint{f.shared.ID, 0} {
+		cat := model.M_category{Name: "Food", Type: "expense", UserID: f.owner.ID, WorkspaceID: wsID}
+		if err := categories.Create(&cat); err != nil {
+			t.Fatal(err)
+		}
+		exp := model.T_expense{
+			UserID: f.owner.ID, WorkspaceID: wsID, Date: date, Currency: "IDR",
+			Amount: 10 * money.One, OriginalAmount: 10 * money.One,
+			Splits: []model.T_expense_split{{CategoryID: cat.ID, Amount: 10 * money.One}},
+		}
+		if err := expenses.Create(&exp); err != nil {
+			t.Fatal(err)
+		}
+		in := model.T_income{
+			UserID: f.owner.ID, WorkspaceID: wsID, Date: date, Currency: "IDR",
+			Amount: 20 * money.One, OriginalAmount: 20 * money.One,
+		}
+		if err := incomes.Create(&in); err != nil {
+			t.Fatal(err)
+		}
+		budget := model.R_budget{UserID: f.owner.ID, WorkspaceID: wsID, CategoryID: cat.ID, Month: "2024-03", Amount: 50 * money.One}
+		if err := budgets.Create(&budget); err != nil {
+			t.Fatal(err)
+		}
+		tmpl := model.M_expense_template{UserID: f.owner.ID, WorkspaceID: wsID, Name: "Lunch", Amount: 5 * money.One, IsActive: true}
+		if err := templates.Create(&tmpl); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, v := range f.outsiders(wsID) {
+			t.Run(v.name, func(t *testing.T) {
+				if _, err := expenses.GetByID(exp.ID, v.userID, v.workspaceID); err == nil {
+					t.Error("expense readable by ID")
+				}
+				if list, _ := expenses.GetByDate(v.userID, v.workspaceID, date); containsID(list, exp.ID, func(e model.T_expense) uint { return e.ID }) {
+					t.Error("expense listed by GetByDate")
+				}
+				if list, _ := expenses.Search(v.userID, v.workspaceID, "", nil, nil, nil); containsID(list, exp.ID, func(e model.T_expense) uint { return e.ID }) {
+					t.Error("expense listed by Search")
+				}
+				if _, err := incomes.GetByID(in.ID, v.userID, v.workspaceID); err == nil {
+					t.Error("income readable by ID")
+				}
+				if list, _ := incomes.GetByDate(v.userID, v.workspaceID, date); containsID(list, in.ID, func(i model.T_income) uint { return i.ID }) {
+					t.Error("income listed by GetByDate")
+				}
+				if _, err := categories.GetByID(v.userID, v.workspaceID, cat.ID); err == nil {
+					t.Error("category readable by ID")
+				}
+				if list, _ := categories.GetByIDs(v.userID, v.workspaceID, []uint{cat.ID}); len(list) != 0 {
+					t.Error("category readable through GetByIDs")
+				}
+				if list, _ := categories.GetAll(v.userID, v.workspaceID, ""); containsID(list, cat.ID, func(c model.M_category) uint { return c.ID }) {
+					t.Error("category listed by GetAll")
+				}
+				if list, _ := budgets.GetByUserAndMonth(v.userID, v.workspaceID, "2024-03"); containsID(list, budget.ID, func(b model.R_budget) uint { return b.ID }) {
+					t.Error("budget listed by GetByUserAndMonth")
+				}
+				if list, _ := templates.GetByUser(v.userID, v.workspaceID); containsID(list, tmpl.ID, func(m model.M_expense_template) uint { return m.ID }) {
+					t.Error("template listed by GetByUser")
+				}
+
+				if err := expenses.Delete(exp.ID, v.userID, v.workspaceID); err != nil {
+					t.Fatal(err)
+				}
+				if err := incomes.Delete(in.ID, v.userID, v.workspaceID); err != nil {
+					t.Fatal(err)
+				}
+				if err := categories.Delete(v.userID, v.workspaceID, cat.ID); err != nil {
+					t.Fatal(err)
+				}
+				if deleted, err := budgets.DeleteByCategory(v.userID, v.workspaceID, cat.ID, ""); err != nil || len(deleted) != 0 {
+					t.Errorf("DeleteByCategory deleted %d budgets, err %v", len(deleted), err)
+				}
+				if err := templates.Delete(tmpl.ID, v.userID, v.workspaceID); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+
+		// Everything is still there for its own workspace
+		if _, err := expenses.GetByID(exp.ID, f.owner.ID, wsID); err != nil {
+			t.Errorf("workspace %d: expense gone: %v", wsID, err)
+		}
+		if _, err := incomes.GetByID(in.ID, f.owner.ID, wsID); err != nil {
+			t.Errorf("workspace %d: income gone: %v", wsID, err)
+		}
+		if _, err := categories.GetByID(f.owner.ID, wsID, cat.ID); err != nil {
+			t.Errorf("workspace %d: category gone: %v", wsID, err)
+		}
+		if list, _ := budgets.GetByUserAndMonth(f.owner.ID, wsID, "2024-03"); !containsID(list, budget.ID, func(b model.R_budget) uint { return b.ID }) {
+			t.Errorf("workspace %d: budget gone", wsID)
+		}
+		if list, _ := templates.GetByUser(f.owner.ID, wsID); !containsID(list, tmpl.ID, func(m model.M_expense_template) uint { return m.ID }) {
+			t.Errorf("workspace %d: template gone", wsID)
+		}
+	}
+}
+
+func containsID[T any](list []T, id uint, idOf func(T) uint) bool {
+	for _, item := range list {
+		if idOf(item) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package route
 
 import (
+	"time"
+
+	appmiddleware "expenses-tracker/src/middleware"
 	"expenses-tracker/src/registry"
 
 	"github.com/labstack/echo/v4"
@@ -13,11 +16,30 @@ func SetupRoutes(e *echo.Echo, reg *registry.Registry) {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
+	// Client addresses come from X-Forwarded-For only when set by a proxy on
+	// a private network, such as the frontend's nginx
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+
+	// Per-address request limits for the unauthenticated auth endpoints
+	signupLimit := appmiddleware.RateLimit(10, time.Hour)
+	loginLimit := appmiddleware.RateLimit(20, time.Minute)
+	refreshLimit := appmiddleware.RateLimit(60, time.Minute)
+	emailLimit := appmiddleware.RateLimit(10, time.Hour)
+
 	// Public routes (no authentication required)
 	api := e.Group("/api/apps")
-	api.POST("/auth/signup", reg.AuthHandler.Signup)
-	api.POST("/auth/login", reg.AuthHandler.Login)
-	api.POST("/auth/refresh", reg.AuthHandler.RefreshToken)
+	api.POST("/auth/signup", reg.AuthHandler.Signup, signupLimit)
+	api.POST("/auth/login", reg.AuthHandler.Login, loginLimit)
+	api.GET("/auth/jwks.json", reg.AuthHandler.JWKS)
+	api.GET("/auth/oidc", reg.OIDCHandler.GetConfig)
+	api.GET("/auth/oidc/login", reg.OIDCHandler.Login, loginLimit)
+	api.GET("/auth/oidc/callback", reg.OIDCHandler.Callback, loginLimit)
+	api.POST("/auth/oidc/exchange", reg.OIDCHandler.Exchange, loginLimit)
+	api.POST("/auth/login/2fa", reg.AuthHandler.LoginTwoFactor, loginLimit)
+	api.POST("/auth/refresh", reg.AuthHandler.RefreshToken, refreshLimit)
+	api.POST("/auth/verify-email", reg.AuthHandler.VerifyEmail, loginLimit)
+	api.POST("/auth/forgot-password", reg.AuthHandler.ForgotPassword, emailLimit)
+	api.POST("/auth/reset-password", reg.AuthHandler.ResetPassword, loginLimit)
 
 	// Protected routes (authentication required)
 	protected := api.Group("")
@@ -28,6 +50,29 @@ func SetupRoutes(e *echo.Echo, reg *registry.Registry) {
 	protected.PUT("/auth/profile", reg.AuthHandler.UpdateProfile)
 	protected.PUT("/auth/currency", reg.AuthHandler.UpdateCurrency)
 	protected.PUT("/auth/password", reg.AuthHandler.ChangePassword)
+	protected.POST("/auth/verify-email/resend", reg.AuthHandler.ResendVerification)
+	protected.GET("/auth/sessions", reg.AuthHandler.GetSessions)
+	protected.DELETE("/auth/sessions", reg.AuthHandler.RevokeOtherSessions)
+	protected.DELETE("/auth/sessions/:id", reg.AuthHandler.RevokeSession)
+
+	// Personal data export and account deletion
+	protected.GET("/auth/account/export", reg.PersonalDataHandler.ExportData)
+	protected.POST("/auth/account/deletion", reg.PersonalDataHandler.RequestDeletion)
+	protected.DELETE("/auth/account/deletion", reg.PersonalDataHandler.CancelDeletion)
+
+	// Two-factor authentication routes
+	protected.GET("/auth/2fa", reg.TwoFactorHandler.GetStatus)
+	protected.POST("/auth/2fa/setup", reg.TwoFactorHandler.Setup)
+	protected.POST("/auth/2fa/enable", reg.TwoFactorHandler.Enable)
+	protected.POST("/auth/2fa/disable", reg.TwoFactorHandler.Disable)
+	protected.POST("/auth/2fa/recovery-codes", reg.TwoFactorHandler.RegenerateRecoveryCodes)
+	protected.GET("/auth/identities", reg.OIDCHandler.GetIdentities)
+	protected.DELETE("/auth/identities/:id", reg.OIDCHandler.DeleteIdentity)
+
+	// Personal access token routes
+	protected.GET("/tokens", reg.AccessTokenHandler.GetTokens)
+	protected.POST("/tokens", reg.AccessTokenHandler.CreateToken)
+	protected.DELETE("/tokens/:id", reg.AccessTokenHandler.RevokeToken)
 
 	// Expense routes
 	protected.POST("/expenses", reg.ExpenseHandler.CreateExpense)
@@ -58,21 +103,88 @@ func SetupRoutes(e *echo.Echo, reg *registry.Registry) {
 	protected.PUT("/income/:id", reg.IncomeHandler.UpdateIncome)
 	protected.DELETE("/income/:id", reg.IncomeHandler.DeleteIncome)
 
+	// Account routes
+	protected.GET("/accounts", reg.AccountHandler.GetAccounts)
+	protected.GET("/accounts/balances", reg.AccountHandler.GetBalances)
+	protected.GET("/accounts/:id/ledger", reg.AccountHandler.GetLedger)
+	protected.POST("/accounts", reg.AccountHandler.CreateAccount)
+	protected.PUT("/accounts/:id", reg.AccountHandler.UpdateAccount)
+	protected.DELETE("/accounts/:id", reg.AccountHandler.DeleteAccount)
+
+	// Transfer routes
+	protected.GET("/transfers", reg.TransferHandler.GetTransfers)
+	protected.POST("/transfers", reg.TransferHandler.CreateTransfer)
+	protected.DELETE("/transfers/:id", reg.TransferHandler.DeleteTransfer)
+
 	// Budget routes
 	protected.GET("/budgets", reg.BudgetHandler.GetBudgets)
 	protected.POST("/budgets", reg.BudgetHandler.CreateBudget)
 	protected.POST("/budgets/copy", reg.BudgetHandler.CopyBudgets)
 	protected.GET("/budgets/latest", reg.BudgetHandler.GetLatestBudgetMonth)
+	protected.GET("/budgets/report", reg.BudgetHandler.GetReport)
+	protected.GET("/budgets/envelopes/:categoryId", reg.BudgetHandler.GetEnvelope)
+	protected.PUT("/budgets/rollover/:categoryId", reg.BudgetHandler.SetRollover)
+	protected.GET("/budgets/thresholds", reg.BudgetAlertHandler.GetThresholds)
+	protected.PUT("/budgets/thresholds/:budgetId", reg.BudgetAlertHandler.SetThresholds)
+	protected.GET("/budgets/alerts", reg.BudgetAlertHandler.GetAlerts)
 	protected.DELETE("/budgets/:categoryId", reg.BudgetHandler.DeleteBudget)
 
+	// Recurring transaction routes
+	protected.GET("/recurring", reg.RecurringHandler.GetRules)
+	protected.POST("/recurring", reg.RecurringHandler.CreateRule)
+	protected.GET("/recurring/:id/preview", reg.RecurringHandler.PreviewRule)
+	protected.POST("/recurring/:id/pause", reg.RecurringHandler.PauseRule)
+	protected.POST("/recurring/:id/resume", reg.RecurringHandler.ResumeRule)
+	protected.POST("/recurring/:id/skip", reg.RecurringHandler.SkipOccurrence)
+	protected.DELETE("/recurring/:id", reg.RecurringHandler.DeleteRule)
+
+	// Import routes
+	protected.POST("/import/csv", reg.ImportHandler.ImportCSV)
+
+	// Export routes
+	protected.GET("/export/:dataset", reg.ExportHandler.Export)
+
+	// Audit log routes
+	protected.GET("/audit", reg.AuditHandler.GetAuditLog)
+
+	// Trash routes
+	protected.GET("/trash", reg.TrashHandler.GetTrash)
+	protected.POST("/trash/:type/:id/restore", reg.TrashHandler.RestoreItem)
+	protected.DELETE("/trash/:type/:id", reg.TrashHandler.PurgeItem)
+
+	// Notification routes
+	protected.GET("/notifications", reg.NotificationHandler.GetNotifications)
+	protected.POST("/notifications/read-all", reg.NotificationHandler.MarkAllRead)
+	protected.POST("/notifications/:id/read", reg.NotificationHandler.MarkRead)
+	protected.GET("/notifications/channels", reg.NotificationHandler.GetChannels)
+	protected.POST("/notifications/channels", reg.NotificationHandler.CreateChannel)
+	protected.PUT("/notifications/channels/:id", reg.NotificationHandler.UpdateChannel)
+	protected.DELETE("/notifications/channels/:id", reg.NotificationHandler.DeleteChannel)
+	protected.POST("/notifications/channels/:id/test", reg.NotificationHandler.TestChannel)
+
+	// Exchange rate routes
+	protected.GET("/exchange-rates", reg.ExchangeRateHandler.GetRates)
+	protected.PUT("/exchange-rates", reg.ExchangeRateHandler.SetRate)
+	protected.POST("/exchange-rates/import", reg.ExchangeRateHandler.ImportRates)
+	protected.DELETE("/exchange-rates/:id", reg.ExchangeRateHandler.DeleteRate)
+
 	// Quick amounts routes
 	protected.GET("/quick-amounts", reg.QuickAmountHandler.GetQuickAmounts)
 	protected.PUT("/quick-amounts", reg.QuickAmountHandler.SetQuickAmounts)
 
 	// Workspace routes
 	protected.GET("/workspaces", reg.WorkSpaceHandler.List)
+	protected.GET("/workspaces/invitations", reg.MemberHandler.ListInvitations)
 	protected.GET("/workspaces/:id", reg.WorkSpaceHandler.Get)
 	protected.POST("/workspaces", reg.WorkSpaceHandler.Create)
 	protected.PUT("/workspaces/:id", reg.WorkSpaceHandler.Update)
 	protected.DELETE("/workspaces/:id", reg.WorkSpaceHandler.Delete)
+
+	// Workspace member routes
+	protected.GET("/workspaces/:id/members", reg.MemberHandler.List)
+	protected.POST("/workspaces/:id/members", reg.MemberHandler.Invite)
+	protected.POST("/workspaces/:id/members/accept", reg.MemberHandler.Accept)
+	protected.POST("/workspaces/:id/members/decline", reg.MemberHandler.Decline)
+	protected.PUT("/workspaces/:id/members/:memberId", reg.MemberHandler.UpdateRole)
+	protected.DELETE("/workspaces/:id/members/:memberId", reg.MemberHandler.Remove)
 }
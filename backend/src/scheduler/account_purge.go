@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"expenses-tracker/src/repository"
+)
+
+// AccountPurgeScheduler periodically purges the accounts whose deletion
+// grace period has ended.
+type AccountPurgeScheduler struct {
+	dataRepo *repository.PersonalDataRepository
+	interval time.Duration
+}
+
+func NewAccountPurgeScheduler(dataRepo *repository.PersonalDataRepository, interval time.Duration) *AccountPurgeScheduler {
+	return &AccountPurgeScheduler{dataRepo: dataRepo, interval: interval}
+}
+
+// Start runs the purge immediately and then on every tick until ctx is cancelled.
+func (s *AccountPurgeScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(time.Now()); err != nil {
+			log.Println("Account purge:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges every account due as of now. One account failing does not
+// hold up the others; it is retried on the next run.
+func (s *AccountPurgeScheduler) RunOnce(now time.Time) error {
+	ids, err := s.dataRepo.DueForPurge(now)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.dataRepo.Purge(id); err != nil {
+			log.Printf("Account purge: user %d: %v", id, err)
+			continue
+		}
+		log.Printf("Account purge: purged user %d", id)
+	}
+	return nil
+}
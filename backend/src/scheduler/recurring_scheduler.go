@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"expenses-tracker/src/notify"
+	"expenses-tracker/src/repository"
+)
+
+// RecurringScheduler periodically turns due recurring rules into expense and
+// income rows, and checks the budgets the new expenses count against.
+type RecurringScheduler struct {
+	repo     *repository.RecurringRepository
+	alerter  *notify.BudgetAlerter
+	interval time.Duration
+}
+
+func NewRecurringScheduler(repo *repository.RecurringRepository, alerter *notify.BudgetAlerter, interval time.Duration) *RecurringScheduler {
+	return &RecurringScheduler{repo: repo, alerter: alerter, interval: interval}
+}
+
+// Start runs the scheduler immediately and then on every tick until ctx is cancelled.
+func (s *RecurringScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(time.Now()); err != nil {
+			log.Println("Recurring scheduler:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce materializes every occurrence due on or before now.
+func (s *RecurringScheduler) RunOnce(now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	ids, err := s.repo.ListDueIDs(today)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		m, err := s.repo.Materialize(id, today)
+		if err != nil {
+			log.Printf("Recurring scheduler: rule %d: %v", id, err)
+			continue
+		}
+		if m.Created > 0 {
+			log.Printf("Recurring scheduler: rule %d created %d transaction(s)", id, m.Created)
+		}
+		if len(m.ExpenseDates) > 0 {
+			s.alerter.Check(m.UserID, m.WorkspaceID, m.Currency, m.ExpenseDates...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"expenses-tracker/src/repository"
+)
+
+// TokenCleanupScheduler periodically purges refresh tokens and emailed user
+// tokens that can no longer be used, along with stale sign-in throttles.
+type TokenCleanupScheduler struct {
+	refreshRepo   *repository.RefreshTokenRepository
+	userTokenRepo *repository.UserTokenRepository
+	throttleRepo  *repository.LoginThrottleRepository
+	interval      time.Duration
+}
+
+func NewTokenCleanupScheduler(refreshRepo *repository.RefreshTokenRepository, userTokenRepo *repository.UserTokenRepository, throttleRepo *repository.LoginThrottleRepository, interval time.Duration) *TokenCleanupScheduler {
+	return &TokenCleanupScheduler{refreshRepo: refreshRepo, userTokenRepo: userTokenRepo, throttleRepo: throttleRepo, interval: interval}
+}
+
+// Start runs the cleanup immediately and then on every tick until ctx is cancelled.
+func (s *TokenCleanupScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(time.Now()); err != nil {
+			log.Println("Token cleanup:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes the tokens that can no longer be used as of now.
+func (s *TokenCleanupScheduler) RunOnce(now time.Time) error {
+	purged, err := s.refreshRepo.PurgeExpired(now)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Token cleanup: purged %d refresh token(s)", purged)
+	}
+
+	purged, err = s.userTokenRepo.PurgeExpired(now)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Token cleanup: purged %d email token(s)", purged)
+	}
+
+	// Counters idle for a day have long been forgotten by every policy
+	if _, err := s.throttleRepo.PurgeStale(now.Add(-24 * time.Hour)); err != nil {
+		return err
+	}
+	return nil
+}
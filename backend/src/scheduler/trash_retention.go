@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"expenses-tracker/src/repository"
+)
+
+// TrashRetentionScheduler periodically deletes for good the expenses, income
+// and categories that have been in the trash for longer than the retention
+// period.
+type TrashRetentionScheduler struct {
+	trashRepo *repository.TrashRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashRetentionScheduler(trashRepo *repository.TrashRepository, retention, interval time.Duration) *TrashRetentionScheduler {
+	return &TrashRetentionScheduler{trashRepo: trashRepo, retention: retention, interval: interval}
+}
+
+// Start runs the purge immediately and then on every tick until ctx is cancelled.
+func (s *TrashRetentionScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(time.Now()); err != nil {
+			log.Println("Trash retention:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges everything deleted more than the retention period before now.
+func (s *TrashRetentionScheduler) RunOnce(now time.Time) error {
+	purged, err := s.trashRepo.PurgeDeletedBefore(now.Add(-s.retention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Trash retention: purged %d item(s)", purged)
+	}
+	return nil
+}
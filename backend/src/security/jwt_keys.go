@@ -0,0 +1,220 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"expenses-tracker/src/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys.
+const minRSABits = 2048
+
+// ErrUnknownKey is returned for tokens whose kid names no key of the set,
+// such as tokens signed before a key was removed.
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet signs JWTs with one private key and verifies them with any of its
+// keys, picked by the token's kid header. Only RS256 and EdDSA are used.
+type KeySet struct {
+	signer     crypto.PrivateKey
+	signingKey *jwtKey
+	keys       map[string]*jwtKey
+}
+
+// NewKeySet loads the keys configured in cfg. Without a key directory it
+// generates an Ed25519 key for this process only, unless running in
+// production, where it fails instead.
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	if cfg.KeysDir == "" {
+		if config.IsProduction() {
+			return nil, errors.New("JWT_KEYS_DIR must be set in production")
+		}
+		log.Println("JWT_KEYS_DIR not set; signing tokens with a temporary key that is lost on restart")
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ks := &KeySet{keys: map[string]*jwtKey{}}
+		ks.signer = private
+		ks.signingKey = &jwtKey{id: "dev", method: jwt.SigningMethodEdDSA, public: private.Public()}
+		ks.keys[ks.signingKey.id] = ks.signingKey
+		return ks, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.KeysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	ks := &KeySet{keys: map[string]*jwtKey{}}
+	signers := map[string]crypto.PrivateKey{}
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, private, err := parseJWTKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %s: %w", file, err)
+		}
+		ks.keys[id] = key
+		if private != nil {
+			signers[id] = private
+		}
+	}
+
+	signingID := cfg.SigningKeyID
+	if signingID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_ID must name one of the %d private keys in %s", len(signers), cfg.KeysDir)
+		}
+		for id := range signers {
+			signingID = id
+		}
+	}
+	private, ok := signers[signingID]
+	if !ok {
+		return nil, fmt.Errorf("no private key %s.pem in %s", signingID, cfg.KeysDir)
+	}
+	ks.signer = private
+	ks.signingKey = ks.keys[signingID]
+	log.Printf("Signing tokens with key %s (%s), %d verification keys", signingID, ks.signingKey.method.Alg(), len(ks.keys))
+	return ks, nil
+}
+
+// parseJWTKey reads a PEM encoded RSA or Ed25519 key. The private key is
+// nil for public key files.
+func parseJWTKey(id string, data []byte) (*jwtKey, crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM data")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, nil, fmt.Errorf("RSA key shorter than %d bits", minRSABits)
+		}
+		return &jwtKey{id: id, method: jwt.SigningMethodRS256, public: &k.PublicKey}, k, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, nil, fmt.Errorf("RSA key shorter than %d bits", minRSABits)
+		}
+		return &jwtKey{id: id, method: jwt.SigningMethodRS256, public: k}, nil, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{id: id, method: jwt.SigningMethodEdDSA, public: k.Public()}, k, nil
+	case ed25519.PublicKey:
+		return &jwtKey{id: id, method: jwt.SigningMethodEdDSA, public: k}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+}
+
+// Sign encodes claims as a JWT signed with the current signing key.
+func (ks *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingKey.method, claims)
+	token.Header["kid"] = ks.signingKey.id
+	return token.SignedString(ks.signer)
+}
+
+// Parse verifies a JWT and returns its claims. Errors are *jwt.ValidationError
+// as from jwt.Parse, except ErrUnknownKey.
+func (ks *KeySet) Parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// Never let the token choose the algorithm
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, jwt.ErrInvalidKeyType
+		}
+		return key.public, nil
+	})
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner == ErrUnknownKey {
+		return nil, ErrUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.NewValidationError("invalid token claims", jwt.ValidationErrorClaimsInvalid)
+	}
+	return claims, nil
+}
+
+// JWK is one public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS lists the public half of every key, sorted by kid.
+func (ks *KeySet) JWKS() []JWK {
+	keys := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch k := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		keys = append(keys, jwk)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
@@ -0,0 +1,259 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expenses-tracker/src/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// writeKey stores der as <dir>/<id>.pem in a PEM block of the given type.
+func writeKey(t *testing.T, dir, id, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ed25519Key(t *testing.T) (ed25519.PublicKey, []byte) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, der
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "7", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	_, current := ed25519Key(t)
+	writeKey(t, dir, "2026-01", "PRIVATE KEY", current)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2025-06", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	// The retired key only verifies; its private half lives elsewhere
+	retiredPublic, retired := ed25519Key(t)
+	publicDER, err := x509.MarshalPKIXPublicKey(retiredPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2024-01", "PUBLIC KEY", publicDER)
+	oldDir := t.TempDir()
+	writeKey(t, oldDir, "2024-01", "PRIVATE KEY", retired)
+
+	if _, err := NewKeySet(config.JWTConfig{KeysDir: dir}); err == nil {
+		t.Error("two private keys without JWT_SIGNING_KEY_ID were accepted")
+	}
+	if _, err := NewKeySet(config.JWTConfig{KeysDir: dir, SigningKeyID: "2024-01"}); err == nil {
+		t.Error("a public key was accepted as the signing key")
+	}
+	ks, err := NewKeySet(config.JWTConfig{KeysDir: dir, SigningKeyID: "2026-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := ks.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2026-01" || parsed.Header["alg"] != "EdDSA" {
+		t.Errorf("signed token header = %v", parsed.Header)
+	}
+	if got, err := ks.Parse(token); err != nil || got["sub"] != "7" {
+		t.Errorf("Parse of a current token = %v, %v", got, err)
+	}
+
+	// The RSA key signs once it is picked, and the set verifies by kid
+	rsaSet, err := NewKeySet(config.JWTConfig{KeysDir: dir, SigningKeyID: "2025-06"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ = rsaSet.Sign(claims())
+	if _, err := ks.Parse(token); err != nil {
+		t.Errorf("Parse of an RS256 token: %v", err)
+	}
+
+	old, err := NewKeySet(config.JWTConfig{KeysDir: oldDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ = old.Sign(claims())
+	if _, err := ks.Parse(token); err != nil {
+		t.Errorf("Parse of a token from the retired key: %v", err)
+	}
+	if _, err := old.Parse(mustSign(t, ks)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Parse of a token from a key the set lacks = %v, want ErrUnknownKey", err)
+	}
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	token, _ = ks.Sign(expired)
+	if _, err := ks.Parse(token); err == nil {
+		t.Error("expired token accepted")
+	}
+}
+
+func mustSign(t *testing.T, ks *KeySet) string {
+	t.Helper()
+	token, err := ks.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestKeySetRefusesOtherAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	public, private := ed25519Key(t)
+	writeKey(t, dir, "main", "PRIVATE KEY", private)
+	ks, err := NewKeySet(config.JWTConfig{KeysDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 keyed with the public key, the classic algorithm confusion
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	hs.Header["kid"] = "main"
+	hsToken, err := hs.SignedString([]byte(public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims())
+	none.Header["kid"] = "main"
+	noneToken, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"HS256": hsToken, "none": noneToken} {
+		if got, err := ks.Parse(token); err == nil {
+			t.Errorf("%s token accepted: %v", name, got)
+		}
+	}
+
+	unnamed := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims())
+	token, err := unnamed.SignedString(ed25519.PrivateKey(mustParsePKCS8(t, private)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Parse of a token without kid = %v, want ErrUnknownKey", err)
+	}
+}
+
+func mustParsePKCS8(t *testing.T, der []byte) ed25519.PrivateKey {
+	t.Helper()
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.(ed25519.PrivateKey)
+}
+
+func TestParseJWTKey(t *testing.T) {
+	short, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string][]byte{
+		"not PEM":     []byte("secret"),
+		"certificate": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}),
+		"short RSA":   pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(short)}),
+		"ECDSA":       pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}),
+		"corrupt":     pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}),
+	}
+	for name, data := range invalid {
+		if _, _, err := parseJWTKey("k", data); err == nil {
+			t.Errorf("%s key accepted", name)
+		}
+	}
+
+	public, private := ed25519Key(t)
+	key, signer, err := parseJWTKey("k", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}))
+	if err != nil || signer == nil || key.method != jwt.SigningMethodEdDSA || !public.Equal(key.public) {
+		t.Errorf("Ed25519 private key = %+v, %v", key, err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	public, private := ed25519Key(t)
+	writeKey(t, dir, "b-ed", "PRIVATE KEY", private)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "a-rsa", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	ks, err := NewKeySet(config.JWTConfig{KeysDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := ks.JWKS()
+	if len(keys) != 2 {
+		t.Fatalf("JWKS = %+v", keys)
+	}
+	want := []JWK{
+		{Kty: "RSA", Kid: "a-rsa", Use: "sig", Alg: "RS256",
+			N: base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()), E: "AQAB"},
+		{Kty: "OKP", Kid: "b-ed", Use: "sig", Alg: "EdDSA", Crv: "Ed25519",
+			X: base64.RawURLEncoding.EncodeToString(public)},
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("JWKS[%d] = %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestKeySetWithoutKeysDir(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	if _, err := NewKeySet(config.JWTConfig{}); err == nil {
+		t.Error("production started without JWT_KEYS_DIR")
+	}
+
+	t.Setenv("APP_ENV", "development")
+	ks, err := NewKeySet(config.JWTConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(mustSign(t, ks)); err != nil {
+		t.Errorf("Parse with the temporary key: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package security
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"expenses-tracker/src/model"
+	"expenses-tracker/src/repository"
+)
+
+// Policy decides how long a key must wait after failed sign-in attempts. The
+// first FreeAttempts failures cost nothing, each further one doubles the
+// wait starting at one second (up to MaxDelay), and LockoutAfter failures lock
+// the key for LockoutFor. Failures are forgotten after Window without one.
+type Policy struct {
+	FreeAttempts int
+	MaxDelay     time.Duration
+	LockoutAfter int
+	LockoutFor   time.Duration
+	Window       time.Duration
+}
+
+var (
+	// AccountPolicy protects one account from guessing spread over many addresses.
+	AccountPolicy = Policy{FreeAttempts: 3, MaxDelay: 5 * time.Minute, LockoutAfter: 10, LockoutFor: 15 * time.Minute, Window: time.Hour}
+	// IPPolicy slows down one address trying many accounts.
+	IPPolicy = Policy{FreeAttempts: 10, MaxDelay: 5 * time.Minute, LockoutAfter: 50, LockoutFor: time.Hour, Window: time.Hour}
+)
+
+// wait returns how long after the last failure the next attempt is refused,
+// and whether that wait is a lockout.
+func (p Policy) wait(failures int) (time.Duration, bool) {
+	switch {
+	case failures >= p.LockoutAfter:
+		return p.LockoutFor, true
+	case failures < p.FreeAttempts:
+		return 0, false
+	}
+	shift := failures - p.FreeAttempts
+	if shift > 30 {
+		return p.MaxDelay, false
+	}
+	if d := time.Second << shift; d < p.MaxDelay {
+		return d, false
+	}
+	return p.MaxDelay, false
+}
+
+// retryAfter returns how long t must still wait at now; zero means it may try.
+func (p Policy) retryAfter(t *model.M_login_throttle, now time.Time) time.Duration {
+	if t.Failures == 0 || now.Sub(t.LastFailureAt) > p.Window {
+		return 0
+	}
+	wait, _ := p.wait(t.Failures)
+	if left := t.LastFailureAt.Add(wait).Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// LoginGuard throttles sign-in attempts per account and per client address.
+type LoginGuard struct {
+	repo      *repository.LoginThrottleRepository
+	auditRepo *repository.AuditRepository
+}
+
+func NewLoginGuard(repo *repository.LoginThrottleRepository, auditRepo *repository.AuditRepository) *LoginGuard {
+	return &LoginGuard{repo: repo, auditRepo: auditRepo}
+}
+
+func accountKey(email string) string {
+	return repository.AccountThrottleKey(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// waits lists the wait after each failure count from 1 to LockoutAfter, the
+// last one applying to any more, in the form LoginThrottleRepository.Reserve
+// takes.
+func (p Policy) waits() []time.Duration {
+	waits := make([]time.Duration, p.LockoutAfter)
+	for i := range waits {
+		waits[i], _ = p.wait(i + 1)
+	}
+	return waits
+}
+
+// Attempt is a sign-in attempt let through by LoginGuard.Begin. It counts as
+// a failure from the start, so parallel guesses cannot all slip past one
+// check; Refund takes it back when the credentials turn out right.
+type Attempt struct {
+	guard     *LoginGuard
+	email, ip string
+	account   int // failures of the account, this attempt included
+	client    int // failures of the address, this attempt included
+}
+
+// Begin admits a sign-in to email from ip, or returns how long it has to
+// wait while the account or address is backing off.
+func (g *LoginGuard) Begin(email, ip string, now time.Time) (*Attempt, time.Duration, error) {
+	client, wait, err := g.reserve(IPPolicy, ipKey(ip), now)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+	account, wait, err := g.reserve(AccountPolicy, accountKey(email), now)
+	if err != nil || wait > 0 {
+		if err := g.repo.Refund(ipKey(ip)); err != nil {
+			log.Printf("Login guard: refund %s: %v", ipKey(ip), err)
+		}
+		return nil, wait, err
+	}
+	return &Attempt{guard: g, email: email, ip: ip, account: account, client: client}, 0, nil
+}
+
+// reserve counts an attempt for key under p, or returns how long key still
+// has to wait.
+func (g *LoginGuard) reserve(p Policy, key string, now time.Time) (int, time.Duration, error) {
+	failures, ok, err := g.repo.Reserve(key, now, p.Window, p.waits())
+	if err != nil || ok {
+		return failures, 0, err
+	}
+	t, err := g.repo.Get(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if wait := p.retryAfter(t, now); wait > 0 {
+		return 0, wait, nil
+	}
+	// The wait ran out between the two statements
+	return 0, time.Second, nil
+}
+
+// Failed records that the attempt failed, writing an audit entry when it
+// locked the account or address. Attempts are refused while a key is locked,
+// so a failure past LockoutAfter always starts a new lockout. userID is the
+// account signed into, or nil when the email is unknown.
+func (a *Attempt) Failed(userID *uint, now time.Time) {
+	if wait, locked := AccountPolicy.wait(a.account); locked {
+		a.guard.audit(&model.T_audit_log{UserID: userID, Action: model.AuditLockout, Entity: model.AuditUser, EntityID: userID, IP: a.ip},
+			a.account, now.Add(wait))
+	}
+	if wait, locked := IPPolicy.wait(a.client); locked {
+		a.guard.audit(&model.T_audit_log{Action: model.AuditLockout, Entity: model.AuditIP, IP: a.ip},
+			a.client, now.Add(wait))
+	}
+}
+
+// Refund takes back the failure counted for a right attempt. With two-factor
+// sign-in a right password alone refunds without resetting the account, so
+// the password cannot be used to clear failed codes.
+func (a *Attempt) Refund() error {
+	if err := a.guard.repo.Refund(ipKey(a.ip)); err != nil {
+		return err
+	}
+	return a.guard.repo.Refund(accountKey(a.email))
+}
+
+// Succeeded clears the account's failures. The address keeps its count so
+// signing into one account cannot reset guessing at others.
+func (g *LoginGuard) Succeeded(email string) error {
+	return g.repo.Reset(accountKey(email))
+}
+
+func (g *LoginGuard) audit(entry *model.T_audit_log, failures int, until time.Time) {
+	after := map[string]interface{}{"failures": failures, "lockedUntil": until}
+	if err := g.auditRepo.Record(entry, nil, after); err != nil {
+		log.Printf("Login guard: audit %s lockout: %v", entry.Entity, err)
+	}
+}
+
+// RetryMessage phrases a wait for the client.
+func RetryMessage(wait time.Duration) string {
+	seconds := int(wait.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("Too many failed attempts, try again in %d seconds", seconds)
+}
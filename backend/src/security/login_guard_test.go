@@ -0,0 +1,20 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyWaits(t *testing.T) {
+	p := Policy{FreeAttempts: 2, MaxDelay: 3 * time.Second, LockoutAfter: 6, LockoutFor: time.Hour, Window: time.Hour}
+	want := []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, time.Hour}
+	got := p.waits()
+	if len(got) != len(want) {
+		t.Fatalf("waits = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wait after %d failures = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
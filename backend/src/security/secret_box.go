@@ -0,0 +1,99 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"expenses-tracker/src/config"
+)
+
+// sealedPrefix marks values sealed by a SecretBox, so values stored before
+// encryption was introduced can be told apart.
+const sealedPrefix = "v1:"
+
+// ErrSecretUnreadable is returned for sealed values that do not decrypt with
+// the configured key, or that belong to another user.
+var ErrSecretUnreadable = errors.New("secret cannot be decrypted")
+
+// SecretBox encrypts per-user secrets such as TOTP seeds with AES-256-GCM.
+// Each value is bound to its user, so a sealed secret copied onto another
+// user's row does not open.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox uses the key configured in cfg. Without one it generates a key
+// for this process only, unless running in production, where it fails
+// instead.
+func NewSecretBox(cfg config.TOTPConfig) (*SecretBox, error) {
+	var key []byte
+	if cfg.EncryptionKey == "" {
+		if config.IsProduction() {
+			return nil, errors.New("TOTP_ENCRYPTION_KEY must be set in production")
+		}
+		log.Println("TOTP_ENCRYPTION_KEY not set; encrypting two-factor secrets with a temporary key, enrollments will not survive a restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		key, err = base64.StdEncoding.DecodeString(cfg.EncryptionKey)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("TOTP_ENCRYPTION_KEY must be 32 bytes in base64")
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// IsSealed reports whether value was produced by Seal rather than stored in
+// the clear.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Seal encrypts secret for userID.
+func (b *SecretBox) Seal(userID uint, secret string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), userData(userID))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value Seal returned for userID.
+func (b *SecretBox) Open(userID uint, value string) (string, error) {
+	if !IsSealed(value) {
+		return "", ErrSecretUnreadable
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", ErrSecretUnreadable
+	}
+	n := b.aead.NonceSize()
+	secret, err := b.aead.Open(nil, data[:n], data[n:], userData(userID))
+	if err != nil {
+		return "", ErrSecretUnreadable
+	}
+	return string(secret), nil
+}
+
+func userData(userID uint) []byte {
+	return []byte("user:" + strconv.FormatUint(uint64(userID), 10))
+}
@@ -0,0 +1,52 @@
+package security
+
+import (
+	"errors"
+	"testing"
+
+	"expenses-tracker/src/config"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(config.TOTPConfig{EncryptionKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal(7, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || sealed == "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Seal = %q", sealed)
+	}
+	if got, err := box.Open(7, sealed); err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+	if again, _ := box.Seal(7, "JBSWY3DPEHPK3PXP"); again == sealed {
+		t.Error("sealing twice gave the same value")
+	}
+
+	if _, err := box.Open(8, sealed); !errors.Is(err, ErrSecretUnreadable) {
+		t.Errorf("Open for another user = %v, want ErrSecretUnreadable", err)
+	}
+	if _, err := box.Open(7, "JBSWY3DPEHPK3PXP"); !errors.Is(err, ErrSecretUnreadable) {
+		t.Errorf("Open of a plaintext secret = %v, want ErrSecretUnreadable", err)
+	}
+	other, _ := NewSecretBox(config.TOTPConfig{EncryptionKey: "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="})
+	if _, err := other.Open(7, sealed); !errors.Is(err, ErrSecretUnreadable) {
+		t.Errorf("Open with another key = %v, want ErrSecretUnreadable", err)
+	}
+}
+
+func TestNewSecretBoxRejectsBadKeys(t *testing.T) {
+	for _, key := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := NewSecretBox(config.TOTPConfig{EncryptionKey: key}); err == nil {
+			t.Errorf("NewSecretBox(%q) succeeded", key)
+		}
+	}
+	t.Setenv("APP_ENV", "production")
+	if _, err := NewSecretBox(config.TOTPConfig{}); err == nil {
+		t.Error("NewSecretBox without a key succeeded in production")
+	}
+}
@@ -0,0 +1,93 @@
+// Package testdb gives tests a freshly migrated PostgreSQL schema of their
+// own. Tests that need one are skipped unless TEST_DATABASE_URL names a
+// database they may create schemas in, e.g.
+//
+//	TEST_DATABASE_URL="host=localhost user=postgres password=postgres dbname=expenses_test sslmode=disable" go test ./...
+package testdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"expenses-tracker/src/migrate"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a connection to a new, empty schema that is dropped when the
+// test ends, with every migration applied.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	db := OpenEmpty(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return db
+}
+
+// OpenEmpty is Open without the migrations, for tests that build the schema
+// themselves.
+func OpenEmpty(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(b)
+	if err := admin.Exec(`CREATE SCHEMA "` + schema + `"`).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec(`DROP SCHEMA "` + schema + `" CASCADE`)
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// withSearchPath makes every connection opened with dsn, a URL or a list of
+// key=value settings, resolve unqualified names in schema.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"expenses-tracker/src/money"
+)
+
+// dateTokens maps the runs of Y, M and D in a human date format to the Go
+// layout elements they stand for.
+var dateTokens = map[string]string{
+	"YYYY": "2006",
+	"YY":   "06",
+	"MMMM": "January",
+	"MMM":  "Jan",
+	"MM":   "01",
+	"M":    "1",
+	"DD":   "02",
+	"D":    "2",
+}
+
+// DateLayout converts a human date format such as "DD/MM/YYYY" into a Go time
+// layout. Each run of Y, M or D is one token, so "MMM" is a month name rather
+// than "MM" followed by "M". Formats that are already Go layouts are returned
+// unchanged.
+// Example: "YYYY-MM-DD" -> "2006-01-02", "D MMM YYYY" -> "2 Jan 2006"
+func DateLayout(format string) string {
+	if format == "" {
+		return "2006-01-02"
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		j := i + 1
+		for j < len(format) && format[j] == format[i] {
+			j++
+		}
+		run := format[i:j]
+		// A run inside a word, such as the M of "Mon" or "MST" in a Go
+		// layout, is not a token
+		inWord := i > 0 && isWordLetter(format[i-1]) || j < len(format) && isWordLetter(format[j])
+		if layout, ok := dateTokens[run]; ok && !inWord {
+			b.WriteString(layout)
+		} else {
+			b.WriteString(run)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// isWordLetter reports whether c is a letter that cannot start a date token.
+func isWordLetter(c byte) bool {
+	if c == 'Y' || c == 'M' || c == 'D' {
+		return false
+	}
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ParseAmount parses a money amount as written in spreadsheets and bank
+// exports: currency symbols, spaces and thousands separators are ignored and
+// "(12.50)" means -12.50. With decimalComma the roles of "," and "." swap.
+// Example: "Rp 1.234,50" with decimalComma -> 1234.5
+func ParseAmount(s string, decimalComma bool) (money.Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-':
+			negative = !negative
+		case r == ',' && decimalComma, r == '.' && !decimalComma:
+			b.WriteRune('.')
+		}
+	}
+	if b.Len() == 0 {
+		return 0, errors.New("empty amount")
+	}
+
+	v, err := money.Parse(b.String())
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
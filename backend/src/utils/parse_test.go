@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"expenses-tracker/src/money"
+)
+
+func TestDateLayout(t *testing.T) {
+	tests := []struct {
+		format, value, want string
+	}{
+		{"", "2024-03-05", "2024-03-05"},
+		{"YYYY-MM-DD", "2024-03-05", "2024-03-05"},
+		{"DD/MM/YYYY", "05/03/2024", "2024-03-05"},
+		{"MM/DD/YY", "03/05/24", "2024-03-05"},
+		{"YYYYMMDD", "20240305", "2024-03-05"},
+		{"D/M/YYYY", "5/3/2024", "2024-03-05"},
+		{"DD MMM YYYY", "05 Mar 2024", "2024-03-05"},
+		{"MMM D, YYYY", "Mar 5, 2024", "2024-03-05"},
+		{"D MMMM YYYY", "5 March 2024", "2024-03-05"},
+		{"DD-MMM-YY", "05-Mar-24", "2024-03-05"},
+		// Go layouts pass through, including the letters of Mon and MST
+		{"2006-01-02", "2024-03-05", "2024-03-05"},
+		{"Mon, 02 Jan 2006", "Tue, 05 Mar 2024", "2024-03-05"},
+		{"02 Jan 2006 MST", "05 Mar 2024 UTC", "2024-03-05"},
+	}
+	for _, tt := range tests {
+		layout := DateLayout(tt.format)
+		d, err := time.Parse(layout, tt.value)
+		if err != nil {
+			t.Errorf("DateLayout(%q) = %q: parse %q: %v", tt.format, layout, tt.value, err)
+			continue
+		}
+		if got := d.Format("2006-01-02"); got != tt.want {
+			t.Errorf("DateLayout(%q) = %q: parsed %q as %s, want %s", tt.format, layout, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in           string
+		decimalComma bool
+		want         string
+	}{
+		{"12.50", false, "12.5"},
+		{"1,234.56", false, "1234.56"},
+		{"Rp 1.234,50", true, "1234.5"},
+		{"(12.50)", false, "-12.5"},
+		{"-7", false, "-7"},
+		{"$ 0.0001", false, "0.0001"},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in, tt.decimalComma)
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", tt.in, err)
+			continue
+		}
+		want, _ := money.Parse(tt.want)
+		if got != want {
+			t.Errorf("ParseAmount(%q) = %s, want %s", tt.in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1.2.3"} {
+		if _, err := ParseAmount(in, false); err == nil {
+			t.Errorf("ParseAmount(%q) succeeded", in)
+		}
+	}
+}
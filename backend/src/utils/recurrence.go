@@ -0,0 +1,110 @@
+package utils
+
+import "time"
+
+// Recurrence frequencies
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// Day anchors for monthly and yearly recurrences. The default keeps the day of
+// the start date, clamped to the length of shorter months.
+const (
+	AnchorSameDay          = ""
+	AnchorLastDay          = "last_day"
+	AnchorFirstBusinessDay = "first_business_day"
+	AnchorLastBusinessDay  = "last_business_day"
+)
+
+// Recurrence describes a repeating date series starting at Start.
+type Recurrence struct {
+	Frequency string
+	Interval  int
+	Anchor    string
+	Start     time.Time
+}
+
+// IsValidFrequency checks if f is one of the supported frequencies
+func IsValidFrequency(f string) bool {
+	switch f {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// IsValidAnchor checks if a is one of the supported day anchors
+func IsValidAnchor(a string) bool {
+	switch a {
+	case AnchorSameDay, AnchorLastDay, AnchorFirstBusinessDay, AnchorLastBusinessDay:
+		return true
+	}
+	return false
+}
+
+// Occurrence returns the date of the n-th occurrence (0-based) in the series.
+// An anchor that resolves to a day before Start in the first period, such as
+// the first business day of a month that started before Start, gives Start.
+func (r Recurrence) Occurrence(n int) time.Time {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	start := time.Date(r.Start.Year(), r.Start.Month(), r.Start.Day(), 0, 0, 0, 0, time.UTC)
+	step := n * interval
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, step)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*step)
+	}
+
+	var d time.Time
+	if r.Frequency == FrequencyYearly {
+		d = anchorInMonth(start.Year()+step, start.Month(), start.Day(), r.Anchor)
+	} else {
+		// Monthly: count months from the start month so short months do not drift the day
+		months := int(start.Month()) - 1 + step
+		year := start.Year() + months/12
+		month := time.Month(months%12 + 1)
+		d = anchorInMonth(year, month, start.Day(), r.Anchor)
+	}
+	if d.Before(start) {
+		return start
+	}
+	return d
+}
+
+// anchorInMonth resolves a day anchor inside the given month.
+func anchorInMonth(year int, month time.Month, day int, anchor string) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+
+	switch anchor {
+	case AnchorLastDay:
+		return last
+	case AnchorLastBusinessDay:
+		for !isBusinessDay(last) {
+			last = last.AddDate(0, 0, -1)
+		}
+		return last
+	case AnchorFirstBusinessDay:
+		for !isBusinessDay(first) {
+			first = first.AddDate(0, 0, 1)
+		}
+		return first
+	default:
+		if day > last.Day() {
+			return last
+		}
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func isBusinessDay(d time.Time) bool {
+	return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+}
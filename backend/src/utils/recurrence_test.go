@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRecurrenceOccurrence(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Recurrence
+		want []string
+	}{
+		{"daily every 3 days", Recurrence{Frequency: FrequencyDaily, Interval: 3, Start: date("2024-01-30")},
+			[]string{"2024-01-30", "2024-02-02", "2024-02-05"}},
+		{"weekly", Recurrence{Frequency: FrequencyWeekly, Interval: 1, Start: date("2024-02-26")},
+			[]string{"2024-02-26", "2024-03-04", "2024-03-11"}},
+		{"monthly keeps the day after a short month", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Start: date("2024-01-31")},
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"monthly across the year end", Recurrence{Frequency: FrequencyMonthly, Interval: 5, Start: date("2024-10-15")},
+			[]string{"2024-10-15", "2025-03-15", "2025-08-15"}},
+		{"yearly on a leap day", Recurrence{Frequency: FrequencyYearly, Interval: 1, Start: date("2024-02-29")},
+			[]string{"2024-02-29", "2025-02-28", "2026-02-28"}},
+		{"last day", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Anchor: AnchorLastDay, Start: date("2024-01-10")},
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31"}},
+		{"last business day", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Anchor: AnchorLastBusinessDay, Start: date("2024-03-01")},
+			[]string{"2024-03-29", "2024-04-30", "2024-05-31", "2024-06-28"}},
+		{"first business day", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Anchor: AnchorFirstBusinessDay, Start: date("2024-05-01")},
+			[]string{"2024-05-01", "2024-06-03", "2024-07-01"}},
+
+		// The anchor of the first month falls before the start date
+		{"first business day before start", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Anchor: AnchorFirstBusinessDay, Start: date("2024-01-15")},
+			[]string{"2024-01-15", "2024-02-01", "2024-03-01"}},
+		{"last business day before start", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Anchor: AnchorLastBusinessDay, Start: date("2024-08-31")},
+			[]string{"2024-08-31", "2024-09-30", "2024-10-31"}},
+		{"yearly first business day before start", Recurrence{Frequency: FrequencyYearly, Interval: 1, Anchor: AnchorFirstBusinessDay, Start: date("2024-06-20")},
+			[]string{"2024-06-20", "2025-06-02", "2026-06-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for n, want := range tt.want {
+				if got := tt.r.Occurrence(n).Format("2006-01-02"); got != want {
+					t.Errorf("Occurrence(%d) = %s, want %s", n, got, want)
+				}
+				if got := tt.r.Occurrence(n); got.Before(tt.r.Start) {
+					t.Errorf("Occurrence(%d) = %s is before the start", n, got.Format("2006-01-02"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// totpPeriod is the lifetime of one TOTP code in seconds.
+const totpPeriod = 30
+
+var totpOpts = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// NewTOTPKey generates a TOTP secret for account. Authenticator apps list it
+// under issuer.
+func NewTOTPKey(issuer, account string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// MatchTOTP checks code against secret at now, allowing one period of clock
+// skew either way, and returns the time step the code belongs to.
+func MatchTOTP(secret, code string, now time.Time) (int64, bool) {
+	step := now.Unix() / totpPeriod
+	for _, s := range []int64{step, step - 1, step + 1} {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(s*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode reports whether s looks like a TOTP code rather than a recovery code.
+func IsTOTPCode(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns n random codes formatted as xxxxx-xxxxx.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	b := make([]byte, 7)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the separators and case users tend to vary
+// when typing a recovery code back in.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
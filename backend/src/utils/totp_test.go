@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestMatchTOTP(t *testing.T) {
+	// RFC 6238 gives 07081804 at 1111111109, which is step 37037036; the
+	// six-digit code is its last six digits
+	at := time.Unix(1111111109, 0)
+	const step = 37037036
+	tests := []struct {
+		name   string
+		code   string
+		now    time.Time
+		want   int64
+		wantOK bool
+	}{
+		{"current step", "081804", at, step, true},
+		{"start of the step", "081804", time.Unix(step*totpPeriod, 0), step, true},
+		{"one step late", "081804", at.Add(totpPeriod * time.Second), step, true},
+		{"one step early", "081804", at.Add(-totpPeriod * time.Second), step, true},
+		{"two steps late", "081804", at.Add(2 * totpPeriod * time.Second), 0, false},
+		{"two steps early", "081804", at.Add(-2 * totpPeriod * time.Second), 0, false},
+		{"another vector", "287082", time.Unix(59, 0), 1, true},
+		{"wrong code", "081805", at, 0, false},
+		{"eight digits", "07081804", at, 0, false},
+		{"empty", "", at, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MatchTOTP(rfcSecret, tt.code, tt.now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("MatchTOTP(%q) = %d, %v; want %d, %v", tt.code, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"123456", true},
+		{"000000", true},
+		{"12345", false},
+		{"1234567", false},
+		{"12345a", false},
+		{"123 45", false},
+		{"abcde-fghij", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsTOTPCode(tt.in); got != tt.want {
+			t.Errorf("IsTOTPCode(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abcde-fghij", "abcdefghij"},
+		{"ABCDE-FGHIJ", "abcdefghij"},
+		{"abcdefghij", "abcdefghij"},
+		{" abcde fghij ", "abcdefghij"},
+		{"ab-cde-fg hij", "abcdefghij"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if !format.MatchString(code) || IsTOTPCode(code) {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+	if len(codes) != 10 {
+		t.Errorf("got %d codes, want 10", len(codes))
+	}
+}
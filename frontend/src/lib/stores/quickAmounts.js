@@ -41,8 +41,10 @@ function createQuickAmountsStore() {
       try {
         const resp = await api.get('/quick-amounts');
         if (Array.isArray(resp.data) && resp.data.length > 0) {
-          set(resp.data);
-          localStorage.setItem(storageKey(userId), JSON.stringify(resp.data));
+          // Amounts arrive as decimal strings; quick amounts are kept as numbers
+          const amounts = resp.data.map((v) => Number(v));
+          set(amounts);
+          localStorage.setItem(storageKey(userId), JSON.stringify(amounts));
           return;
         }
       } catch (err) {
@@ -1,5 +1,6 @@
 import { get } from 'svelte/store';
 import { currency } from '../stores/currency';
+import { isDecimalAmount } from './money';
 
 // Currency symbols mapping
 const CURRENCY_SYMBOLS = {
@@ -38,9 +39,12 @@ export function formatCurrency(amount, forceCurrency = null) {
     }
   }
   
-  // Convert string to number if needed
+  // Amounts from the API are decimal strings, which Intl formats exactly;
+  // other strings are typed input, reduced to their digits
   let numericValue;
-  if (typeof amount === 'string') {
+  if (isDecimalAmount(amount)) {
+    numericValue = amount.trim();
+  } else if (typeof amount === 'string') {
     numericValue = amount.replace(/\D/g, '');
     if (!numericValue) return '';
     numericValue = parseFloat(numericValue);
@@ -48,7 +52,7 @@ export function formatCurrency(amount, forceCurrency = null) {
     numericValue = amount;
   }
   
-  if (isNaN(numericValue)) return '';
+  if (typeof numericValue === 'number' && isNaN(numericValue)) return '';
   
   const symbol = CURRENCY_SYMBOLS[currentCurrency] || 'Rp.';
   const locale = CURRENCY_LOCALES[currentCurrency] || 'id-ID';
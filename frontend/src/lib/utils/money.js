@@ -0,0 +1,83 @@
+// Amounts come from the API as decimal strings such as "-1234.5", with at
+// most four decimal places, so they never lose precision in a float.
+
+const AMOUNT_PATTERN = /^[+-]?(\d+(\.\d*)?|\.\d+)$/;
+const AMOUNT_SCALE = 4;
+const UNIT = 10n ** BigInt(AMOUNT_SCALE);
+
+/**
+ * Check whether a value is a decimal amount string as the API sends them
+ * @param {unknown} value
+ * @returns {boolean}
+ */
+export function isDecimalAmount(value) {
+  return typeof value === 'string' && AMOUNT_PATTERN.test(value.trim());
+}
+
+/**
+ * Convert an amount into a number, for charts, comparisons and percentages.
+ * Totals should be computed with sumAmounts, which is exact.
+ * @param {number|string|null|undefined} amount
+ * @returns {number}
+ */
+export function toNumber(amount) {
+  if (amount === null || amount === undefined || amount === '') return 0;
+  const n = Number(amount);
+  return isNaN(n) ? 0 : n;
+}
+
+// toUnits reads an amount as a whole number of 10^-4 units
+function toUnits(amount) {
+  if (typeof amount === 'number') {
+    amount = amount.toFixed(AMOUNT_SCALE);
+  }
+  if (!isDecimalAmount(amount)) return 0n;
+  let s = amount.trim();
+  let sign = 1n;
+  if (s[0] === '-' || s[0] === '+') {
+    if (s[0] === '-') sign = -1n;
+    s = s.slice(1);
+  }
+  const [whole, frac = ''] = s.split('.');
+  const digits = (frac + '0'.repeat(AMOUNT_SCALE)).slice(0, AMOUNT_SCALE);
+  return sign * (BigInt(whole || '0') * UNIT + BigInt(digits));
+}
+
+// fromUnits writes a number of 10^-4 units as a decimal amount string
+function fromUnits(units) {
+  const sign = units < 0n ? '-' : '';
+  if (units < 0n) units = -units;
+  const whole = units / UNIT;
+  const frac = (units % UNIT).toString().padStart(AMOUNT_SCALE, '0').replace(/0+$/, '');
+  return sign + whole.toString() + (frac ? '.' + frac : '');
+}
+
+/**
+ * Add up amounts exactly
+ * @param {Array<any>} items
+ * @param {(item: any) => number|string} [pick] - Amount of an item
+ * @returns {string} The total as a decimal amount string
+ */
+export function sumAmounts(items, pick = (item) => item) {
+  return fromUnits(items.reduce((sum, item) => sum + toUnits(pick(item)), 0n));
+}
+
+/**
+ * Subtract one amount from another exactly
+ * @param {number|string} a
+ * @param {number|string} b
+ * @returns {string} a - b as a decimal amount string
+ */
+export function subtractAmounts(a, b) {
+  return fromUnits(toUnits(a) - toUnits(b));
+}
+
+/**
+ * Drop the sign of an amount
+ * @param {number|string} amount
+ * @returns {string} |amount| as a decimal amount string
+ */
+export function absAmount(amount) {
+  const units = toUnits(amount);
+  return fromUnits(units < 0n ? -units : units);
+}